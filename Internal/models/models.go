@@ -6,28 +6,56 @@ import (
 )
 
 type CrawlTask struct {
-    ID          string            `json:"id" bson:"_id"`
-    URL         string            `json:"url" bson:"url"`
-    Method      string            `json:"method" bson:"method"`
-    Headers     map[string]string `json:"headers" bson:"headers"`
-    Priority    int               `json:"priority" bson:"priority"`
-    MaxDepth    int               `json:"max_depth" bson:"max_depth"`
-    CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
-    ScheduledAt time.Time         `json:"scheduled_at" bson:"scheduled_at"`
-    Status      string            `json:"status" bson:"status"`
-    SessionID   string            `json:"session_id" bson:"session_id"`
+    ID             string            `json:"id" bson:"_id"`
+    URL            string            `json:"url" bson:"url"`
+    // Host is the key the task's politeness is tracked under
+    Host           string            `json:"host,omitempty" bson:"host,omitempty"`
+    ParentURL      string            `json:"parent_url,omitempty" bson:"parent_url,omitempty"`
+    Method         string            `json:"method" bson:"method"`
+    Headers        map[string]string `json:"headers" bson:"headers"`
+    Priority       int               `json:"priority" bson:"priority"`
+    MaxDepth       int               `json:"max_depth" bson:"max_depth"`
+    Depth          int               `json:"depth" bson:"depth"`
+    CreatedAt      time.Time         `json:"created_at" bson:"created_at"`
+    ScheduledAt    time.Time         `json:"scheduled_at" bson:"scheduled_at"`
+    Status         string            `json:"status" bson:"status"`
+    SessionID      string            `json:"session_id" bson:"session_id"`
+    Attempts       int               `json:"attempts" bson:"attempts"`
+    WorkerID       string            `json:"worker_id,omitempty" bson:"worker_id,omitempty"`
+    LeasedBy       string            `json:"leased_by,omitempty" bson:"leased_by,omitempty"`
+    LeaseExpiresAt *time.Time        `json:"lease_expires_at,omitempty" bson:"lease_expires_at,omitempty"`
+    // LeaseID tells the lease a task was delivered under from later ones
+    LeaseID        string            `json:"lease_id,omitempty" bson:"lease_id,omitempty"`
+    LastError      string            `json:"last_error,omitempty" bson:"last_error,omitempty"`
 }
 
 type CrawlResult struct {
-    TaskID    string        `json:"task_id" bson:"task_id"`
-    URL       string        `json:"url" bson:"url"`
-    WorkerID  string        `json:"worker_id" bson:"worker_id"`
-    Success   bool          `json:"success" bson:"success"`
-    Data      *CrawlData    `json:"data,omitempty" bson:"data,omitempty"`
-    Error     string        `json:"error,omitempty" bson:"error,omitempty"`
-    StartTime time.Time     `json:"start_time" bson:"start_time"`
-    EndTime   time.Time     `json:"end_time" bson:"end_time"`
-    Duration  time.Duration `json:"duration" bson:"duration"`
+    TaskID         string        `json:"task_id" bson:"task_id"`
+    SessionID      string        `json:"session_id" bson:"session_id"`
+    URL            string        `json:"url" bson:"url"`
+    ParentURL      string        `json:"parent_url,omitempty" bson:"parent_url,omitempty"`
+    Depth          int           `json:"depth" bson:"depth"`
+    Attempt        int           `json:"attempt" bson:"attempt"`
+    WorkerID       string        `json:"worker_id" bson:"worker_id"`
+    Success        bool          `json:"success" bson:"success"`
+    Data           *CrawlData    `json:"data,omitempty" bson:"data,omitempty"`
+    Error          string        `json:"error,omitempty" bson:"error,omitempty"`
+    Skipped        bool          `json:"skipped,omitempty" bson:"skipped,omitempty"`
+    SkipReason     string        `json:"skip_reason,omitempty" bson:"skip_reason,omitempty"`
+    // Unchanged results carry no body; PreviousTaskID names the result
+    // holding the last downloaded snapshot
+    Unchanged      bool          `json:"unchanged,omitempty" bson:"unchanged,omitempty"`
+    PreviousTaskID string        `json:"previous_task_id,omitempty" bson:"previous_task_id,omitempty"`
+    // ContentHash and SimHash fingerprint the page text. Change is new,
+    // changed, unchanged or gone compared with the URL's last snapshot
+    // from another session, which PreviousTaskID then names
+    ContentHash    string        `json:"content_hash,omitempty" bson:"content_hash,omitempty"`
+    SimHash        string        `json:"simhash,omitempty" bson:"simhash,omitempty"`
+    Change         string        `json:"change,omitempty" bson:"change,omitempty"`
+    ChangeDistance int           `json:"change_distance,omitempty" bson:"change_distance,omitempty"`
+    StartTime      time.Time     `json:"start_time" bson:"start_time"`
+    EndTime        time.Time     `json:"end_time" bson:"end_time"`
+    Duration       time.Duration `json:"duration" bson:"duration"`
 }
 
 type CrawlData struct {
@@ -42,34 +70,115 @@ type CrawlData struct {
 }
 
 type CrawlSession struct {
-    ID          string            `json:"id" bson:"_id"`
-    Name        string            `json:"name" bson:"name"`
-    Description string            `json:"description" bson:"description"`
-    StartURLs   []string          `json:"start_urls" bson:"start_urls"`
-    Rules       CrawlRules        `json:"rules" bson:"rules"`
-    Status      string            `json:"status" bson:"status"`
-    CreatedAt   time.Time         `json:"created_at" bson:"created_at"`
-    StartedAt   *time.Time        `json:"started_at,omitempty" bson:"started_at,omitempty"`
-    CompletedAt *time.Time        `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
-    Stats       SessionStats      `json:"stats" bson:"stats"`
+    ID          string             `json:"id" bson:"_id"`
+    Name        string             `json:"name" bson:"name"`
+    Description string             `json:"description" bson:"description"`
+    StartURLs   []string           `json:"start_urls" bson:"start_urls"`
+    Rules       CrawlRules         `json:"rules" bson:"rules"`
+    Status      string             `json:"status" bson:"status"`
+    CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+    StartedAt   *time.Time         `json:"started_at,omitempty" bson:"started_at,omitempty"`
+    CompletedAt *time.Time         `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+    Stats       SessionStats       `json:"stats" bson:"stats"`
+    Schemas     []ExtractionSchema `json:"schemas,omitempty" bson:"schemas,omitempty"`
+}
+
+// ExtractionSchema describes structured records to pull out of HTML pages.
+// When Selector is set, every node it matches yields one record and field
+// selectors are evaluated relative to that node; otherwise the whole page
+// yields a single record.
+type ExtractionSchema struct {
+    Name         string            `json:"name" bson:"name"`
+    Selector     string            `json:"selector,omitempty" bson:"selector,omitempty"`
+    SelectorType string            `json:"selector_type,omitempty" bson:"selector_type,omitempty"`
+    URLPattern   string            `json:"url_pattern,omitempty" bson:"url_pattern,omitempty"`
+    Fields       []ExtractionField `json:"fields" bson:"fields"`
+}
+
+type ExtractionField struct {
+    Name         string                `json:"name" bson:"name"`
+    Selector     string                `json:"selector" bson:"selector"`
+    SelectorType string                `json:"selector_type,omitempty" bson:"selector_type,omitempty"`
+    Attribute    string                `json:"attribute,omitempty" bson:"attribute,omitempty"`
+    Multiple     bool                  `json:"multiple,omitempty" bson:"multiple,omitempty"`
+    Required     bool                  `json:"required,omitempty" bson:"required,omitempty"`
+    Transforms   []ExtractionTransform `json:"transforms,omitempty" bson:"transforms,omitempty"`
+}
+
+type ExtractionTransform struct {
+    Type        string `json:"type" bson:"type"`
+    Pattern     string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+    Replacement string `json:"replacement,omitempty" bson:"replacement,omitempty"`
+    Group       int    `json:"group,omitempty" bson:"group,omitempty"`
+    Layout      string `json:"layout,omitempty" bson:"layout,omitempty"`
 }
 
 type CrawlRules struct {
-    MaxDepth        int      `json:"max_depth" bson:"max_depth"`
-    MaxPages        int      `json:"max_pages" bson:"max_pages"`
-    AllowedDomains  []string `json:"allowed_domains" bson:"allowed_domains"`
-    BlockedDomains  []string `json:"blocked_domains" bson:"blocked_domains"`
-    URLPatterns     []string `json:"url_patterns" bson:"url_patterns"`
-    RespectRobotsTxt bool    `json:"respect_robots_txt" bson:"respect_robots_txt"`
-    Delay           int      `json:"delay" bson:"delay"`
+    MaxDepth         int           `json:"max_depth" bson:"max_depth"`
+    MaxPages         int           `json:"max_pages" bson:"max_pages"`
+    AllowedDomains   []string      `json:"allowed_domains" bson:"allowed_domains"`
+    BlockedDomains   []string      `json:"blocked_domains" bson:"blocked_domains"`
+    URLPatterns      []string      `json:"url_patterns" bson:"url_patterns"`
+    ExcludePatterns  []string      `json:"exclude_patterns" bson:"exclude_patterns"`
+    RespectRobotsTxt bool          `json:"respect_robots_txt" bson:"respect_robots_txt"`
+    Delay            int           `json:"delay" bson:"delay"`
+    // Weight is the session's share of dispatches relative to other
+    // running sessions; zero counts as one
+    Weight           int           `json:"weight,omitempty" bson:"weight,omitempty"`
+    Render           bool          `json:"render" bson:"render"`
+    RenderOptions    RenderOptions `json:"render_options" bson:"render_options"`
+    Sitemaps         SitemapRules  `json:"sitemaps" bson:"sitemaps"`
+}
+
+// SitemapRules seeds a session from the sitemaps of its start URLs' sites,
+// found through robots.txt or at /sitemap.xml, plus any listed in URLs.
+// With SkipUnchanged, URLs whose <lastmod> predates their last fetch are
+// not queued.
+type SitemapRules struct {
+    Enabled       bool     `json:"enabled" bson:"enabled"`
+    URLs          []string `json:"urls,omitempty" bson:"urls,omitempty"`
+    SkipUnchanged bool     `json:"skip_unchanged,omitempty" bson:"skip_unchanged,omitempty"`
+}
+
+// RenderOptions controls headless rendering. Pages are rendered when the
+// session sets Render or when their URL matches one of URLPatterns.
+type RenderOptions struct {
+    URLPatterns    []string `json:"url_patterns,omitempty" bson:"url_patterns,omitempty"`
+    WaitFor        string   `json:"wait_for,omitempty" bson:"wait_for,omitempty"`
+    WaitSelector   string   `json:"wait_selector,omitempty" bson:"wait_selector,omitempty"`
+    WaitDelay      int      `json:"wait_delay,omitempty" bson:"wait_delay,omitempty"`
+    Screenshot     bool     `json:"screenshot,omitempty" bson:"screenshot,omitempty"`
+    // BlockResources replaces the pool's blocked resource types when set
+    BlockResources []string `json:"block_resources,omitempty" bson:"block_resources,omitempty"`
 }
 
 type SessionStats struct {
-    TotalTasks      int `json:"total_tasks" bson:"total_tasks"`
-    CompletedTasks  int `json:"completed_tasks" bson:"completed_tasks"`
-    FailedTasks     int `json:"failed_tasks" bson:"failed_tasks"`
-    PendingTasks    int `json:"pending_tasks" bson:"pending_tasks"`
-    PagesPerMinute  int `json:"pages_per_minute" bson:"pages_per_minute"`
+    TotalTasks     int   `json:"total_tasks" bson:"total_tasks"`
+    CompletedTasks int   `json:"completed_tasks" bson:"completed_tasks"`
+    FailedTasks    int   `json:"failed_tasks" bson:"failed_tasks"`
+    SkippedTasks   int   `json:"skipped_tasks" bson:"skipped_tasks"`
+    PendingTasks   int   `json:"pending_tasks" bson:"pending_tasks"`
+    PagesPerMinute int   `json:"pages_per_minute" bson:"pages_per_minute"`
+    DuplicateURLs  int   `json:"duplicate_urls" bson:"duplicate_urls"`
+    UnchangedTasks int   `json:"unchanged_tasks" bson:"unchanged_tasks"`
+    // BytesSaved is what unchanged pages weighed when last downloaded
+    BytesSaved     int64 `json:"bytes_saved" bson:"bytes_saved"`
+    NewPages       int   `json:"new_pages" bson:"new_pages"`
+    ChangedPages   int   `json:"changed_pages" bson:"changed_pages"`
+    GonePages      int   `json:"gone_pages" bson:"gone_pages"`
+    SitemapURLs    int   `json:"sitemap_urls" bson:"sitemap_urls"`
+}
+
+// URLValidators are the cache validators last seen for a canonical URL,
+// used to make re-crawls conditional.
+type URLValidators struct {
+    URL           string    `json:"url" bson:"url"`
+    ETag          string    `json:"etag,omitempty" bson:"etag,omitempty"`
+    LastModified  string    `json:"last_modified,omitempty" bson:"last_modified,omitempty"`
+    TaskID        string    `json:"task_id" bson:"task_id"`
+    SessionID     string    `json:"session_id" bson:"session_id"`
+    ContentLength int64     `json:"content_length" bson:"content_length"`
+    FetchedAt     time.Time `json:"fetched_at" bson:"fetched_at"`
 }
 
 type ProxyInfo struct {
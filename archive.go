@@ -0,0 +1,210 @@
+// archive.go
+package main
+
+import (
+    "bytes"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "net/http/httptrace"
+    "strconv"
+    "sync"
+    "time"
+
+    "crawler666/internal/models"
+    "crawler666/pkg/parser"
+    "crawler666/pkg/render"
+    "crawler666/pkg/warc"
+)
+
+// fetchRecorder follows a fetch for the WARC archive: the address each
+// request went to, how long it took, and every redirect response on the
+// way to the final one.
+type fetchRecorder struct {
+    worker   *Worker
+    task     *models.CrawlTask
+    mu       sync.Mutex
+    ip       string
+    started  time.Time
+    finalURL string
+    hops     []*warc.Exchange
+}
+
+func (w *Worker) newFetchRecorder(task *models.CrawlTask) *fetchRecorder {
+    return &fetchRecorder{worker: w, task: task, started: time.Now(), finalURL: task.URL}
+}
+
+// trace returns req with hooks noting the connection of each request,
+// redirects included.
+func (r *fetchRecorder) trace(req *http.Request) *http.Request {
+    trace := &httptrace.ClientTrace{
+        GetConn: func(string) {
+            r.mu.Lock()
+            r.started = time.Now()
+            r.mu.Unlock()
+        },
+        GotConn: func(info httptrace.GotConnInfo) {
+            r.mu.Lock()
+            r.ip = remoteIP(info.Conn.RemoteAddr())
+            r.mu.Unlock()
+        },
+    }
+    return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// checkRedirect wraps a client's redirect policy so each redirect
+// response is kept before the client follows it.
+func (r *fetchRecorder) checkRedirect(policy func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+    return func(req *http.Request, via []*http.Request) error {
+        if resp := req.Response; resp != nil {
+            raw, truncated, err := readRaw(resp.Body, r.worker.Engine.config.MaxBodySize)
+            if err != nil {
+                r.worker.Engine.logger.Debugf("Failed to read redirect from %s: %v", resp.Request.URL, err)
+            }
+            r.mu.Lock()
+            r.hops = append(r.hops, r.exchange(resp, raw, truncated))
+            r.finalURL = req.URL.String()
+            r.mu.Unlock()
+        }
+
+        if policy != nil {
+            return policy(req, via)
+        }
+        if len(via) >= 10 {
+            return errors.New("stopped after 10 redirects")
+        }
+        return nil
+    }
+}
+
+// exchange captures a response for the archive; r.mu must be held.
+func (r *fetchRecorder) exchange(resp *http.Response, body []byte, truncated bool) *warc.Exchange {
+    ex := newExchange(resp, body, time.Now())
+    ex.IP = r.ip
+    ex.Truncated = truncated
+    ex.Metadata = r.worker.archiveMetadata(r.task, ex.Date.Sub(r.started), truncated)
+    return ex
+}
+
+// archive writes the redirect responses followed by the final one, if
+// the fetch got that far. Every record names the URL the fetch ended at.
+func (r *fetchRecorder) archive(resp *http.Response, body []byte, truncated bool) {
+    r.mu.Lock()
+    exchanges := r.hops
+    if resp != nil {
+        exchanges = append(exchanges, r.exchange(resp, body, truncated))
+    }
+    finalURL := r.finalURL
+    r.mu.Unlock()
+
+    for _, ex := range exchanges {
+        ex.Metadata["finalURL"] = finalURL
+        r.worker.archive(r.task.SessionID, ex)
+    }
+}
+
+// archiveRendered records the DOM of a rendered page as the response to
+// its final URL. The body is the serialised DOM rather than what the
+// server sent, so the headers describing its transfer are dropped.
+func (w *Worker) archiveRendered(task *models.CrawlTask, page *render.Page, duration time.Duration, date time.Time) {
+    header := make(http.Header, len(page.Headers))
+    for k, v := range page.Headers {
+        header.Set(k, v)
+    }
+    for _, name := range []string{"Content-Encoding", "Content-Length", "Transfer-Encoding"} {
+        header.Del(name)
+    }
+    header.Set("Content-Type", "text/html; charset=utf-8")
+
+    status := page.StatusCode
+    if status == 0 {
+        status = http.StatusOK
+    }
+    var head bytes.Buffer
+    fmt.Fprintf(&head, "HTTP/1.1 %d %s\r\n", status, http.StatusText(status))
+    header.Write(&head)
+    head.WriteString("\r\n")
+
+    metadata := w.archiveMetadata(task, duration, false)
+    metadata["finalURL"] = page.URL
+    metadata["rendered"] = "true"
+
+    w.archive(task.SessionID, &warc.Exchange{
+        URL:          page.URL,
+        IP:           page.IP,
+        Date:         date,
+        ResponseHead: head.Bytes(),
+        Body:         []byte(page.HTML),
+        ContentType:  header.Get("Content-Type"),
+        StatusCode:   status,
+        Metadata:     metadata,
+    })
+}
+
+// archiveMetadata describes how a response was fetched, for the metadata
+// record next to it.
+func (w *Worker) archiveMetadata(task *models.CrawlTask, duration time.Duration, truncated bool) map[string]string {
+    metadata := map[string]string{
+        "fetchTimeMs": strconv.FormatInt(duration.Milliseconds(), 10),
+        "sessionID":   task.SessionID,
+        "taskID":      task.ID,
+        "workerID":    w.ID,
+    }
+    if truncated {
+        metadata["truncated"] = "length"
+    }
+    return metadata
+}
+
+func (w *Worker) archive(sessionID string, ex *warc.Exchange) {
+    if err := w.Engine.storage.ArchiveExchange(sessionID, ex); err != nil {
+        w.Engine.logger.Errorf("Failed to archive %s: %v", ex.URL, err)
+    }
+}
+
+// newExchange captures a fetch for the WARC archive. Request headers are
+// those the client sent; hop-by-hop framing is not reproduced.
+func newExchange(resp *http.Response, body []byte, date time.Time) *warc.Exchange {
+    var request bytes.Buffer
+    req := resp.Request
+    fmt.Fprintf(&request, "%s %s HTTP/1.1\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.Host)
+    req.Header.Write(&request)
+    request.WriteString("\r\n")
+
+    var response bytes.Buffer
+    fmt.Fprintf(&response, "%s %s\r\n", resp.Proto, resp.Status)
+    resp.Header.Write(&response)
+    response.WriteString("\r\n")
+
+    return &warc.Exchange{
+        URL:          req.URL.String(),
+        Date:         date,
+        RequestHead:  request.Bytes(),
+        ResponseHead: response.Bytes(),
+        Body:         body,
+        ContentType:  resp.Header.Get("Content-Type"),
+        StatusCode:   resp.StatusCode,
+    }
+}
+
+// readRaw reads a body as transferred, up to maxSize bytes.
+func readRaw(body io.Reader, maxSize int64) ([]byte, bool, error) {
+    if maxSize <= 0 {
+        maxSize = parser.DefaultMaxBodySize
+    }
+    raw, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+    if int64(len(raw)) > maxSize {
+        return raw[:maxSize], true, err
+    }
+    return raw, false, err
+}
+
+func remoteIP(addr net.Addr) string {
+    host, _, err := net.SplitHostPort(addr.String())
+    if err != nil {
+        return addr.String()
+    }
+    return host
+}
@@ -0,0 +1,284 @@
+// changes.go
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "crawler666/internal/models"
+    "crawler666/pkg/diff"
+    "crawler666/pkg/fingerprint"
+    "crawler666/pkg/parser"
+)
+
+var (
+    ErrSnapshotNotFound   = errors.New("snapshot not found")
+    ErrNoPreviousSnapshot = errors.New("no previous snapshot")
+    ErrSnapshotMismatch   = errors.New("snapshots are of different URLs")
+)
+
+// URLChange is the classification of one URL of a session.
+type URLChange struct {
+    URL            string `json:"url"`
+    Change         string `json:"change"`
+    TaskID         string `json:"task_id,omitempty"`
+    PreviousTaskID string `json:"previous_task_id,omitempty"`
+    Distance       int    `json:"distance,omitempty"`
+}
+
+// SnapshotRef identifies one side of a snapshot diff.
+type SnapshotRef struct {
+    TaskID      string    `json:"task_id"`
+    SessionID   string    `json:"session_id"`
+    FetchedAt   time.Time `json:"fetched_at"`
+    ContentHash string    `json:"content_hash,omitempty"`
+}
+
+// SnapshotDiff is a line diff of the text of two snapshots of a URL.
+type SnapshotDiff struct {
+    URL      string       `json:"url"`
+    From     *SnapshotRef `json:"from"`
+    To       *SnapshotRef `json:"to"`
+    Distance int          `json:"distance"`
+    Added    int          `json:"added"`
+    Removed  int          `json:"removed"`
+    Diff     string       `json:"diff"`
+}
+
+// detectChange fingerprints a result and classifies it against the URL's
+// last snapshot from another session. A 404 or 410 for a URL that used to
+// be fetched is gone; failures otherwise carry no classification.
+func (e *CrawlerEngine) detectChange(result *models.CrawlResult) {
+    if result.Unchanged {
+        // A 304 vouches for the last download, fingerprint included
+        result.Change = "unchanged"
+        snapshot, err := e.storage.GetCrawlResult(result.PreviousTaskID)
+        if err != nil {
+            e.logger.Errorf("Failed to load previous snapshot of %s: %v", result.URL, err)
+        } else if snapshot != nil {
+            result.ContentHash, result.SimHash = snapshot.ContentHash, snapshot.SimHash
+        }
+        return
+    }
+
+    gone := result.Data != nil &&
+        (result.Data.StatusCode == http.StatusNotFound || result.Data.StatusCode == http.StatusGone)
+    if !result.Success && !gone {
+        return
+    }
+
+    previous, err := e.storage.GetLatestSnapshot(result.URL, result.SessionID)
+    if err != nil {
+        e.logger.Errorf("Failed to load previous snapshot of %s: %v", result.URL, err)
+        return
+    }
+
+    if gone {
+        if previous != nil {
+            result.Change = "gone"
+            result.PreviousTaskID = snapshotTaskID(previous)
+        }
+        return
+    }
+
+    fingerprintResult(result)
+    if previous == nil {
+        result.Change = "new"
+        return
+    }
+
+    result.PreviousTaskID = snapshotTaskID(previous)
+    if previous.ContentHash == result.ContentHash {
+        result.Change = "unchanged"
+        return
+    }
+    result.Change = "changed"
+    result.ChangeDistance = simHashDistance(previous.SimHash, result.SimHash)
+}
+
+// fingerprintResult hashes the text of a result's content. Blobs have no
+// text, so they keep the digest of their bytes and no SimHash.
+func fingerprintResult(result *models.CrawlResult) {
+    text := pageText(result.Data)
+    if text == "" {
+        result.ContentHash, _ = result.Data.Metadata["sha256"].(string)
+        return
+    }
+
+    fp := fingerprint.Compute(text)
+    result.ContentHash = fp.Hash
+    result.SimHash = fingerprint.FormatSimHash(fp.SimHash)
+}
+
+// pageText returns the text that is fingerprinted and diffed: the visible
+// text of HTML pages and the content itself for other text types.
+func pageText(data *models.CrawlData) string {
+    mediaType, _ := data.Metadata["media_type"].(string)
+    if parser.IsHTML(mediaType) {
+        if text, err := parser.Text(strings.NewReader(data.Content)); err == nil {
+            return text
+        }
+    }
+    return data.Content
+}
+
+// simHashDistance returns the number of differing bits between two
+// formatted SimHashes, or 64 when either is missing.
+func simHashDistance(a, b string) int {
+    x, errA := fingerprint.ParseSimHash(a)
+    y, errB := fingerprint.ParseSimHash(b)
+    if errA != nil || errB != nil {
+        return 64
+    }
+    return fingerprint.Distance(x, y)
+}
+
+// snapshotTaskID names the result holding a snapshot's body, which for a
+// 304 is the download it confirmed.
+func snapshotTaskID(result *models.CrawlResult) string {
+    if result.Unchanged && result.PreviousTaskID != "" {
+        return result.PreviousTaskID
+    }
+    return result.TaskID
+}
+
+// loadSnapshot returns a task's result resolved to the one holding its body.
+func (e *CrawlerEngine) loadSnapshot(taskID string) (*models.CrawlResult, error) {
+    result, err := e.storage.GetCrawlResult(taskID)
+    if err != nil {
+        return nil, err
+    }
+    if result != nil && result.Unchanged {
+        result, err = e.storage.GetCrawlResult(result.PreviousTaskID)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if result == nil || result.Data == nil {
+        return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, taskID)
+    }
+    return result, nil
+}
+
+// DiffSnapshots diffs the text of a URL between two results. fromTaskID
+// defaults to the snapshot the later result was classified against.
+func (e *CrawlerEngine) DiffSnapshots(toTaskID, fromTaskID string) (*SnapshotDiff, error) {
+    if fromTaskID == "" {
+        result, err := e.storage.GetCrawlResult(toTaskID)
+        if err != nil {
+            return nil, err
+        }
+        if result == nil {
+            return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, toTaskID)
+        }
+        if result.PreviousTaskID == "" {
+            return nil, ErrNoPreviousSnapshot
+        }
+        fromTaskID = result.PreviousTaskID
+    }
+
+    to, err := e.loadSnapshot(toTaskID)
+    if err != nil {
+        return nil, err
+    }
+    from, err := e.loadSnapshot(fromTaskID)
+    if err != nil {
+        return nil, err
+    }
+    if from.URL != to.URL {
+        return nil, ErrSnapshotMismatch
+    }
+
+    if from.ContentHash == "" {
+        fingerprintResult(from)
+    }
+    if to.ContentHash == "" {
+        fingerprintResult(to)
+    }
+
+    lines := diff.Lines(pageText(from.Data), pageText(to.Data))
+    return &SnapshotDiff{
+        URL:      to.URL,
+        From:     newSnapshotRef(from),
+        To:       newSnapshotRef(to),
+        Distance: simHashDistance(from.SimHash, to.SimHash),
+        Added:    lines.Added,
+        Removed:  lines.Removed,
+        Diff:     lines.Unified(from.TaskID, to.TaskID, 3),
+    }, nil
+}
+
+func newSnapshotRef(result *models.CrawlResult) *SnapshotRef {
+    return &SnapshotRef{
+        TaskID:      result.TaskID,
+        SessionID:   result.SessionID,
+        FetchedAt:   result.StartTime,
+        ContentHash: result.ContentHash,
+    }
+}
+
+// SessionChanges lists the classification of every URL the session
+// fetched, optionally filtered to one kind of change. When since names an
+// earlier session, URLs it fetched that this session never reached are
+// reported as gone too.
+func (e *CrawlerEngine) SessionChanges(ctx context.Context, sessionID, since, change string) ([]*URLChange, error) {
+    byURL := make(map[string]*URLChange)
+    var order []string
+    _, err := e.storage.StreamCrawlResults(ctx, sessionID, "", 0, func(_ string, result *models.CrawlResult) error {
+        _, seen := byURL[result.URL]
+        if !seen {
+            order = append(order, result.URL)
+        }
+        // Results stream in insertion order, so a classified later
+        // attempt supersedes an earlier failed one
+        if !seen || result.Change != "" {
+            byURL[result.URL] = &URLChange{
+                URL:            result.URL,
+                Change:         result.Change,
+                TaskID:         result.TaskID,
+                PreviousTaskID: result.PreviousTaskID,
+                Distance:       result.ChangeDistance,
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    var changes []*URLChange
+    for _, url := range order {
+        if c := byURL[url]; c.Change != "" && (change == "" || c.Change == change) {
+            changes = append(changes, c)
+        }
+    }
+
+    if since == "" || (change != "" && change != "gone") {
+        return changes, nil
+    }
+
+    missing := make(map[string]bool)
+    _, err = e.storage.StreamCrawlResults(ctx, since, "", 0, func(_ string, result *models.CrawlResult) error {
+        if !result.Success || missing[result.URL] {
+            return nil
+        }
+        if _, reached := byURL[result.URL]; reached {
+            return nil
+        }
+        missing[result.URL] = true
+        changes = append(changes, &URLChange{
+            URL:            result.URL,
+            Change:         "gone",
+            PreviousTaskID: snapshotTaskID(result),
+        })
+        return nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return changes, nil
+}
@@ -3,15 +3,38 @@ package main
 
 import (
     "context"
+    "errors"
     "fmt"
+    "net"
+    "net/http"
+    "net/url"
+    "os"
+    "regexp"
+    "strconv"
     "sync"
     "time"
 
     "crawler666/internal/models"
+    "crawler666/pkg/browserpool"
+    "crawler666/pkg/cluster"
+    "crawler666/pkg/dedup"
+    "crawler666/pkg/extract"
+    "crawler666/pkg/frontier"
+    "crawler666/pkg/parser"
+    "crawler666/pkg/processor"
     "crawler666/pkg/proxy"
+    "crawler666/pkg/queue"
+    "crawler666/pkg/ratelimit"
+    "crawler666/pkg/render"
+    "crawler666/pkg/retry"
+    "crawler666/pkg/robots"
+    "crawler666/pkg/sitemap"
     "crawler666/pkg/stealth"
     "crawler666/pkg/storage"
+    "crawler666/pkg/urlfilter"
+    "crawler666/pkg/urlnorm"
 
+    "github.com/google/uuid"
     "github.com/sirupsen/logrus"
 )
 
@@ -20,16 +43,32 @@ type CrawlerEngine struct {
     storage    storage.Interface
     proxyMgr   *proxy.Manager
     stealthEng *stealth.Engine
+    robots     *robots.Checker
+    sitemaps   *sitemap.Fetcher
+    normalizer *urlnorm.Normalizer
+    seen       dedup.Store
+    retry      *retry.Policy
+    processors *processor.Registry
+    renderer   *render.Renderer
+    browsers   *browserpool.Pool
     logger     *logrus.Logger
+    nodeID     string
+    cluster    *cluster.Coordinator
+    startedAt  time.Time
     
     workers    map[string]*Worker
+    workersCtx context.Context
+    nextWorker int
     scheduler  *Scheduler
-    queue      chan *models.CrawlTask
-    results    chan *models.CrawlResult
+    queue      queue.TaskQueue
+    results    chan *taskOutcome
+    sessions   map[string]*sessionState
     
     mu         sync.RWMutex
     running    bool
     stats      *CrawlStats
+    // renderElsewhere is set when another node of the cluster can render
+    renderElsewhere bool
 }
 
 type Worker struct {
@@ -37,20 +76,39 @@ type Worker struct {
     Engine   *CrawlerEngine
     ctx      context.Context
     cancel   context.CancelFunc
+    // drainCtx ends the worker's wait for tasks but not its current one
+    drainCtx context.Context
+    drain    context.CancelFunc
+    // active is cleared once the worker is draining; busy is set while it
+    // holds a task and waiting while it waits for the task's host
     active   bool
+    busy     bool
+    waiting  bool
 }
 
 type Scheduler struct {
     engine    *CrawlerEngine
-    domains   map[string]*DomainState
-    mu        sync.RWMutex
+    limiter   *ratelimit.HostLimiter
+    frontier  *frontier.Frontier
 }
 
-type DomainState struct {
-    LastRequest time.Time
-    RequestRate int
-    Blocked     bool
-    ProxyPool   string
+type sessionState struct {
+    session        *models.CrawlSession
+    filter         *urlfilter.Filter
+    extractor      *extract.Extractor
+    renderPatterns []*regexp.Regexp
+    // base holds the stored stats as of the last flush and stats what
+    // this process counted since
+    base        models.SessionStats
+    stats       models.SessionStats
+    fetches     rateWindow
+    flushedRate int
+    dirty       bool
+    // statusAt is when session.Status was last read from or written to
+    // storage
+    statusAt time.Time
+    flushing sync.Mutex
+    mu       sync.Mutex
 }
 
 type CrawlStats struct {
@@ -59,12 +117,16 @@ type CrawlStats struct {
     FailedCrawls      int64
     DetectionEvents   int64
     ActiveWorkers     int
+    BusyWorkers       int
+    WaitingWorkers    int
     QueueSize         int
+    FrontierSize      int
     mu                sync.RWMutex
 }
 
 func NewCrawlerEngine(config *CrawlerConfig, storage storage.Interface, 
-                     proxyMgr *proxy.Manager, stealthEng *stealth.Engine, 
+                     taskQueue queue.TaskQueue, coordinator *cluster.Coordinator,
+                     proxyMgr *proxy.Manager, stealthEng *stealth.Engine,
                      logger *logrus.Logger) *CrawlerEngine {
     
     engine := &CrawlerEngine{
@@ -73,75 +135,352 @@ func NewCrawlerEngine(config *CrawlerConfig, storage storage.Interface,
         proxyMgr:   proxyMgr,
         stealthEng: stealthEng,
         logger:     logger,
+        nodeID:     defaultNodeID(),
+        cluster:    coordinator,
         workers:    make(map[string]*Worker),
-        queue:      make(chan *models.CrawlTask, config.QueueSize),
-        results:    make(chan *models.CrawlResult, config.QueueSize),
+        queue:      taskQueue,
+        results:    make(chan *taskOutcome, config.QueueSize),
+        sessions:   make(map[string]*sessionState),
         stats:      &CrawlStats{},
     }
 
+    engine.robots = robots.NewChecker(&robots.Config{
+        UserAgent: config.UserAgent,
+        CacheTTL:  time.Duration(config.RobotsCacheTTL) * time.Second,
+        Timeout:   time.Duration(config.Timeout) * time.Second,
+    }, storage)
+
+    engine.sitemaps = sitemap.NewFetcher(&sitemap.Config{
+        UserAgent:   config.UserAgent,
+        Timeout:     time.Duration(config.Timeout) * time.Second,
+        MaxSitemaps: config.Sitemap.MaxSitemaps,
+        MaxBodySize: config.Sitemap.MaxBodySize,
+    })
+
+    trackingParams := config.TrackingParams
+    if len(trackingParams) == 0 {
+        trackingParams = urlnorm.DefaultTrackingParams
+    }
+    engine.normalizer = urlnorm.NewNormalizer(&urlnorm.Config{TrackingParams: trackingParams})
+
+    seen, err := dedup.NewStore(&dedup.Config{
+        Backend:           config.Dedup.Backend,
+        ExpectedURLs:      config.Dedup.ExpectedURLs,
+        FalsePositiveRate: config.Dedup.FalsePositiveRate,
+    }, storage, storage)
+    if err != nil {
+        logger.Errorf("Invalid dedup configuration, using exact sets: %v", err)
+        seen, _ = dedup.NewStore(&dedup.Config{Backend: "set"}, storage, storage)
+    }
+    engine.seen = seen
+
+    engine.retry = retry.NewPolicy(&retry.Config{
+        MaxAttempts:          config.Retry.MaxAttempts,
+        BackoffBase:          time.Duration(config.Retry.BackoffBase) * time.Second,
+        BackoffCap:           time.Duration(config.Retry.BackoffCap) * time.Second,
+        Jitter:               config.Retry.Jitter,
+        RetryableStatusCodes: config.Retry.RetryableStatusCodes,
+        RetryTimeouts:        config.Retry.RetryTimeouts,
+        RetryNetworkErrors:   config.Retry.RetryNetworkErrors,
+    })
+
+    engine.processors = processor.NewDefaultRegistry(storage)
+
+    if config.Render.Enabled {
+        // Renders share a small pool of browsers however many workers run
+        browsers, err := browserpool.NewPool(&browserpool.Config{
+            ExecPath:       config.Render.ExecPath,
+            Headless:       config.Render.Headless,
+            Browsers:       config.Render.Browsers,
+            TabsPerBrowser: config.Render.TabsPerBrowser,
+            PagesPerTab:    config.Render.PagesPerTab,
+            MaxMemory:      config.Render.MaxMemoryMB << 20,
+            AcquireTimeout: time.Duration(config.Render.AcquireTimeout) * time.Second,
+            BlockResources: config.Render.BlockResources,
+        })
+        if err != nil {
+            logger.Errorf("Rendering disabled: %v", err)
+        } else {
+            engine.browsers = browsers
+            engine.renderer = render.NewRenderer(&render.Config{
+                PageTimeout: time.Duration(config.Render.PageTimeout) * time.Second,
+            }, browsers)
+        }
+    }
+
     engine.scheduler = &Scheduler{
         engine:  engine,
-        domains: make(map[string]*DomainState),
+        limiter: ratelimit.NewHostLimiter(&ratelimit.Config{
+            Interval:    time.Duration(config.RateLimit) * time.Millisecond,
+            Burst:       config.HostBurst,
+            Concurrency: config.HostConcurrency,
+        }),
+    }
+    if config.Frontier.Capacity <= 0 {
+        config.Frontier.Capacity = config.QueueSize
+    }
+    if config.Frontier.PerHost <= 0 {
+        config.Frontier.PerHost = 4
+    }
+    if config.Autoscale.MinWorkers < 0 {
+        config.Autoscale.MinWorkers = 0
+    }
+    if config.Autoscale.MaxWorkers < config.Autoscale.MinWorkers {
+        config.Autoscale.MaxWorkers = config.Autoscale.MinWorkers
+    }
+    if config.Autoscale.Interval <= 0 {
+        config.Autoscale.Interval = 10
     }
+    engine.scheduler.frontier = frontier.New(&frontier.Config{
+        HostConcurrency: config.HostConcurrency,
+        Ready:           engine.scheduler.readyAt,
+    })
 
     return engine
 }
 
+// StartWorkers starts the parts of the engine the node's role calls for:
+// the scheduler leases tasks and publishes them to the queue, the workers
+// consume and crawl them. A standalone node runs both. In distributed mode
+// the node then registers with the cluster.
 func (e *CrawlerEngine) StartWorkers(ctx context.Context) {
     e.mu.Lock()
     e.running = true
+    e.startedAt = time.Now()
     e.mu.Unlock()
 
-    // Start result processor
-    go e.processResults(ctx)
+    if e.config.Role != "worker" {
+        // Start scheduler
+        go e.scheduler.run(ctx)
+        go e.scheduler.dispatch(ctx)
+    }
+
+    if e.config.Role != "scheduler" {
+        // Start result processor
+        go e.processResults(ctx)
 
-    // Start scheduler
-    go e.scheduler.run(ctx)
+        // Start session stats flusher
+        go e.flushSessionStats(ctx)
 
-    // Start workers
-    for i := 0; i < e.config.MaxWorkers; i++ {
-        workerID := fmt.Sprintf("worker-%d", i)
-        worker := e.createWorker(workerID, ctx)
-        e.workers[workerID] = worker
-        go worker.run()
+        // Start workers
+        size := e.config.MaxWorkers
+        if autoscale := e.config.Autoscale; autoscale.Enabled {
+            if size < autoscale.MinWorkers {
+                size = autoscale.MinWorkers
+            }
+            if size > autoscale.MaxWorkers {
+                size = autoscale.MaxWorkers
+            }
+        }
+        e.mu.Lock()
+        e.workersCtx = ctx
+        e.resize(size)
+        e.mu.Unlock()
+
+        e.logger.Infof("Started %d crawler workers", size)
+
+        if e.config.Autoscale.Enabled {
+            go e.autoscale(ctx)
+        }
     }
 
-    e.logger.Infof("Started %d crawler workers", e.config.MaxWorkers)
+    if e.cluster != nil {
+        go e.runNode(ctx)
+    }
 }
 
 func (e *CrawlerEngine) createWorker(id string, parentCtx context.Context) *Worker {
     ctx, cancel := context.WithCancel(parentCtx)
+    drainCtx, drain := context.WithCancel(ctx)
     return &Worker{
-        ID:     id,
-        Engine: e,
-        ctx:    ctx,
-        cancel: cancel,
-        active: true,
+        ID:       id,
+        Engine:   e,
+        ctx:      ctx,
+        cancel:   cancel,
+        drainCtx: drainCtx,
+        drain:    drain,
+        active:   true,
     }
 }
 
 func (w *Worker) run() {
     w.Engine.logger.Infof("Worker %s started", w.ID)
-    
+    defer w.Engine.removeWorker(w)
+
     for {
-        select {
-        case <-w.ctx.Done():
+        if w.drainCtx.Err() != nil {
             w.Engine.logger.Infof("Worker %s stopped", w.ID)
             return
-        case task := <-w.Engine.queue:
-            w.processTask(task)
+        }
+
+        delivery, err := w.Engine.queue.Consume(w.drainCtx)
+        if err != nil {
+            if w.drainCtx.Err() != nil || err == queue.ErrClosed {
+                w.Engine.logger.Infof("Worker %s stopped", w.ID)
+                return
+            }
+            w.Engine.logger.Errorf("Worker %s failed to receive a task: %v", w.ID, err)
+            select {
+            case <-w.drainCtx.Done():
+            case <-time.After(time.Second):
+            }
+            continue
+        }
+
+        task := delivery.Task()
+        if w.Engine.leaveToOthers(w.Engine.getSession(task.SessionID), task.URL) {
+            // Give a node that can render the chance to pick it up
+            if err := delivery.Nack(true); err != nil {
+                w.Engine.logger.Errorf("Failed to requeue task %s: %v", task.ID, err)
+            }
+            select {
+            case <-w.drainCtx.Done():
+            case <-time.After(time.Second):
+            }
+            continue
+        }
+
+        w.setBusy(true)
+        w.processTask(task)
+        w.setBusy(false)
+
+        // Whatever became of the task is recorded in storage by now
+        if err := delivery.Ack(); err != nil {
+            w.Engine.logger.Errorf("Failed to acknowledge task %s: %v", task.ID, err)
         }
     }
 }
 
+func (w *Worker) setBusy(busy bool) {
+    w.Engine.mu.Lock()
+    w.busy = busy
+    w.Engine.mu.Unlock()
+}
+
+func (w *Worker) setWaiting(waiting bool) {
+    w.Engine.mu.Lock()
+    w.waiting = waiting
+    w.Engine.mu.Unlock()
+}
+
+// processTask crawls a delivered task. A task redelivered after its worker
+// died is still running in storage and is skipped here; it is picked up
+// again once its lease is reclaimed.
 func (w *Worker) processTask(task *models.CrawlTask) {
+    if !w.Engine.queue.Remote() {
+        defer w.Engine.scheduler.done(task)
+    }
+
+    started, err := w.Engine.storage.StartTask(task.ID, task.LeaseID, w.ID, w.Engine.leaseDuration())
+    if err != nil {
+        w.Engine.logger.Errorf("Failed to start task %s: %v", task.ID, err)
+        return
+    }
+    if !started {
+        // The lease was reclaimed or the task finished while it sat in the queue
+        return
+    }
+    task.Attempts++
+
+    result, fetchErr := w.execute(task)
+    if result == nil {
+        // Stopped mid-task: hand the task back instead of waiting for its lease to expire
+        if err := w.Engine.storage.ReleaseTasks([]string{task.ID}); err != nil {
+            w.Engine.logger.Errorf("Failed to release task %s: %v", task.ID, err)
+        }
+        return
+    }
+
+    status, message := "done", ""
+    if result.Unchanged {
+        status = "unchanged"
+    } else if result.Skipped {
+        status, message = "skipped", result.SkipReason
+    } else if !result.Success {
+        status, message = "failed", result.Error
+
+        statusCode, retryAfter := 0, ""
+        if result.Data != nil {
+            statusCode, retryAfter = result.Data.StatusCode, result.Data.Headers["Retry-After"]
+        }
+        if retry, delay := w.Engine.retry.Decide(task.Attempts, statusCode, fetchErr, retryAfter); retry {
+            task.ScheduledAt = time.Now().Add(delay)
+            if err := w.Engine.storage.RetryTask(task.ID, task.ScheduledAt, message); err != nil {
+                w.Engine.logger.Errorf("Failed to reschedule task %s: %v", task.ID, err)
+            } else {
+                w.Engine.logger.Infof("Retrying %s in %s (attempt %d): %s", task.URL, delay.Round(time.Second), task.Attempts, message)
+            }
+            w.report(&taskOutcome{task: task, result: result, status: "retry"})
+            return
+        }
+        if w.Engine.retry.Retryable(statusCode, fetchErr) {
+            // Transient failure that ran out of attempts
+            status = "dead"
+        }
+    }
+    if err := w.Engine.storage.CompleteTask(task.ID, status, message); err != nil {
+        w.Engine.logger.Errorf("Failed to mark task %s as %s: %v", task.ID, status, err)
+    }
+
+    w.report(&taskOutcome{task: task, result: result, status: status})
+}
+
+// report hands an outcome to the result processor, unless the engine is
+// stopping and the processor may already be gone.
+func (w *Worker) report(outcome *taskOutcome) {
+    select {
+    case w.Engine.results <- outcome:
+    case <-w.ctx.Done():
+    }
+}
+
+// execute crawls a task and returns its result along with the error that
+// made the fetch fail, if any. The result is nil when the worker was
+// stopped before the fetch could start.
+func (w *Worker) execute(task *models.CrawlTask) (*models.CrawlResult, error) {
+    if reason, err := w.checkRobots(task); reason != "" || err != nil {
+        now := time.Now()
+        result := &models.CrawlResult{
+            TaskID:    task.ID,
+            SessionID: task.SessionID,
+            URL:       task.URL,
+            ParentURL: task.ParentURL,
+            Depth:     task.Depth,
+            Attempt:   task.Attempts,
+            WorkerID:  w.ID,
+            StartTime: now,
+            EndTime:   now,
+        }
+        if err != nil {
+            // The host stays off limits until its robots.txt can be read
+            result.Error = err.Error()
+            return result, err
+        }
+        result.Skipped = true
+        result.SkipReason = reason
+        return result, nil
+    }
+
+    // Wait for the host's politeness budget before touching it
+    w.setWaiting(true)
+    host, err := w.Engine.scheduler.acquire(w.ctx, task)
+    w.setWaiting(false)
+    if err != nil {
+        return nil, err
+    }
+    defer w.Engine.scheduler.release(host)
+
     w.Engine.stats.mu.Lock()
     w.Engine.stats.TotalRequests++
     w.Engine.stats.mu.Unlock()
 
     result := &models.CrawlResult{
         TaskID:    task.ID,
+        SessionID: task.SessionID,
         URL:       task.URL,
+        ParentURL: task.ParentURL,
+        Depth:     task.Depth,
+        Attempt:   task.Attempts,
         WorkerID:  w.ID,
         StartTime: time.Now(),
     }
@@ -150,21 +489,26 @@ func (w *Worker) processTask(task *models.CrawlTask) {
     proxy, err := w.Engine.proxyMgr.GetProxy(task.URL)
     if err != nil {
         result.Error = fmt.Sprintf("Failed to get proxy: %v", err)
-        w.Engine.results <- result
-        return
+        return result, err
     }
 
     // Get stealth profile
     profile, err := w.Engine.stealthEng.GenerateProfile(task.URL)
     if err != nil {
         result.Error = fmt.Sprintf("Failed to generate stealth profile: %v", err)
-        w.Engine.results <- result
-        return
+        return result, err
     }
 
     // Perform crawl
-    data, err := w.crawlURL(task.URL, proxy, profile)
-    if err != nil {
+    data, err := w.crawlURL(task, proxy, profile)
+    if err == nil && data.StatusCode >= 400 {
+        // Keep the response for inspection, but count the fetch as failed
+        result.Data = data
+        result.Error = fmt.Sprintf("HTTP %d %s", data.StatusCode, http.StatusText(data.StatusCode))
+        w.Engine.stats.mu.Lock()
+        w.Engine.stats.FailedCrawls++
+        w.Engine.stats.mu.Unlock()
+    } else if err != nil {
         result.Error = err.Error()
         w.Engine.stats.mu.Lock()
         w.Engine.stats.FailedCrawls++
@@ -172,27 +516,89 @@ func (w *Worker) processTask(task *models.CrawlTask) {
     } else {
         result.Data = data
         result.Success = true
+        if data.StatusCode == http.StatusNotModified {
+            result.Unchanged = true
+            result.PreviousTaskID, _ = data.Metadata["previous_task_id"].(string)
+        }
         w.Engine.stats.mu.Lock()
         w.Engine.stats.SuccessfulCrawls++
         w.Engine.stats.mu.Unlock()
+
+        w.Engine.expandFrontier(task, data.Links)
     }
 
     result.EndTime = time.Now()
     result.Duration = result.EndTime.Sub(result.StartTime)
 
-    w.Engine.results <- result
+    w.Engine.detectChange(result)
+
+    return result, err
 }
 
-func (w *Worker) crawlURL(url string, proxy *proxy.Proxy, profile *stealth.Profile) (*models.CrawlData, error) {
-    // Implementation will use stealth browser automation
-    // This is a simplified version - full implementation would use chromedp/puppeteer
-    
+// checkRobots returns a skip reason when the session respects robots.txt
+// and the task's URL is disallowed for our user agent. The error is set
+// when the host's robots.txt is unreachable, which the retry policy treats
+// as transient.
+func (w *Worker) checkRobots(task *models.CrawlTask) (string, error) {
+    state := w.Engine.getSession(task.SessionID)
+    if state == nil || !state.session.Rules.RespectRobotsTxt {
+        return "", nil
+    }
+
+    allowed, delay, err := w.Engine.robots.Allowed(task.URL)
+    var unreachable *robots.UnreachableError
+    if errors.As(err, &unreachable) {
+        return "", err
+    }
+    if err != nil {
+        return fmt.Sprintf("robots.txt check failed: %v", err), nil
+    }
+    if delay > 0 {
+        w.Engine.scheduler.setCrawlDelay(task.URL, delay)
+    }
+    if !allowed {
+        return "disallowed by robots.txt", nil
+    }
+    return "", nil
+}
+
+func (w *Worker) crawlURL(task *models.CrawlTask, proxy *proxy.Proxy, profile *stealth.Profile) (*models.CrawlData, error) {
+    url := task.URL
+
+    if state := w.Engine.getSession(task.SessionID); w.Engine.shouldRender(state, url) {
+        return w.renderURL(task, state, proxy, profile)
+    }
+
     client := w.Engine.stealthEng.CreateHTTPClient(proxy, profile)
-    
-    resp, err := client.Get(url)
+
+    req, err := http.NewRequest(http.MethodGet, url, nil)
     if err != nil {
         return nil, err
     }
+
+    // Make the fetch conditional on what the last download of the URL reported
+    previous, err := w.Engine.storage.GetURLValidators(url)
+    if err != nil {
+        w.Engine.logger.Errorf("Failed to load validators of %s: %v", url, err)
+    }
+    if previous != nil {
+        if previous.ETag != "" {
+            req.Header.Set("If-None-Match", previous.ETag)
+        }
+        if previous.LastModified != "" {
+            req.Header.Set("If-Modified-Since", previous.LastModified)
+        }
+    }
+
+    recorder := w.newFetchRecorder(task)
+    req = recorder.trace(req)
+    client.CheckRedirect = recorder.checkRedirect(client.CheckRedirect)
+
+    resp, err := client.Do(req)
+    if err != nil {
+        recorder.archive(nil, nil, false)
+        return nil, err
+    }
     defer resp.Body.Close()
 
     // Parse content
@@ -209,27 +615,480 @@ func (w *Worker) crawlURL(url string, proxy *proxy.Proxy, profile *stealth.Profi
         }
     }
 
-    // Read body (simplified - should handle content type parsing)
-    body := make([]byte, 1024*1024) // 1MB limit
-    n, _ := resp.Body.Read(body)
-    data.Content = string(body[:n])
+    if resp.StatusCode == http.StatusNotModified && previous != nil {
+        recorder.archive(nil, nil, false)
+        w.notModified(task, data, previous)
+        return data, nil
+    }
+
+    body, err := parser.ReadBody(resp, w.Engine.config.MaxBodySize)
+    if err != nil {
+        recorder.archive(nil, nil, false)
+        return nil, err
+    }
+    data.Metadata = map[string]interface{}{
+        "content_length": len(body.Content),
+        "raw_length":     len(body.Raw),
+        "truncated":      body.Truncated,
+    }
+    if body.Charset != "" {
+        data.Metadata["charset"] = body.Charset
+    }
+    if body.ContentEncoding != "" {
+        data.Metadata["content_encoding"] = body.ContentEncoding
+    }
+    if body.Truncated {
+        w.Engine.logger.Debugf("Body of %s truncated at %d bytes", url, w.Engine.config.MaxBodySize)
+    }
+
+    if resp.StatusCode == http.StatusOK {
+        w.saveValidators(task, resp, previous, int64(len(body.Raw)), data.Timestamp)
+    }
+
+    // The archive keeps the body exactly as transferred
+    recorder.archive(resp, body.Raw, body.Truncated)
 
+    // Resolve against the final URL so redirects don't break relative links
+    w.processBody(task, data, resp.Request.URL.String(), resp.Header.Get("Content-Type"), body.Content)
     return data, nil
 }
 
+// notModified fills in a 304 response from the snapshot it refers to. The
+// snapshot's links are reused so the frontier still grows past unchanged
+// pages.
+func (w *Worker) notModified(task *models.CrawlTask, data *models.CrawlData, previous *models.URLValidators) {
+    data.Metadata = map[string]interface{}{
+        "unchanged":        true,
+        "previous_task_id": previous.TaskID,
+        "bytes_saved":      previous.ContentLength,
+    }
+
+    snapshot, err := w.Engine.storage.GetCrawlResult(previous.TaskID)
+    if err != nil {
+        w.Engine.logger.Errorf("Failed to load previous snapshot of %s: %v", task.URL, err)
+        return
+    }
+    if snapshot != nil && snapshot.Data != nil {
+        data.Links = snapshot.Data.Links
+        data.Images = snapshot.Data.Images
+    }
+}
+
+// saveValidators remembers the ETag and Last-Modified of a full download.
+// A URL that stopped sending them has its old ones cleared.
+func (w *Worker) saveValidators(task *models.CrawlTask, resp *http.Response, previous *models.URLValidators, size int64, fetchedAt time.Time) {
+    etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+    if etag == "" && lastModified == "" && previous == nil {
+        return
+    }
+
+    err := w.Engine.storage.SaveURLValidators(&models.URLValidators{
+        URL:           task.URL,
+        ETag:          etag,
+        LastModified:  lastModified,
+        TaskID:        task.ID,
+        SessionID:     task.SessionID,
+        ContentLength: size,
+        FetchedAt:     fetchedAt,
+    })
+    if err != nil {
+        w.Engine.logger.Errorf("Failed to save validators of %s: %v", task.URL, err)
+    }
+}
+
+// processBody runs a decoded body through the processor registry and the
+// session's extraction schemas, filling in data.
+func (w *Worker) processBody(task *models.CrawlTask, data *models.CrawlData, finalURL, contentType string, body []byte) {
+    output, err := w.Engine.processors.Process(&processor.Input{
+        URL:         finalURL,
+        ContentType: contentType,
+        Body:        body,
+    })
+    if err != nil {
+        w.Engine.logger.Debugf("Failed to process %s: %v", task.URL, err)
+        if parser.IsText(contentType) {
+            data.Content = string(body)
+        }
+        return
+    }
+
+    data.Content = output.Content
+    data.Links = output.Links
+    data.Images = output.Images
+    for k, v := range output.Metadata {
+        data.Metadata[k] = v
+    }
+
+    mediaType, _ := data.Metadata["media_type"].(string)
+    if state := w.Engine.getSession(task.SessionID); state != nil && !state.extractor.Empty() && parser.IsHTML(mediaType) {
+        records, err := state.extractor.Extract(finalURL, body)
+        if err != nil {
+            w.Engine.logger.Debugf("Failed to extract from %s: %v", task.URL, err)
+        } else {
+            data.Metadata["extracted"] = records
+        }
+    }
+}
+
+// renderURL loads a page in the headless browser and processes the final
+// DOM as HTML.
+func (w *Worker) renderURL(task *models.CrawlTask, state *sessionState, proxy *proxy.Proxy, profile *stealth.Profile) (*models.CrawlData, error) {
+    rules := state.session.Rules.RenderOptions
+    opts := &render.Options{
+        WaitFor:        rules.WaitFor,
+        WaitSelector:   rules.WaitSelector,
+        WaitDelay:      time.Duration(rules.WaitDelay) * time.Millisecond,
+        Screenshot:     rules.Screenshot,
+        BlockResources: rules.BlockResources,
+    }
+    if proxy != nil {
+        opts.Proxy = renderProxy(proxy)
+    }
+    if profile != nil {
+        opts.UserAgent = profile.UserAgent
+        opts.Width, opts.Height = profile.Viewport.Width, profile.Viewport.Height
+    }
+
+    started := time.Now()
+    page, err := w.Engine.renderer.Render(w.ctx, task.URL, opts)
+    if err != nil {
+        return nil, err
+    }
+    w.archiveRendered(task, page, time.Since(started), time.Now())
+
+    data := &models.CrawlData{
+        URL:        task.URL,
+        StatusCode: page.StatusCode,
+        Headers:    page.Headers,
+        Timestamp:  time.Now(),
+        Metadata: map[string]interface{}{
+            "rendered":       true,
+            "final_url":      page.URL,
+            "content_length": len(page.HTML),
+        },
+    }
+    if data.Headers == nil {
+        data.Headers = make(map[string]string)
+    }
+    if len(page.ConsoleErrors) > 0 {
+        data.Metadata["console_errors"] = page.ConsoleErrors
+    }
+    if len(page.Screenshot) > 0 {
+        if id, err := w.Engine.storage.StoreBlob(task.URL, "image/png", page.Screenshot); err != nil {
+            w.Engine.logger.Errorf("Failed to store screenshot of %s: %v", task.URL, err)
+        } else {
+            data.Metadata["screenshot_blob_id"] = id
+        }
+    }
+
+    // The DOM is serialised as UTF-8 whatever the page declared
+    w.processBody(task, data, page.URL, "text/html; charset=utf-8", []byte(page.HTML))
+    return data, nil
+}
+
+// renderProxy returns the proxy URL for a render tab, keeping the
+// credentials the browser pool answers the proxy's challenges with.
+func renderProxy(p *proxy.Proxy) string {
+    server := &url.URL{Scheme: "http", Host: net.JoinHostPort(p.Host, strconv.Itoa(p.Port))}
+    if p.Username != "" {
+        server.User = url.UserPassword(p.Username, p.Password)
+    }
+    return server.String()
+}
+
+// shouldRender reports whether a URL of the session goes through the
+// headless browser.
+func (e *CrawlerEngine) shouldRender(state *sessionState, url string) bool {
+    return e.renderer != nil && e.wantsRender(state, url)
+}
+
+// wantsRender reports whether the session's rules ask for a URL to be
+// rendered, whether or not this node can.
+func (e *CrawlerEngine) wantsRender(state *sessionState, url string) bool {
+    if state == nil {
+        return false
+    }
+    if state.session.Rules.Render {
+        return true
+    }
+    for _, pattern := range state.renderPatterns {
+        if pattern.MatchString(url) {
+            return true
+        }
+    }
+    return false
+}
+
+// Processors returns the registry that turns response bodies into crawl
+// data, so callers can register processors for further media types.
+func (e *CrawlerEngine) Processors() *processor.Registry {
+    return e.processors
+}
+
+// newURLFilter compiles the scoping rules of a session.
+func newURLFilter(rules models.CrawlRules) (*urlfilter.Filter, error) {
+    return urlfilter.New(urlfilter.Config{
+        AllowedDomains:  rules.AllowedDomains,
+        BlockedDomains:  rules.BlockedDomains,
+        IncludePatterns: rules.URLPatterns,
+        ExcludePatterns: rules.ExcludePatterns,
+    })
+}
+
+func newSessionState(session *models.CrawlSession) (*sessionState, error) {
+    filter, err := newURLFilter(session.Rules)
+    if err != nil {
+        return nil, err
+    }
+    extractor, err := extract.New(session.Schemas)
+    if err != nil {
+        return nil, err
+    }
+    var renderPatterns []*regexp.Regexp
+    for _, pattern := range session.Rules.RenderOptions.URLPatterns {
+        re, err := regexp.Compile(pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid render pattern %q: %v", pattern, err)
+        }
+        renderPatterns = append(renderPatterns, re)
+    }
+    if err := browserpool.ValidateResources(session.Rules.RenderOptions.BlockResources); err != nil {
+        return nil, err
+    }
+
+    // Resume counting from the last flushed stats of a known session
+    return &sessionState{
+        session:        session,
+        filter:         filter,
+        extractor:      extractor,
+        renderPatterns: renderPatterns,
+        base:           session.Stats,
+        statusAt:       time.Now(),
+    }, nil
+}
+
+// RegisterSession makes a session's rules available to the workers and
+// persists its in-scope start URLs as depth-0 tasks. Sessions seeded from
+// sitemaps start once the sitemaps have been read in the background.
+func (e *CrawlerEngine) RegisterSession(session *models.CrawlSession) error {
+    state, err := newSessionState(session)
+    if err != nil {
+        return err
+    }
+
+    e.mu.Lock()
+    e.sessions[session.ID] = state
+    e.mu.Unlock()
+
+    var tasks []*models.CrawlTask
+    for _, rawURL := range session.StartURLs {
+        url, ok := e.admit(state, rawURL)
+        if !ok {
+            continue
+        }
+        tasks = append(tasks, &models.CrawlTask{
+            ID:          uuid.New().String(),
+            SessionID:   session.ID,
+            URL:         url,
+            Method:      "GET",
+            Priority:    5,
+            MaxDepth:    session.Rules.MaxDepth,
+            Depth:       0,
+            CreatedAt:   time.Now(),
+            ScheduledAt: time.Now(),
+            Status:      "pending",
+        })
+    }
+
+    if _, err := e.enqueue(state, tasks); err != nil {
+        return fmt.Errorf("failed to create seed tasks: %v", err)
+    }
+
+    if session.Rules.Sitemaps.Enabled {
+        go e.seedFromSitemaps(state)
+        return nil
+    }
+
+    now := time.Now()
+    if err := e.storage.UpdateSessionStatus(session.ID, "running", &now, nil); err != nil {
+        return fmt.Errorf("failed to start session: %v", err)
+    }
+    state.setStatus("running", &now, nil)
+    return nil
+}
+
+func (e *CrawlerEngine) getSession(sessionID string) *sessionState {
+    e.mu.RLock()
+    state, exists := e.sessions[sessionID]
+    e.mu.RUnlock()
+    if exists {
+        return state
+    }
+
+    session, err := e.storage.GetCrawlSession(sessionID)
+    if err != nil {
+        e.logger.Errorf("Failed to load session %s: %v", sessionID, err)
+        return nil
+    }
+
+    state, err = newSessionState(session)
+    if err != nil {
+        e.logger.Errorf("Invalid rules for session %s: %v", sessionID, err)
+        return nil
+    }
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if existing, exists := e.sessions[sessionID]; exists {
+        return existing
+    }
+    e.sessions[sessionID] = state
+    return state
+}
+
+func (e *CrawlerEngine) inScope(state *sessionState, url string) bool {
+    allowed, reason := state.filter.Allow(url)
+    if !allowed {
+        e.logger.Debugf("Skipping %s for session %s: %s", url, state.session.ID, reason)
+    }
+    return allowed
+}
+
+// admit canonicalizes a URL and decides whether it may become a new task
+// of the session: it must be in scope and not queued before.
+func (e *CrawlerEngine) admit(state *sessionState, rawURL string) (string, bool) {
+    if !e.accepting(state) {
+        return "", false
+    }
+    url, err := e.normalizer.Normalize(rawURL)
+    if err != nil {
+        e.logger.Debugf("Skipping invalid URL %s: %v", rawURL, err)
+        return "", false
+    }
+    if !e.inScope(state, url) {
+        return "", false
+    }
+
+    isNew, err := e.seen.MarkSeen(state.session.ID, url)
+    if err != nil {
+        // Prefer crawling a page twice over losing it
+        e.logger.Errorf("Failed to check seen URLs for session %s: %v", state.session.ID, err)
+        return url, true
+    }
+    if !isNew {
+        state.mu.Lock()
+        state.stats.DuplicateURLs++
+        state.dirty = true
+        state.mu.Unlock()
+        return "", false
+    }
+
+    return url, true
+}
+
+// unmark lets the URLs of tasks that did not make it into storage be found
+// again.
+func (e *CrawlerEngine) unmark(tasks, created []*models.CrawlTask) {
+    written := make(map[string]bool, len(created))
+    for _, task := range created {
+        written[task.ID] = true
+    }
+    for _, task := range tasks {
+        if written[task.ID] {
+            continue
+        }
+        if err := e.seen.Unmark(task.SessionID, task.URL); err != nil {
+            e.logger.Errorf("Failed to unmark %s for session %s: %v", task.URL, task.SessionID, err)
+        }
+    }
+}
+
+// expandFrontier turns the links discovered on a page into child tasks,
+// bounded by the session's MaxDepth and MaxPages rules.
+func (e *CrawlerEngine) expandFrontier(parent *models.CrawlTask, links []string) {
+    if len(links) == 0 || parent.Depth >= parent.MaxDepth {
+        return
+    }
+
+    state := e.getSession(parent.SessionID)
+    if state == nil {
+        return
+    }
+
+    var tasks []*models.CrawlTask
+    for _, rawURL := range links {
+        link, ok := e.admit(state, rawURL)
+        if !ok {
+            continue
+        }
+
+        tasks = append(tasks, &models.CrawlTask{
+            ID:          uuid.New().String(),
+            SessionID:   parent.SessionID,
+            URL:         link,
+            ParentURL:   parent.URL,
+            Method:      "GET",
+            Priority:    parent.Priority,
+            MaxDepth:    parent.MaxDepth,
+            Depth:       parent.Depth + 1,
+            CreatedAt:   time.Now(),
+            ScheduledAt: time.Now(),
+            Status:      "pending",
+        })
+    }
+
+    if _, err := e.enqueue(state, tasks); err != nil {
+        e.logger.Errorf("Failed to create tasks discovered on %s: %v", parent.URL, err)
+    }
+}
+
+// enqueue is the single path by which tasks enter the frontier: they are
+// written to storage, which trims them to the session's remaining MaxPages
+// budget, and later leased by the scheduler. It returns how many were
+// queued.
+func (e *CrawlerEngine) enqueue(state *sessionState, tasks []*models.CrawlTask) (int, error) {
+    if !e.accepting(state) || len(tasks) == 0 {
+        return 0, nil
+    }
+    for _, task := range tasks {
+        task.Host = e.scheduler.hostKey(task.URL)
+    }
+
+    created, err := e.storage.CreateTasks(tasks)
+    if err != nil {
+        e.unmark(tasks, nil)
+        return 0, err
+    }
+    if len(created) < len(tasks) {
+        e.unmark(tasks, created)
+    }
+
+    state.mu.Lock()
+    state.stats.TotalTasks += len(created)
+    state.stats.PendingTasks += len(created)
+    state.dirty = true
+    state.mu.Unlock()
+    return len(created), nil
+}
+
 func (e *CrawlerEngine) processResults(ctx context.Context) {
     for {
         select {
         case <-ctx.Done():
             return
-        case result := <-e.results:
+        case outcome := <-e.results:
+            result := outcome.result
+
             // Store result
             if err := e.storage.StoreCrawlResult(result); err != nil {
                 e.logger.Errorf("Failed to store crawl result: %v", err)
             }
+            e.recordOutcome(outcome)
 
             // Update metrics based on result
-            if result.Error != "" {
+            if result.Skipped {
+                e.logger.Infof("Skipped %s: %s", result.URL, result.SkipReason)
+            } else if result.Error != "" {
                 e.logger.Warnf("Crawl failed for %s: %s", result.URL, result.Error)
             } else {
                 e.logger.Debugf("Successfully crawled %s", result.URL)
@@ -242,74 +1101,181 @@ func (s *Scheduler) run(ctx context.Context) {
     ticker := time.NewTicker(1 * time.Second)
     defer ticker.Stop()
 
+    reclaim := time.NewTicker(s.engine.leaseDuration() / 4)
+    defer reclaim.Stop()
+
+    completion := time.NewTicker(5 * time.Second)
+    defer completion.Stop()
+
     for {
         select {
         case <-ctx.Done():
             return
         case <-ticker.C:
             s.scheduleNextTasks()
+        case <-reclaim.C:
+            s.reclaimExpiredLeases()
+        case <-completion.C:
+            s.engine.completeFinishedSessions()
         }
     }
 }
 
+// scheduleNextTasks tops up the frontier with leased tasks, fairly across
+// sessions and hosts, leaving out hosts that already have enough queued.
 func (s *Scheduler) scheduleNextTasks() {
-    // Get pending tasks from storage
-    tasks, err := s.engine.storage.GetPendingTasks(100)
+    config := s.engine.config.Frontier
+    limit := config.Capacity - s.frontier.Len()
+    if limit > 500 {
+        limit = 500
+    }
+    if limit <= 0 {
+        return
+    }
+
+    tasks, err := s.engine.storage.LeaseTasks(s.engine.nodeID, limit, config.PerHost,
+        s.frontier.FullHosts(config.PerHost), s.engine.leaseDuration())
     if err != nil {
-        s.engine.logger.Errorf("Failed to get pending tasks: %v", err)
+        s.engine.logger.Errorf("Failed to lease pending tasks: %v", err)
         return
     }
 
     for _, task := range tasks {
-        // Check domain rate limits
-        if s.canScheduleTask(task) {
+        state := s.engine.getSession(task.SessionID)
+        if state == nil || !s.engine.inScope(state, task.URL) {
+            if err := s.engine.storage.CompleteTask(task.ID, "skipped", "out of scope"); err != nil {
+                s.engine.logger.Errorf("Failed to skip task %s: %v", task.ID, err)
+            }
+            continue
+        }
+
+        s.frontier.Push(task, s.hostKey(task.URL), state.session.Rules.Weight)
+    }
+}
+
+// dispatch feeds the worker queue from the frontier, sleeping until the
+// next host is due or the frontier changes. Workers in other processes
+// cannot report back, so for a shared queue a host is charged its request
+// when the task is published, and its frontier slot freed right away.
+func (s *Scheduler) dispatch(ctx context.Context) {
+    idle := time.NewTimer(time.Second)
+    defer idle.Stop()
+
+    remote := s.engine.queue.Remote()
+    for {
+        if task, host, ok := s.frontier.Pop(time.Now()); ok {
+            if remote {
+                s.limiter.Reserve(host)
+            }
+            err := s.engine.queue.Publish(ctx, task)
+            if err != nil && ctx.Err() == nil {
+                s.engine.logger.Errorf("Failed to publish task %s: %v", task.ID, err)
+                if err := s.engine.storage.ReleaseTasks([]string{task.ID}); err != nil {
+                    s.engine.logger.Errorf("Failed to release task %s: %v", task.ID, err)
+                }
+            }
+            if remote || err != nil {
+                s.frontier.Done(host)
+            }
+            if ctx.Err() != nil {
+                return
+            }
+            continue
+        }
+
+        wait := time.Second
+        if next, ok := s.frontier.NextReady(); ok {
+            if until := time.Until(next); until < wait {
+                wait = until
+            }
+        }
+        if wait < time.Millisecond {
+            wait = time.Millisecond
+        }
+        if !idle.Stop() {
             select {
-            case s.engine.queue <- task:
-                s.updateDomainState(task.URL)
+            case <-idle.C:
             default:
-                // Queue full, skip for now
-                break
             }
         }
+        idle.Reset(wait)
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-s.frontier.Wake():
+        case <-idle.C:
+        }
     }
 }
 
-func (s *Scheduler) canScheduleTask(task *models.CrawlTask) bool {
-    s.mu.RLock()
-    defer s.mu.RUnlock()
+// done frees the frontier slot a dispatched task held on its host.
+func (s *Scheduler) done(task *models.CrawlTask) {
+    s.frontier.Done(s.hostKey(task.URL))
+}
 
-    domain := extractDomain(task.URL)
-    state, exists := s.domains[domain]
-    
-    if !exists {
-        return true
+// dropSession discards the frontier's tasks of a session that stopped
+// being scheduled; their leases are handled by the caller.
+func (s *Scheduler) dropSession(sessionID string) {
+    if ids := s.frontier.RemoveSession(sessionID); len(ids) > 0 {
+        s.engine.logger.Debugf("Dropped %d queued tasks of session %s", len(ids), sessionID)
     }
+}
 
-    if state.Blocked {
-        return false
+// reclaimExpiredLeases returns tasks whose lease ran out, typically
+// because the worker holding them crashed, to the pending state.
+func (s *Scheduler) reclaimExpiredLeases() {
+    reclaimed, err := s.engine.storage.ReclaimExpiredLeases()
+    if err != nil {
+        s.engine.logger.Errorf("Failed to reclaim expired leases: %v", err)
+        return
     }
+    if reclaimed > 0 {
+        s.engine.logger.Warnf("Reclaimed %d tasks with expired leases", reclaimed)
+    }
+}
 
-    // Check rate limiting
-    if time.Since(state.LastRequest) < time.Duration(s.engine.config.RateLimit)*time.Millisecond {
-        return false
+// readyAt returns when a host may next be fetched for a session, under
+// the global rate limit, robots.txt Crawl-delay and the session's delay.
+func (s *Scheduler) readyAt(host, sessionID string) time.Time {
+    return s.limiter.ReadyAt(host, sessionDelay(s.engine.getSession(sessionID)))
+}
+
+func (s *Scheduler) setCrawlDelay(url string, delay time.Duration) {
+    s.limiter.SetMinDelay(s.hostKey(url), delay)
+}
+
+// acquire blocks until the task's host may be fetched under the global
+// rate limit, per-host concurrency, robots.txt Crawl-delay and the
+// session's own delay. The returned key must be passed to release.
+func (s *Scheduler) acquire(ctx context.Context, task *models.CrawlTask) (string, error) {
+    domain := s.hostKey(task.URL)
+    if err := s.limiter.Wait(ctx, domain, sessionDelay(s.engine.getSession(task.SessionID))); err != nil {
+        return "", err
     }
+    return domain, nil
+}
 
-    return true
+func (s *Scheduler) release(domain string) {
+    s.limiter.Release(domain)
 }
 
-func (s *Scheduler) updateDomainState(url string) {
-    s.mu.Lock()
-    defer s.mu.Unlock()
+// hostKey returns the key politeness is tracked under: the host by
+// default, or the registrable domain when configured to group subdomains.
+func (s *Scheduler) hostKey(url string) string {
+    if s.engine.config.PolitenessKey == "domain" {
+        return urlnorm.RegistrableDomain(url)
+    }
+    return extractDomain(url)
+}
 
-    domain := extractDomain(url)
-    state := s.domains[domain]
+// sessionDelay returns the per-host delay configured by a session's
+// rules, in milliseconds.
+func sessionDelay(state *sessionState) time.Duration {
     if state == nil {
-        state = &DomainState{}
-        s.domains[domain] = state
+        return 0
     }
-
-    state.LastRequest = time.Now()
-    state.RequestRate++
+    return time.Duration(state.session.Rules.Delay) * time.Millisecond
 }
 
 func (e *CrawlerEngine) Stop() {
@@ -327,29 +1293,74 @@ func (e *CrawlerEngine) Stop() {
         worker.cancel()
     }
 
+    if e.browsers != nil {
+        e.browsers.Close()
+    }
+    e.queue.Close()
+
+    if e.cluster != nil {
+        if err := e.cluster.Leave(context.Background(), e.nodeID); err != nil {
+            e.logger.Errorf("Failed to leave the cluster: %v", err)
+        }
+    }
+
     e.logger.Info("Crawler engine stopped")
 }
 
+// BrowserPoolStats reports on the browser pool; ok is false when rendering
+// is disabled.
+func (e *CrawlerEngine) BrowserPoolStats() (browserpool.Stats, bool) {
+    if e.browsers == nil {
+        return browserpool.Stats{}, false
+    }
+    return e.browsers.Stats(), true
+}
+
 func (e *CrawlerEngine) GetStats() *CrawlStats {
+    pool := e.WorkerPoolStats()
+    stats := &CrawlStats{
+        ActiveWorkers:  pool.Active,
+        BusyWorkers:    pool.Busy,
+        WaitingWorkers: pool.Waiting,
+        QueueSize:      e.queue.Len(),
+        FrontierSize:   e.scheduler.frontier.Len(),
+    }
+
     e.stats.mu.RLock()
     defer e.stats.mu.RUnlock()
 
-    // Update active workers count
-    e.stats.ActiveWorkers = len(e.workers)
-    e.stats.QueueSize = len(e.queue)
+    stats.TotalRequests = e.stats.TotalRequests
+    stats.SuccessfulCrawls = e.stats.SuccessfulCrawls
+    stats.FailedCrawls = e.stats.FailedCrawls
+    stats.DetectionEvents = e.stats.DetectionEvents
+    return stats
+}
+
+// minLeaseDuration keeps leases from expiring before a fetch can finish.
+const minLeaseDuration = 30 * time.Second
 
-    return &CrawlStats{
-        TotalRequests:    e.stats.TotalRequests,
-        SuccessfulCrawls: e.stats.SuccessfulCrawls,
-        FailedCrawls:     e.stats.FailedCrawls,
-        DetectionEvents:  e.stats.DetectionEvents,
-        ActiveWorkers:    e.stats.ActiveWorkers,
-        QueueSize:        e.stats.QueueSize,
+// leaseDuration is how long a leased task may go without being finished
+// before it is handed to another worker. Unset, it defaults to five
+// minutes.
+func (e *CrawlerEngine) leaseDuration() time.Duration {
+    lease := time.Duration(e.config.LeaseTimeout) * time.Second
+    if lease <= 0 {
+        return 5 * time.Minute
+    }
+    if lease < minLeaseDuration {
+        return minLeaseDuration
+    }
+    return lease
+}
+
+func defaultNodeID() string {
+    hostname, err := os.Hostname()
+    if err != nil {
+        hostname = "crawler"
     }
+    return fmt.Sprintf("%s-%d", hostname, os.Getpid())
 }
 
 func extractDomain(url string) string {
-    // Simplified domain extraction
-    // Full implementation would use net/url package
-    return url
+    return urlnorm.Host(url)
 }
@@ -14,4 +14,9 @@ require (
     github.com/PuerkitoBio/goquery v1.8.1
     github.com/sirupsen/logrus v1.9.3
     gopkg.in/yaml.v2 v2.4.0
+    golang.org/x/net v0.10.0
+    github.com/andybalholm/brotli v1.0.5
+    github.com/andybalholm/cascadia v1.3.1
+    github.com/antchfx/htmlquery v1.3.0
+    github.com/antchfx/xpath v1.2.3
 )
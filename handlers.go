@@ -2,11 +2,18 @@
 package main
 
 import (
+    "database/sql"
+    "errors"
+    "io"
     "net/http"
+    "os"
     "strconv"
+    "strings"
     "time"
 
     "crawler666/internal/models"
+    "crawler666/pkg/export"
+    "crawler666/pkg/storage"
 
     "github.com/gin-gonic/gin"
     "github.com/google/uuid"
@@ -18,6 +25,7 @@ func (app *CrawlerApp) startCrawl(c *gin.Context) {
         Description string   `json:"description"`
         StartURLs   []string `json:"start_urls" binding:"required"`
         Rules       models.CrawlRules `json:"rules"`
+        Schemas     []models.ExtractionSchema `json:"schemas"`
     }
 
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -32,9 +40,16 @@ func (app *CrawlerApp) startCrawl(c *gin.Context) {
         Description: req.Description,
         StartURLs:   req.StartURLs,
         Rules:       req.Rules,
-        Status:      "active",
+        Status:      "pending",
         CreatedAt:   time.Now(),
         Stats:       models.SessionStats{},
+        Schemas:     req.Schemas,
+    }
+
+    // Reject rules, schemas and render patterns that won't compile
+    if _, err := newSessionState(session); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
     }
 
     if err := app.Storage.CreateCrawlSession(session); err != nil {
@@ -42,26 +57,10 @@ func (app *CrawlerApp) startCrawl(c *gin.Context) {
         return
     }
 
-    // Create initial tasks
-    for _, url := range req.StartURLs {
-        task := &models.CrawlTask{
-            ID:          uuid.New().String(),
-            SessionID:   session.ID,
-            URL:         url,
-            Method:      "GET",
-            Priority:    5,
-            MaxDepth:    req.Rules.MaxDepth,
-            CreatedAt:   time.Now(),
-            ScheduledAt: time.Now(),
-            Status:      "pending",
-        }
-        
-        // Add task to queue (simplified - would use proper task creation)
-        select {
-        case app.Engine.queue <- task:
-        default:
-            app.Logger.Warn("Queue full, task will be scheduled later")
-        }
+    // Seed the frontier with the start URLs
+    if err := app.Engine.RegisterSession(session); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+        return
     }
 
     c.JSON(http.StatusCreated, session)
@@ -78,6 +77,9 @@ func (app *CrawlerApp) getCrawlStatus(c *gin.Context) {
 
     for _, session := range sessions {
         if session.ID == sessionID {
+            if stats, ok := app.Engine.SessionStats(sessionID); ok {
+                session.Stats = stats
+            }
             c.JSON(http.StatusOK, session)
             return
         }
@@ -87,11 +89,79 @@ func (app *CrawlerApp) getCrawlStatus(c *gin.Context) {
 }
 
 func (app *CrawlerApp) stopCrawl(c *gin.Context) {
-    sessionID := c.Param("id")
-    
-    // Implementation would stop all tasks for this session
-    // For now, return success
-    c.JSON(http.StatusOK, gin.H{"message": "Crawl stopped", "session_id": sessionID})
+    session, err := app.Engine.StopSession(c.Param("id"))
+    app.respondSessionTransition(c, session, err, "Crawl stopped")
+}
+
+func (app *CrawlerApp) pauseCrawl(c *gin.Context) {
+    session, err := app.Engine.PauseSession(c.Param("id"))
+    app.respondSessionTransition(c, session, err, "Crawl paused")
+}
+
+func (app *CrawlerApp) resumeCrawl(c *gin.Context) {
+    session, err := app.Engine.ResumeSession(c.Param("id"))
+    app.respondSessionTransition(c, session, err, "Crawl resumed")
+}
+
+func (app *CrawlerApp) respondSessionTransition(c *gin.Context, session *models.CrawlSession, err error, message string) {
+    switch {
+    case errors.Is(err, ErrSessionNotFound):
+        c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+    case errors.Is(err, ErrInvalidSessionState):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case err != nil:
+        app.Logger.Errorf("Session transition failed: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update session"})
+    default:
+        c.JSON(http.StatusOK, gin.H{"message": message, "session": session})
+    }
+}
+
+func (app *CrawlerApp) listDeadLetters(c *gin.Context) {
+    sessionID := c.Query("session_id")
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+    if err != nil {
+        limit = 100
+    }
+
+    tasks, err := app.Storage.GetDeadLetterTasks(sessionID, limit)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dead-letter tasks"})
+        return
+    }
+
+    c.JSON(http.StatusOK, tasks)
+}
+
+// listChanges reports how each URL of a session changed since it was last
+// crawled. change filters to new, changed, unchanged or gone; since names
+// an earlier session whose URLs this one no longer reached.
+func (app *CrawlerApp) listChanges(c *gin.Context) {
+    changes, err := app.Engine.SessionChanges(c.Request.Context(), c.Param("id"), c.Query("since"), c.Query("change"))
+    if err != nil {
+        app.Logger.Errorf("Failed to list changes of session %s: %v", c.Param("id"), err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list changes"})
+        return
+    }
+
+    c.JSON(http.StatusOK, changes)
+}
+
+// diffSnapshot diffs the text of a result against an earlier snapshot of
+// the same URL, by default the one it was classified against.
+func (app *CrawlerApp) diffSnapshot(c *gin.Context) {
+    result, err := app.Engine.DiffSnapshots(c.Param("taskId"), c.Query("from"))
+    switch {
+    case errors.Is(err, ErrSnapshotNotFound):
+        c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+    case errors.Is(err, ErrNoPreviousSnapshot), errors.Is(err, ErrSnapshotMismatch):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case err != nil:
+        app.Logger.Errorf("Failed to diff snapshots: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to diff snapshots"})
+    default:
+        c.JSON(http.StatusOK, result)
+    }
 }
 
 func (app *CrawlerApp) listCrawls(c *gin.Context) {
@@ -104,6 +174,82 @@ func (app *CrawlerApp) listCrawls(c *gin.Context) {
     c.JSON(http.StatusOK, sessions)
 }
 
+// listNodes lists the crawler nodes with their capacity and load, summed
+// up over the cluster.
+func (app *CrawlerApp) listNodes(c *gin.Context) {
+    nodes, err := app.Engine.Nodes(c.Request.Context())
+    if err != nil {
+        app.Logger.Errorf("Failed to list nodes: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list nodes"})
+        return
+    }
+
+    workers, busy := 0, 0
+    for _, node := range nodes {
+        workers += node.Workers
+        busy += node.Busy
+    }
+    load := 0.0
+    if workers > 0 {
+        load = float64(busy) / float64(workers)
+    }
+
+    c.JSON(http.StatusOK, gin.H{
+        "nodes":   nodes,
+        "workers": workers,
+        "busy":    busy,
+        "load":    load,
+    })
+}
+
+func (app *CrawlerApp) listWorkers(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{
+        "workers": app.Engine.Workers(),
+        "pool":    app.Engine.WorkerPoolStats(),
+    })
+}
+
+// scaleWorkers resizes the worker pool to count active workers; surplus
+// workers finish their current task before they exit.
+func (app *CrawlerApp) scaleWorkers(c *gin.Context) {
+    var req struct {
+        Count *int `json:"count" binding:"required"`
+    }
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    pool, err := app.Engine.ScaleWorkers(*req.Count)
+    switch {
+    case errors.Is(err, ErrInvalidPoolSize):
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+    case errors.Is(err, ErrNoWorkerPool):
+        c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+    case err != nil:
+        app.Logger.Errorf("Failed to scale workers: %v", err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scale workers"})
+    default:
+        c.JSON(http.StatusOK, pool)
+    }
+}
+
+// drainWorker lets a worker finish its current task, then stops it.
+// Worker IDs contain a slash, hence the wildcard route.
+func (app *CrawlerApp) drainWorker(c *gin.Context) {
+    id := strings.TrimPrefix(c.Param("id"), "/")
+    if err := app.Engine.DrainWorker(id); err != nil {
+        if errors.Is(err, ErrWorkerNotFound) {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Worker not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to drain worker"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"message": "Worker draining"})
+}
+
 func (app *CrawlerApp) getConfig(c *gin.Context) {
     c.JSON(http.StatusOK, app.Config)
 }
@@ -129,6 +275,9 @@ func (app *CrawlerApp) getStats(c *gin.Context) {
         "proxies": proxyStats,
         "timestamp": time.Now(),
     }
+    if poolStats, ok := app.Engine.BrowserPoolStats(); ok {
+        response["browser_pool"] = poolStats
+    }
 
     c.JSON(http.StatusOK, response)
 }
@@ -184,22 +333,110 @@ func (app *CrawlerApp) testProxy(c *gin.Context) {
     c.JSON(http.StatusOK, result)
 }
 
+// exportData streams a session's results. format is json, ndjson or csv,
+// with a ".gz" suffix for gzip, or warc for the session's WARC archive;
+// columns selects CSV columns. Every record
+// carries a cursor, and the cursor of the last record sent is returned in
+// the X-Export-Cursor trailer; passing either back as cursor resumes the
+// export after that record.
 func (app *CrawlerApp) exportData(c *gin.Context) {
     crawlID := c.Param("crawlId")
-    limitStr := c.DefaultQuery("limit", "1000")
-    
-    limit, err := strconv.Atoi(limitStr)
+    format := c.DefaultQuery("format", "json")
+    after := c.Query("cursor")
+
+    if format == "warc" {
+        app.exportWARC(c, crawlID)
+        return
+    }
+
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+    if err != nil || limit < 0 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+        return
+    }
+
+    var columns []string
+    if cols := c.Query("columns"); cols != "" {
+        columns = strings.Split(cols, ",")
+    }
+
+    writer, err := export.NewWriter(c.Writer, &export.Config{Format: format, Columns: columns})
     if err != nil {
-        limit = 1000
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+
+    contentType, ext := export.ContentType(format)
+    c.Header("Content-Type", contentType)
+    c.Header("Content-Disposition", "attachment; filename=crawl_"+crawlID+"."+ext)
+    c.Header("Trailer", "X-Export-Cursor")
+    c.Status(http.StatusOK)
+
+    last, err := app.Storage.StreamCrawlResults(c.Request.Context(), crawlID, after, limit,
+        func(cursor string, result *models.CrawlResult) error {
+            return writer.Write(&export.Record{Cursor: cursor, CrawlResult: result})
+        })
+    if err != nil && !c.Writer.Written() {
+        header := c.Writer.Header()
+        header.Del("Content-Disposition")
+        header.Del("Content-Type")
+        header.Del("Trailer")
+        if errors.Is(err, storage.ErrInvalidCursor) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export results"})
+        return
+    }
+    if err != nil {
+        // Headers are already sent; the client resumes from the trailer
+        app.Logger.Errorf("Export of session %s interrupted: %v", crawlID, err)
+    }
+    if err := writer.Close(); err != nil {
+        app.Logger.Errorf("Failed to finish export of session %s: %v", crawlID, err)
+    }
+
+    c.Writer.Header().Set("X-Export-Cursor", last)
+}
+
+// exportWARC streams the session's WARC files back to back. Each record is
+// its own gzip member, so the concatenation is itself a valid .warc.gz.
+func (app *CrawlerApp) exportWARC(c *gin.Context, crawlID string) {
+    if _, err := app.Storage.GetCrawlSession(crawlID); err != nil {
+        if err == sql.ErrNoRows {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get session"})
+        return
     }
 
-    results, err := app.Storage.GetCrawlResults(crawlID, limit)
+    files, err := app.Storage.SessionArchives(crawlID)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get results"})
+        app.Logger.Errorf("Failed to list WARC files of session %s: %v", crawlID, err)
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read WARC archive"})
+        return
+    }
+    if len(files) == 0 {
+        c.JSON(http.StatusNotFound, gin.H{"error": "No WARC archive for this session"})
         return
     }
 
-    c.Header("Content-Type", "application/json")
-    c.Header("Content-Disposition", "attachment; filename=crawl_"+crawlID+".json")
-    c.JSON(http.StatusOK, results)
+    c.Header("Content-Type", "application/warc")
+    c.Header("Content-Disposition", "attachment; filename=crawl_"+crawlID+".warc.gz")
+    c.Status(http.StatusOK)
+
+    for _, file := range files {
+        f, err := os.Open(file.Path)
+        if err != nil {
+            app.Logger.Errorf("Failed to open %s: %v", file.Path, err)
+            return
+        }
+        _, err = io.Copy(c.Writer, io.LimitReader(f, file.Size))
+        f.Close()
+        if err != nil {
+            app.Logger.Errorf("WARC export of session %s interrupted: %v", crawlID, err)
+            return
+        }
+    }
 }
@@ -11,7 +11,9 @@ import (
     "time"
 
     "crawler666/internal/models"
+    "crawler666/pkg/cluster"
     "crawler666/pkg/proxy"
+    "crawler666/pkg/queue"
     "crawler666/pkg/stealth"
     "crawler666/pkg/storage"
 
@@ -57,8 +59,55 @@ func main() {
         log.Fatalf("Failed to initialize stealth engine: %v", err)
     }
 
+    // Initialize task queue
+    prefetch := config.Crawler.Queue.Prefetch
+    if prefetch <= 0 {
+        prefetch = config.Crawler.MaxWorkers
+    }
+    taskQueue, err := queue.New(&queue.Config{
+        Backend:           config.Crawler.Queue.Backend,
+        Name:              config.Crawler.Queue.Name,
+        Capacity:          config.Crawler.QueueSize,
+        Consumer:          defaultNodeID(),
+        Prefetch:          prefetch,
+        VisibilityTimeout: time.Duration(config.Crawler.Queue.VisibilityTimeout) * time.Second,
+        AMQPURL:           config.Crawler.Queue.AMQPURL,
+        Redis: queue.RedisConfig{
+            Host:     config.Storage.Redis.Host,
+            Port:     config.Storage.Redis.Port,
+            Password: config.Storage.Redis.Password,
+            DB:       config.Storage.Redis.DB,
+        },
+    })
+    if err != nil {
+        log.Fatalf("Failed to initialize task queue: %v", err)
+    }
+    if config.Crawler.Role != "standalone" && config.Crawler.Mode != "distributed" {
+        log.Fatalf("Role %q needs distributed mode", config.Crawler.Role)
+    }
+
+    // Join the cluster in distributed mode
+    var coordinator *cluster.Coordinator
+    if config.Crawler.Mode == "distributed" {
+        if !taskQueue.Remote() {
+            log.Fatalf("Distributed mode needs a queue shared between processes, not %q", config.Crawler.Queue.Backend)
+        }
+        coordinator, err = cluster.NewCoordinator(&cluster.Config{
+            Redis: cluster.RedisConfig{
+                Host:     config.Storage.Redis.Host,
+                Port:     config.Storage.Redis.Port,
+                Password: config.Storage.Redis.Password,
+                DB:       config.Storage.Redis.DB,
+            },
+            NodeTTL: time.Duration(config.Crawler.Cluster.NodeTTL) * time.Second,
+        })
+        if err != nil {
+            log.Fatalf("Failed to connect to the cluster coordinator: %v", err)
+        }
+    }
+
     // Initialize crawler engine
-    crawlerEngine := NewCrawlerEngine(config.Crawler, storage, proxyMgr, stealthEng, logger)
+    crawlerEngine := NewCrawlerEngine(config.Crawler, storage, taskQueue, coordinator, proxyMgr, stealthEng, logger)
 
     app := &CrawlerApp{
         Engine:     crawlerEngine,
@@ -122,7 +171,11 @@ func setupRoutes(app *CrawlerApp) *gin.Engine {
         api.POST("/crawl", app.startCrawl)
         api.GET("/crawl/:id", app.getCrawlStatus)
         api.DELETE("/crawl/:id", app.stopCrawl)
+        api.POST("/crawl/:id/pause", app.pauseCrawl)
+        api.POST("/crawl/:id/resume", app.resumeCrawl)
+        api.GET("/crawl/:id/changes", app.listChanges)
         api.GET("/crawls", app.listCrawls)
+        api.GET("/dead-letters", app.listDeadLetters)
 
         // Configuration
         api.GET("/config", app.getConfig)
@@ -132,6 +185,12 @@ func setupRoutes(app *CrawlerApp) *gin.Engine {
         api.GET("/stats", app.getStats)
         api.GET("/health", app.healthCheck)
         api.GET("/metrics", app.getMetrics)
+        api.GET("/nodes", app.listNodes)
+
+        // Worker pool
+        api.GET("/workers", app.listWorkers)
+        api.PUT("/workers", app.scaleWorkers)
+        api.DELETE("/workers/*id", app.drainWorker)
 
         // Proxy management
         api.GET("/proxies", app.getProxies)
@@ -139,6 +198,7 @@ func setupRoutes(app *CrawlerApp) *gin.Engine {
 
         // Data export
         api.GET("/export/:crawlId", app.exportData)
+        api.GET("/results/:taskId/diff", app.diffSnapshot)
     }
 
     return router
@@ -0,0 +1,146 @@
+// nodes.go
+package main
+
+import (
+    "context"
+    "os"
+    "time"
+
+    "crawler666/pkg/cluster"
+)
+
+// runNode keeps the node registered with the cluster while the engine
+// runs. Nodes that schedule also reap the nodes whose heartbeats stopped.
+func (e *CrawlerEngine) runNode(ctx context.Context) {
+    interval := time.Duration(e.config.Cluster.HeartbeatInterval) * time.Second
+    if interval <= 0 {
+        interval = 5 * time.Second
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    registered := false
+    for {
+        joined, err := e.cluster.Heartbeat(ctx, e.localNode())
+        if err != nil {
+            e.logger.Errorf("Failed to send heartbeat: %v", err)
+        } else if joined && registered {
+            // Another node took this one for dead and released its tasks
+            e.logger.Warnf("Node %s was reaped and rejoined the cluster; its running tasks may be crawled twice", e.nodeID)
+        } else if joined {
+            e.logger.Infof("Node %s joined the cluster", e.nodeID)
+            registered = true
+        }
+
+        if e.config.Role != "worker" {
+            e.reapNodes(ctx)
+        }
+        if e.renderer == nil && e.config.Role != "scheduler" {
+            e.refreshRenderNodes(ctx)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// reapNodes hands the tasks of dead nodes back to the frontier. Should
+// that fail, the tasks still come back once their leases expire.
+func (e *CrawlerEngine) reapNodes(ctx context.Context) {
+    ids, err := e.cluster.Reap(ctx)
+    if err != nil {
+        e.logger.Errorf("Failed to reap dead nodes: %v", err)
+    }
+    for _, id := range ids {
+        released, err := e.storage.ReleaseNodeTasks(id)
+        if err != nil {
+            e.logger.Errorf("Failed to release the tasks of node %s: %v", id, err)
+            continue
+        }
+        e.logger.Warnf("Reaped node %s after its heartbeats stopped, released %d tasks", id, released)
+    }
+}
+
+// refreshRenderNodes notes whether some other node can render, in which
+// case tasks that need rendering are left to it.
+func (e *CrawlerEngine) refreshRenderNodes(ctx context.Context) {
+    nodes, err := e.cluster.Nodes(ctx)
+    if err != nil {
+        e.logger.Errorf("Failed to list cluster nodes: %v", err)
+        return
+    }
+
+    found := false
+    for _, node := range nodes {
+        if node.ID != e.nodeID && node.Has("render") {
+            found = true
+            break
+        }
+    }
+
+    e.mu.Lock()
+    e.renderElsewhere = found
+    e.mu.Unlock()
+}
+
+// leaveToOthers reports whether a delivered task needs a capability this
+// node lacks and another node has.
+func (e *CrawlerEngine) leaveToOthers(state *sessionState, url string) bool {
+    if e.renderer != nil || !e.wantsRender(state, url) {
+        return false
+    }
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+    return e.renderElsewhere
+}
+
+// Nodes lists the nodes of the cluster, or just this one when it runs
+// standalone.
+func (e *CrawlerEngine) Nodes(ctx context.Context) ([]*cluster.Node, error) {
+    if e.cluster == nil {
+        node := e.localNode()
+        node.LastHeartbeat = time.Now()
+        return []*cluster.Node{node}, nil
+    }
+    return e.cluster.Nodes(ctx)
+}
+
+func (e *CrawlerEngine) localNode() *cluster.Node {
+    hostname, err := os.Hostname()
+    if err != nil {
+        hostname = ""
+    }
+
+    pool := e.WorkerPoolStats()
+    node := &cluster.Node{
+        ID:           e.nodeID,
+        Hostname:     hostname,
+        Role:         e.config.Role,
+        Capabilities: e.capabilities(),
+        Workers:      pool.Active,
+        Busy:         pool.Busy,
+        StartedAt:    e.startedAt,
+    }
+    if pool.Active > 0 {
+        node.Load = float64(pool.Busy) / float64(pool.Active)
+    }
+    return node
+}
+
+// capabilities names what the node contributes to the cluster.
+func (e *CrawlerEngine) capabilities() []string {
+    var caps []string
+    if e.config.Role != "worker" {
+        caps = append(caps, "schedule")
+    }
+    if e.config.Role != "scheduler" {
+        caps = append(caps, "crawl")
+        if e.renderer != nil {
+            caps = append(caps, "render")
+        }
+    }
+    return caps
+}
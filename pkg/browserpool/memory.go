@@ -0,0 +1,88 @@
+// pkg/browserpool/memory.go
+package browserpool
+
+import (
+    "bufio"
+    "fmt"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+)
+
+// treeMemory sums the resident memory of a process and all of its
+// descendants, since chromium keeps renderers in child processes. It reads
+// /proc and so only works on Linux.
+func treeMemory(pid int) (int64, error) {
+    entries, err := os.ReadDir("/proc")
+    if err != nil {
+        return 0, fmt.Errorf("failed to list processes: %v", err)
+    }
+
+    children := make(map[int][]int)
+    for _, entry := range entries {
+        child, err := strconv.Atoi(entry.Name())
+        if err != nil {
+            continue
+        }
+        if parent, err := parentPID(child); err == nil {
+            children[parent] = append(children[parent], child)
+        }
+    }
+
+    var total int64
+    queue := []int{pid}
+    for len(queue) > 0 {
+        current := queue[0]
+        queue = queue[1:]
+        if rss, err := residentMemory(current); err == nil {
+            total += rss
+        }
+        queue = append(queue, children[current]...)
+    }
+    return total, nil
+}
+
+func parentPID(pid int) (int, error) {
+    data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+    if err != nil {
+        return 0, err
+    }
+    // The command name may contain spaces, so fields start after its ')'
+    stat := string(data)
+    end := strings.LastIndexByte(stat, ')')
+    if end < 0 {
+        return 0, fmt.Errorf("malformed stat for %d", pid)
+    }
+    fields := strings.Fields(stat[end+1:])
+    if len(fields) < 2 {
+        return 0, fmt.Errorf("malformed stat for %d", pid)
+    }
+    return strconv.Atoi(fields[1])
+}
+
+func residentMemory(pid int) (int64, error) {
+    f, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "status"))
+    if err != nil {
+        return 0, err
+    }
+    defer f.Close()
+
+    scanner := bufio.NewScanner(f)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if !strings.HasPrefix(line, "VmRSS:") {
+            continue
+        }
+        fields := strings.Fields(line)
+        if len(fields) < 2 {
+            break
+        }
+        kb, err := strconv.ParseInt(fields[1], 10, 64)
+        if err != nil {
+            return 0, err
+        }
+        return kb * 1024, nil
+    }
+    return 0, fmt.Errorf("no VmRSS for %d", pid)
+}
@@ -0,0 +1,50 @@
+// pkg/browserpool/memory_test.go
+package browserpool
+
+import (
+    "os"
+    "os/exec"
+    "runtime"
+    "testing"
+)
+
+func TestTreeMemory(t *testing.T) {
+    if runtime.GOOS != "linux" {
+        t.Skip("process memory is read from /proc")
+    }
+
+    child := exec.Command("sleep", "10")
+    if err := child.Start(); err != nil {
+        t.Skipf("cannot start a child process: %v", err)
+    }
+    defer func() {
+        child.Process.Kill()
+        child.Wait()
+    }()
+
+    if parent, err := parentPID(child.Process.Pid); err != nil || parent != os.Getpid() {
+        t.Errorf("parentPID(child) = %d, %v, want %d", parent, err, os.Getpid())
+    }
+
+    own, err := residentMemory(os.Getpid())
+    if err != nil || own <= 0 {
+        t.Fatalf("residentMemory(self) = %d, %v, want a positive size", own, err)
+    }
+    childMemory, err := residentMemory(child.Process.Pid)
+    if err != nil || childMemory <= 0 {
+        t.Fatalf("residentMemory(child) = %d, %v, want a positive size", childMemory, err)
+    }
+
+    // The tree covers the child, which is far smaller than this process
+    total, err := treeMemory(os.Getpid())
+    if err != nil {
+        t.Fatalf("treeMemory failed: %v", err)
+    }
+    if total <= childMemory || total < own/2 {
+        t.Errorf("treeMemory(self) = %d, want about %d + %d", total, own, childMemory)
+    }
+
+    if _, err := residentMemory(-1); err == nil {
+        t.Error("residentMemory(-1) succeeded, want an error")
+    }
+}
@@ -0,0 +1,175 @@
+// pkg/cluster/cluster.go
+package cluster
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strconv"
+    "time"
+
+    "github.com/go-redis/redis/v8"
+)
+
+// Node describes one crawler process as it last reported itself.
+type Node struct {
+    ID           string   `json:"id"`
+    Hostname     string   `json:"hostname"`
+    Role         string   `json:"role"`
+    Capabilities []string `json:"capabilities"`
+    // Workers is the node's capacity, Busy how many of them hold a task
+    // and Load the busy fraction
+    Workers       int       `json:"workers"`
+    Busy          int       `json:"busy"`
+    Load          float64   `json:"load"`
+    StartedAt     time.Time `json:"started_at"`
+    LastHeartbeat time.Time `json:"last_heartbeat"`
+}
+
+// Has reports whether the node advertises a capability.
+func (n *Node) Has(capability string) bool {
+    for _, c := range n.Capabilities {
+        if c == capability {
+            return true
+        }
+    }
+    return false
+}
+
+type Config struct {
+    Redis  RedisConfig
+    Prefix string
+    // NodeTTL is how long a node may go without a heartbeat before it is
+    // considered dead
+    NodeTTL time.Duration
+}
+
+type RedisConfig struct {
+    Host     string
+    Port     int
+    Password string
+    DB       int
+}
+
+// Coordinator keeps the registry of live nodes in Redis: a hash of node
+// descriptions and a sorted set of their last heartbeat times.
+type Coordinator struct {
+    config *Config
+    client *redis.Client
+}
+
+func NewCoordinator(config *Config) (*Coordinator, error) {
+    if config.Prefix == "" {
+        config.Prefix = "crawler:nodes"
+    }
+    if config.NodeTTL <= 0 {
+        config.NodeTTL = 30 * time.Second
+    }
+
+    client := redis.NewClient(&redis.Options{
+        Addr:     fmt.Sprintf("%s:%d", config.Redis.Host, config.Redis.Port),
+        Password: config.Redis.Password,
+        DB:       config.Redis.DB,
+    })
+    if err := client.Ping(context.Background()).Err(); err != nil {
+        return nil, err
+    }
+
+    return &Coordinator{config: config, client: client}, nil
+}
+
+func (c *Coordinator) nodesKey() string      { return c.config.Prefix }
+func (c *Coordinator) heartbeatsKey() string { return c.config.Prefix + ":heartbeats" }
+
+// Heartbeat registers or refreshes a node. joined is true when the node
+// was not registered, i.e. on its first heartbeat or after it was reaped.
+func (c *Coordinator) Heartbeat(ctx context.Context, node *Node) (bool, error) {
+    node.LastHeartbeat = time.Now()
+    data, err := json.Marshal(node)
+    if err != nil {
+        return false, err
+    }
+
+    pipe := c.client.TxPipeline()
+    pipe.HSet(ctx, c.nodesKey(), node.ID, data)
+    added := pipe.ZAdd(ctx, c.heartbeatsKey(), &redis.Z{
+        Score:  float64(node.LastHeartbeat.UnixMilli()),
+        Member: node.ID,
+    })
+    if _, err := pipe.Exec(ctx); err != nil {
+        return false, err
+    }
+    return added.Val() == 1, nil
+}
+
+// Leave deregisters a node that shuts down cleanly.
+func (c *Coordinator) Leave(ctx context.Context, nodeID string) error {
+    pipe := c.client.TxPipeline()
+    pipe.HDel(ctx, c.nodesKey(), nodeID)
+    pipe.ZRem(ctx, c.heartbeatsKey(), nodeID)
+    _, err := pipe.Exec(ctx)
+    return err
+}
+
+// Nodes lists the registered nodes by ID.
+func (c *Coordinator) Nodes(ctx context.Context) ([]*Node, error) {
+    entries, err := c.client.HGetAll(ctx, c.nodesKey()).Result()
+    if err != nil {
+        return nil, err
+    }
+
+    nodes := make([]*Node, 0, len(entries))
+    for id, data := range entries {
+        node := &Node{}
+        if err := json.Unmarshal([]byte(data), node); err != nil {
+            return nil, fmt.Errorf("invalid registration of node %s: %v", id, err)
+        }
+        nodes = append(nodes, node)
+    }
+    sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+    return nodes, nil
+}
+
+// Reap deregisters the nodes whose heartbeats stopped and returns their
+// IDs. When several coordinators reap at once, each dead node is returned
+// to exactly one of them.
+func (c *Coordinator) Reap(ctx context.Context) ([]string, error) {
+    cutoff := time.Now().Add(-c.config.NodeTTL).UnixMilli()
+    ids, err := c.client.ZRangeByScore(ctx, c.heartbeatsKey(), &redis.ZRangeBy{
+        Min: "-inf",
+        Max: strconv.FormatInt(cutoff, 10),
+    }).Result()
+    if err != nil {
+        return nil, err
+    }
+
+    var reaped []string
+    for _, id := range ids {
+        removed, err := reapScript.Run(ctx, c.client,
+            []string{c.heartbeatsKey(), c.nodesKey()}, id, cutoff).Int()
+        if err != nil {
+            return reaped, err
+        }
+        if removed == 1 {
+            reaped = append(reaped, id)
+        }
+    }
+    return reaped, nil
+}
+
+// reapScript removes a node only if it is still overdue, so a heartbeat
+// arriving between listing and removal keeps the node alive.
+var reapScript = redis.NewScript(`
+local beat = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if beat and tonumber(beat) <= tonumber(ARGV[2]) then
+    redis.call('ZREM', KEYS[1], ARGV[1])
+    redis.call('HDEL', KEYS[2], ARGV[1])
+    return 1
+end
+return 0
+`)
+
+func (c *Coordinator) Close() error {
+    return c.client.Close()
+}
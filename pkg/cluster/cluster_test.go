@@ -0,0 +1,149 @@
+// pkg/cluster/cluster_test.go
+package cluster
+
+import (
+    "context"
+    "net"
+    "os"
+    "reflect"
+    "strconv"
+    "testing"
+    "time"
+
+    "github.com/go-redis/redis/v8"
+    "github.com/google/uuid"
+)
+
+// testCoordinator connects to the Redis at the host:port in
+// CRAWLER666_TEST_REDIS under a prefix of its own, and skips the test
+// without one.
+func testCoordinator(t *testing.T, ttl time.Duration) *Coordinator {
+    t.Helper()
+    addr := os.Getenv("CRAWLER666_TEST_REDIS")
+    if addr == "" {
+        t.Skip("CRAWLER666_TEST_REDIS is not set")
+    }
+    host, port, err := net.SplitHostPort(addr)
+    if err != nil {
+        t.Fatalf("invalid CRAWLER666_TEST_REDIS: %v", err)
+    }
+    portNum, _ := strconv.Atoi(port)
+
+    c, err := NewCoordinator(&Config{
+        Redis:   RedisConfig{Host: host, Port: portNum},
+        Prefix:  "test:" + uuid.New().String(),
+        NodeTTL: ttl,
+    })
+    if err != nil {
+        t.Fatalf("failed to connect to Redis: %v", err)
+    }
+    t.Cleanup(func() {
+        c.client.Del(context.Background(), c.nodesKey(), c.heartbeatsKey())
+        c.Close()
+    })
+    return c
+}
+
+func TestNodeHas(t *testing.T) {
+    node := &Node{Capabilities: []string{"fetch", "render"}}
+    for capability, want := range map[string]bool{"fetch": true, "render": true, "schedule": false, "": false} {
+        if got := node.Has(capability); got != want {
+            t.Errorf("Has(%q) = %v, want %v", capability, got, want)
+        }
+    }
+}
+
+func TestNewCoordinatorDefaults(t *testing.T) {
+    config := &Config{Redis: RedisConfig{Host: "127.0.0.1", Port: 1}}
+    if _, err := NewCoordinator(config); err == nil {
+        t.Fatal("NewCoordinator succeeded without a Redis server")
+    }
+    if config.Prefix != "crawler:nodes" || config.NodeTTL != 30*time.Second {
+        t.Errorf("defaults = %q, %v, want %q, %v", config.Prefix, config.NodeTTL, "crawler:nodes", 30*time.Second)
+    }
+}
+
+func TestHeartbeat(t *testing.T) {
+    c := testCoordinator(t, time.Minute)
+    ctx := context.Background()
+
+    steps := []struct {
+        action string
+        node   *Node
+        joined bool
+        nodes  []string
+    }{
+        {"heartbeat", &Node{ID: "b", Role: "worker", Workers: 4}, true, []string{"b"}},
+        {"heartbeat", &Node{ID: "a", Role: "scheduler"}, true, []string{"a", "b"}},
+        {"heartbeat", &Node{ID: "b", Role: "worker", Workers: 4, Busy: 2}, false, []string{"a", "b"}},
+        {"leave", &Node{ID: "a"}, false, []string{"b"}},
+        {"heartbeat", &Node{ID: "a", Role: "scheduler"}, true, []string{"a", "b"}},
+    }
+
+    for i, step := range steps {
+        if step.action == "leave" {
+            if err := c.Leave(ctx, step.node.ID); err != nil {
+                t.Fatalf("step %d: Leave failed: %v", i, err)
+            }
+        } else {
+            joined, err := c.Heartbeat(ctx, step.node)
+            if err != nil {
+                t.Fatalf("step %d: Heartbeat failed: %v", i, err)
+            }
+            if joined != step.joined {
+                t.Errorf("step %d: Heartbeat(%s) joined = %v, want %v", i, step.node.ID, joined, step.joined)
+            }
+        }
+
+        nodes, err := c.Nodes(ctx)
+        if err != nil {
+            t.Fatalf("step %d: Nodes failed: %v", i, err)
+        }
+        var ids []string
+        for _, node := range nodes {
+            ids = append(ids, node.ID)
+        }
+        if !reflect.DeepEqual(ids, step.nodes) {
+            t.Errorf("step %d: Nodes = %v, want %v", i, ids, step.nodes)
+        }
+    }
+
+    nodes, _ := c.Nodes(ctx)
+    if b := nodes[1]; b.Busy != 2 || b.LastHeartbeat.IsZero() {
+        t.Errorf("node b = %+v, want its latest registration", b)
+    }
+}
+
+func TestReap(t *testing.T) {
+    c := testCoordinator(t, time.Minute)
+    ctx := context.Background()
+
+    for _, id := range []string{"dead", "alive"} {
+        if _, err := c.Heartbeat(ctx, &Node{ID: id}); err != nil {
+            t.Fatal(err)
+        }
+    }
+    // Backdate the dead node's last heartbeat past the TTL
+    stale := float64(time.Now().Add(-2 * time.Minute).UnixMilli())
+    c.client.ZAdd(ctx, c.heartbeatsKey(), &redis.Z{Score: stale, Member: "dead"})
+
+    reaped, err := c.Reap(ctx)
+    if err != nil {
+        t.Fatalf("Reap failed: %v", err)
+    }
+    if len(reaped) != 1 || reaped[0] != "dead" {
+        t.Errorf("Reap = %v, want [dead]", reaped)
+    }
+    if again, _ := c.Reap(ctx); len(again) != 0 {
+        t.Errorf("second Reap = %v, want nothing", again)
+    }
+
+    nodes, _ := c.Nodes(ctx)
+    if len(nodes) != 1 || nodes[0].ID != "alive" {
+        t.Errorf("Nodes after Reap = %v, want only alive", nodes)
+    }
+    // A reaped node that comes back joins again
+    if joined, _ := c.Heartbeat(ctx, &Node{ID: "dead"}); !joined {
+        t.Error("Heartbeat of a reaped node did not rejoin it")
+    }
+}
@@ -0,0 +1,133 @@
+// pkg/dedup/store.go
+package dedup
+
+import (
+    "fmt"
+    "hash/fnv"
+    "math"
+)
+
+// Store remembers which URLs a session has already queued.
+type Store interface {
+    // MarkSeen records url for the session and reports whether it had
+    // not been seen before.
+    MarkSeen(sessionID, url string) (bool, error)
+    // Unmark forgets a URL that was marked but never queued, so that it
+    // is admitted when found again.
+    Unmark(sessionID, url string) error
+}
+
+// SetBackend keeps exact per-session URL sets, e.g. Redis sets. Both
+// methods report whether the set changed.
+type SetBackend interface {
+    AddSeenURL(sessionID, url string) (bool, error)
+    RemoveSeenURL(sessionID, url string) (bool, error)
+}
+
+// BitBackend keeps per-session bitmaps, e.g. Redis strings used with
+// SETBIT. SetSeenBits sets all offsets and reports whether any of them
+// was previously unset.
+type BitBackend interface {
+    SetSeenBits(sessionID string, offsets []uint64) (bool, error)
+}
+
+type Config struct {
+    // Backend is "set" for exact deduplication or "bloom" for a
+    // fixed-size Bloom filter suited to very large crawls.
+    Backend           string
+    ExpectedURLs      int
+    FalsePositiveRate float64
+}
+
+func NewStore(config *Config, sets SetBackend, bits BitBackend) (Store, error) {
+    switch config.Backend {
+    case "", "set":
+        return &setStore{backend: sets}, nil
+    case "bloom":
+        return newBloomStore(config, bits, sets), nil
+    default:
+        return nil, fmt.Errorf("unknown dedup backend %q", config.Backend)
+    }
+}
+
+type setStore struct {
+    backend SetBackend
+}
+
+func (s *setStore) MarkSeen(sessionID, url string) (bool, error) {
+    return s.backend.AddSeenURL(sessionID, url)
+}
+
+func (s *setStore) Unmark(sessionID, url string) error {
+    _, err := s.backend.RemoveSeenURL(sessionID, url)
+    return err
+}
+
+// bloomStore is a Bloom filter whose bits live in a BitBackend so that
+// every engine process shares the same filter. Bits cannot be cleared
+// without forgetting other URLs, so unmarked URLs are kept in an exact set
+// of exceptions instead.
+type bloomStore struct {
+    backend  BitBackend
+    unmarked SetBackend
+    bits     uint64
+    hashes   int
+}
+
+func newBloomStore(config *Config, backend BitBackend, unmarked SetBackend) *bloomStore {
+    n := float64(config.ExpectedURLs)
+    if n < 1 {
+        n = 1e6
+    }
+    p := config.FalsePositiveRate
+    if p <= 0 || p >= 1 {
+        p = 0.001
+    }
+
+    m := math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2))
+    // Redis bitmaps are limited to 2^32 bits
+    if m > 1<<32 {
+        m = 1 << 32
+    }
+    k := int(math.Round(m / n * math.Ln2))
+    if k < 1 {
+        k = 1
+    }
+
+    return &bloomStore{backend: backend, unmarked: unmarked, bits: uint64(m), hashes: k}
+}
+
+func (b *bloomStore) MarkSeen(sessionID, url string) (bool, error) {
+    added, err := b.backend.SetSeenBits(sessionID, b.offsets(url))
+    if err != nil || added {
+        return added, err
+    }
+    // Taking the URL out of the exceptions admits it exactly once
+    return b.unmarked.RemoveSeenURL(unmarkedKey(sessionID), url)
+}
+
+func (b *bloomStore) Unmark(sessionID, url string) error {
+    _, err := b.unmarked.AddSeenURL(unmarkedKey(sessionID), url)
+    return err
+}
+
+func unmarkedKey(sessionID string) string {
+    return sessionID + ":unmarked"
+}
+
+// offsets derives the filter positions of url by double hashing.
+func (b *bloomStore) offsets(url string) []uint64 {
+    h1 := fnv.New64a()
+    h1.Write([]byte(url))
+    sum1 := h1.Sum64()
+
+    h2 := fnv.New64()
+    h2.Write([]byte(url))
+    sum2 := h2.Sum64() | 1
+
+    offsets := make([]uint64, b.hashes)
+    for i := range offsets {
+        offsets[i] = (sum1 + uint64(i)*sum2) % b.bits
+    }
+    return offsets
+}
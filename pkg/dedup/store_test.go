@@ -0,0 +1,173 @@
+// pkg/dedup/store_test.go
+package dedup
+
+import (
+    "math"
+    "strconv"
+    "testing"
+)
+
+// memoryBackend implements both backends with maps.
+type memoryBackend struct {
+    sets map[string]bool
+    bits map[string]bool
+}
+
+func newMemoryBackend() *memoryBackend {
+    return &memoryBackend{sets: make(map[string]bool), bits: make(map[string]bool)}
+}
+
+func (m *memoryBackend) AddSeenURL(sessionID, url string) (bool, error) {
+    key := sessionID + " " + url
+    added := !m.sets[key]
+    m.sets[key] = true
+    return added, nil
+}
+
+func (m *memoryBackend) RemoveSeenURL(sessionID, url string) (bool, error) {
+    key := sessionID + " " + url
+    removed := m.sets[key]
+    delete(m.sets, key)
+    return removed, nil
+}
+
+func (m *memoryBackend) SetSeenBits(sessionID string, offsets []uint64) (bool, error) {
+    added := false
+    for _, offset := range offsets {
+        key := sessionID + " " + strconv.FormatUint(offset, 10)
+        if !m.bits[key] {
+            added = true
+            m.bits[key] = true
+        }
+    }
+    return added, nil
+}
+
+func TestNewBloomStoreSizing(t *testing.T) {
+    tests := []struct {
+        name       string
+        config     Config
+        wantBits   uint64
+        wantHashes int
+    }{
+        {"one in a thousand", Config{ExpectedURLs: 1000, FalsePositiveRate: 0.001}, 14378, 10},
+        {"one in a hundred", Config{ExpectedURLs: 1000, FalsePositiveRate: 0.01}, 9586, 7},
+        {"defaults", Config{}, 14377588, 10},
+        {"invalid rate falls back", Config{ExpectedURLs: 1000, FalsePositiveRate: 1.5}, 14378, 10},
+        {"capped at Redis bitmap size", Config{ExpectedURLs: 1e10, FalsePositiveRate: 0.001}, 1 << 32, 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            config := tt.config
+            b := newBloomStore(&config, nil, nil)
+            if b.bits != tt.wantBits || b.hashes != tt.wantHashes {
+                t.Errorf("bits, hashes = %d, %d, want %d, %d", b.bits, b.hashes, tt.wantBits, tt.wantHashes)
+            }
+        })
+    }
+}
+
+func TestBloomOffsets(t *testing.T) {
+    b := newBloomStore(&Config{ExpectedURLs: 1000, FalsePositiveRate: 0.001}, nil, nil)
+
+    urls := []string{"https://example.com/", "https://example.com/a", "https://example.org/", ""}
+    for _, url := range urls {
+        offsets := b.offsets(url)
+        if len(offsets) != b.hashes {
+            t.Fatalf("offsets(%q) has %d entries, want %d", url, len(offsets), b.hashes)
+        }
+        distinct := make(map[uint64]bool)
+        for _, offset := range offsets {
+            if offset >= b.bits {
+                t.Errorf("offsets(%q) contains %d, beyond %d bits", url, offset, b.bits)
+            }
+            distinct[offset] = true
+        }
+        // The odd second hash keeps the probes from collapsing
+        if len(distinct) < len(offsets)-1 {
+            t.Errorf("offsets(%q) = %v, want distinct positions", url, offsets)
+        }
+
+        again := b.offsets(url)
+        for i := range offsets {
+            if offsets[i] != again[i] {
+                t.Fatalf("offsets(%q) is not deterministic", url)
+            }
+        }
+    }
+
+    if a, c := b.offsets(urls[0]), b.offsets(urls[1]); equal(a, c) {
+        t.Errorf("different URLs share all offsets %v", a)
+    }
+}
+
+func TestBloomOffsetsNearCap(t *testing.T) {
+    b := &bloomStore{bits: math.MaxUint32 + 1, hashes: 20}
+    for _, offset := range b.offsets("https://example.com/") {
+        if offset >= b.bits {
+            t.Errorf("offset %d is beyond %d bits", offset, b.bits)
+        }
+    }
+}
+
+func TestStores(t *testing.T) {
+    for _, backend := range []string{"set", "bloom"} {
+        t.Run(backend, func(t *testing.T) {
+            memory := newMemoryBackend()
+            store, err := NewStore(&Config{Backend: backend, ExpectedURLs: 1000}, memory, memory)
+            if err != nil {
+                t.Fatalf("NewStore: %v", err)
+            }
+
+            steps := []struct {
+                action  string
+                session string
+                url     string
+                want    bool
+            }{
+                {"mark", "s1", "https://example.com/a", true},
+                {"mark", "s1", "https://example.com/a", false},
+                {"mark", "s1", "https://example.com/b", true},
+                {"mark", "s2", "https://example.com/a", true},
+                {"unmark", "s1", "https://example.com/a", false},
+                {"mark", "s1", "https://example.com/a", true},
+                {"mark", "s1", "https://example.com/a", false},
+                {"mark", "s2", "https://example.com/a", false},
+            }
+            for i, step := range steps {
+                if step.action == "unmark" {
+                    if err := store.Unmark(step.session, step.url); err != nil {
+                        t.Fatalf("step %d: Unmark: %v", i, err)
+                    }
+                    continue
+                }
+                got, err := store.MarkSeen(step.session, step.url)
+                if err != nil {
+                    t.Fatalf("step %d: MarkSeen: %v", i, err)
+                }
+                if got != step.want {
+                    t.Errorf("step %d: MarkSeen(%s, %s) = %v, want %v", i, step.session, step.url, got, step.want)
+                }
+            }
+        })
+    }
+}
+
+func TestUnknownBackend(t *testing.T) {
+    if _, err := NewStore(&Config{Backend: "cuckoo"}, nil, nil); err == nil {
+        t.Error("NewStore accepted an unknown backend")
+    }
+}
+
+func equal(a, b []uint64) bool {
+    if len(a) != len(b) {
+        return false
+    }
+    for i := range a {
+        if a[i] != b[i] {
+            return false
+        }
+    }
+    return true
+}
@@ -0,0 +1,215 @@
+// pkg/diff/diff.go
+package diff
+
+import (
+    "fmt"
+    "strings"
+)
+
+// Op says what happened to a line going from the old text to the new one.
+type Op byte
+
+const (
+    Equal  Op = ' '
+    Delete Op = '-'
+    Insert Op = '+'
+)
+
+type Line struct {
+    Op   Op
+    Text string
+}
+
+// Result is a line diff between two texts.
+type Result struct {
+    Lines   []Line
+    Added   int
+    Removed int
+}
+
+// Lines diffs two texts line by line with Myers' algorithm, which finds a
+// shortest edit script in O((N+M)D) time for D edits.
+func Lines(a, b string) *Result {
+    x, y := splitLines(a), splitLines(b)
+
+    // Common ends cost nothing to keep out of the search
+    prefix := 0
+    for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+        prefix++
+    }
+    suffix := 0
+    for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+        suffix++
+    }
+
+    result := &Result{}
+    for _, line := range x[:prefix] {
+        result.Lines = append(result.Lines, Line{Op: Equal, Text: line})
+    }
+    for _, line := range myers(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix]) {
+        switch line.Op {
+        case Insert:
+            result.Added++
+        case Delete:
+            result.Removed++
+        }
+        result.Lines = append(result.Lines, line)
+    }
+    for _, line := range x[len(x)-suffix:] {
+        result.Lines = append(result.Lines, Line{Op: Equal, Text: line})
+    }
+    return result
+}
+
+func splitLines(s string) []string {
+    if s == "" {
+        return nil
+    }
+    return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+func myers(x, y []string) []Line {
+    n, m := len(x), len(y)
+    max := n + m
+    if max == 0 {
+        return nil
+    }
+
+    // v[k+max] is the furthest x reached on diagonal k; trace keeps a copy
+    // per edit distance to walk the path back
+    v := make([]int, 2*max+1)
+    var trace [][]int
+    for d := 0; d <= max; d++ {
+        trace = append(trace, append([]int(nil), v...))
+        for k := -d; k <= d; k += 2 {
+            var i int
+            if k == -d || (k != d && v[k-1+max] < v[k+1+max]) {
+                i = v[k+1+max]
+            } else {
+                i = v[k-1+max] + 1
+            }
+            j := i - k
+            for i < n && j < m && x[i] == y[j] {
+                i++
+                j++
+            }
+            v[k+max] = i
+            if i >= n && j >= m {
+                return backtrack(x, y, trace, d, max)
+            }
+        }
+    }
+    return nil
+}
+
+func backtrack(x, y []string, trace [][]int, d, max int) []Line {
+    var lines []Line
+    i, j := len(x), len(y)
+    for ; d > 0; d-- {
+        v := trace[d]
+        k := i - j
+        var prevK int
+        if k == -d || (k != d && v[k-1+max] < v[k+1+max]) {
+            prevK = k + 1
+        } else {
+            prevK = k - 1
+        }
+        prevI := v[prevK+max]
+        prevJ := prevI - prevK
+
+        for i > prevI && j > prevJ {
+            i--
+            j--
+            lines = append(lines, Line{Op: Equal, Text: x[i]})
+        }
+        if i == prevI {
+            j--
+            lines = append(lines, Line{Op: Insert, Text: y[j]})
+        } else {
+            i--
+            lines = append(lines, Line{Op: Delete, Text: x[i]})
+        }
+    }
+    for i > 0 && j > 0 {
+        i--
+        j--
+        lines = append(lines, Line{Op: Equal, Text: x[i]})
+    }
+
+    for l, r := 0, len(lines)-1; l < r; l, r = l+1, r-1 {
+        lines[l], lines[r] = lines[r], lines[l]
+    }
+    return lines
+}
+
+// Unified renders the diff in unified format with the given number of
+// context lines around each change. It is empty when the texts are equal.
+func (r *Result) Unified(fromName, toName string, context int) string {
+    if r.Added == 0 && r.Removed == 0 {
+        return ""
+    }
+
+    var out strings.Builder
+    fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+
+    // Line numbers of each entry in the old and new text
+    oldLine := make([]int, len(r.Lines)+1)
+    newLine := make([]int, len(r.Lines)+1)
+    for i, line := range r.Lines {
+        oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+        if line.Op != Insert {
+            oldLine[i+1]++
+        }
+        if line.Op != Delete {
+            newLine[i+1]++
+        }
+    }
+
+    for start := 0; start < len(r.Lines); {
+        for start < len(r.Lines) && r.Lines[start].Op == Equal {
+            start++
+        }
+        if start == len(r.Lines) {
+            break
+        }
+
+        // Grow the hunk while the next change is close enough to share context
+        end := start
+        for i := start; i < len(r.Lines) && i <= end+2*context; i++ {
+            if r.Lines[i].Op != Equal {
+                end = i
+            }
+        }
+
+        from := start - context
+        if from < 0 {
+            from = 0
+        }
+        to := end + context + 1
+        if to > len(r.Lines) {
+            to = len(r.Lines)
+        }
+
+        fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+            hunkRange(oldLine[from], oldLine[to]-oldLine[from]),
+            hunkRange(newLine[from], newLine[to]-newLine[from]))
+        for _, line := range r.Lines[from:to] {
+            out.WriteByte(byte(line.Op))
+            out.WriteString(line.Text)
+            out.WriteByte('\n')
+        }
+        start = to
+    }
+
+    return out.String()
+}
+
+func hunkRange(start, count int) string {
+    if count == 0 {
+        return fmt.Sprintf("%d,0", start)
+    }
+    if count == 1 {
+        return fmt.Sprintf("%d", start+1)
+    }
+    return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,130 @@
+// pkg/diff/diff_test.go
+package diff
+
+import (
+    "strings"
+    "testing"
+)
+
+// render spells a diff as one "<op><text>" entry per line.
+func render(r *Result) string {
+    entries := make([]string, len(r.Lines))
+    for i, line := range r.Lines {
+        entries[i] = string(byte(line.Op)) + line.Text
+    }
+    return strings.Join(entries, "|")
+}
+
+func TestLines(t *testing.T) {
+    tests := []struct {
+        name           string
+        a, b           string
+        want           string
+        added, removed int
+    }{
+        {"both empty", "", "", "", 0, 0},
+        {"equal", "a\nb\n", "a\nb\n", " a| b", 0, 0},
+        {"trailing newline ignored", "a\nb", "a\nb\n", " a| b", 0, 0},
+        {"from empty", "", "a\nb\n", "+a|+b", 2, 0},
+        {"to empty", "a\nb\n", "", "-a|-b", 0, 2},
+        {"replace middle", "a\nb\nc\n", "a\nx\nc\n", " a|-b|+x| c", 1, 1},
+        {"insert at start", "b\nc\n", "a\nb\nc\n", "+a| b| c", 1, 0},
+        {"delete at end", "a\nb\nc\n", "a\nb\n", " a| b|-c", 0, 1},
+        {"moved line", "a\nb\nc\n", "b\nc\na\n", "-a| b| c|+a", 1, 1},
+        {"repeated lines", "x\nx\nx\n", "x\nx\n", " x| x|-x", 0, 1},
+        {"interleaved", "a\nb\nc\nd\n", "a\nc\nd\ne\n", " a|-b| c| d|+e", 1, 1},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            r := Lines(tt.a, tt.b)
+            if got := render(r); got != tt.want {
+                t.Errorf("Lines = %q, want %q", got, tt.want)
+            }
+            if r.Added != tt.added || r.Removed != tt.removed {
+                t.Errorf("Added, Removed = %d, %d, want %d, %d", r.Added, r.Removed, tt.added, tt.removed)
+            }
+        })
+    }
+}
+
+// TestLinesReconstructs checks that every diff turns the old text into the
+// new one with a shortest edit script.
+func TestLinesReconstructs(t *testing.T) {
+    tests := []struct {
+        a, b  string
+        edits int
+    }{
+        {"a\nb\nc\na\nb\nb\na\n", "c\nb\na\nb\na\nc\n", 5},
+        {"1\n2\n3\n4\n5\n6\n", "0\n2\n3\n5\n6\n7\n", 4},
+        {"same\n", "same\n", 0},
+        {"x\ny\n", "y\nx\n", 2},
+    }
+
+    for _, tt := range tests {
+        r := Lines(tt.a, tt.b)
+        var from, to []string
+        for _, line := range r.Lines {
+            if line.Op != Insert {
+                from = append(from, line.Text)
+            }
+            if line.Op != Delete {
+                to = append(to, line.Text)
+            }
+        }
+        if got := strings.Join(from, "\n"); got != strings.TrimSuffix(tt.a, "\n") {
+            t.Errorf("Lines(%q, %q) old side = %q", tt.a, tt.b, got)
+        }
+        if got := strings.Join(to, "\n"); got != strings.TrimSuffix(tt.b, "\n") {
+            t.Errorf("Lines(%q, %q) new side = %q", tt.a, tt.b, got)
+        }
+        if edits := r.Added + r.Removed; edits != tt.edits {
+            t.Errorf("Lines(%q, %q) takes %d edits, want %d", tt.a, tt.b, edits, tt.edits)
+        }
+    }
+}
+
+func TestUnified(t *testing.T) {
+    numbers := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+
+    tests := []struct {
+        name    string
+        a, b    string
+        context int
+        want    string
+    }{
+        {"equal texts", "a\n", "a\n", 3, ""},
+        {
+            name:    "one change",
+            a:       numbers,
+            b:       strings.Replace(numbers, "5\n", "five\n", 1),
+            context: 2,
+            want:    "--- old\n+++ new\n@@ -3,5 +3,5 @@\n 3\n 4\n-5\n+five\n 6\n 7\n",
+        },
+        {
+            name:    "close changes share a hunk",
+            a:       numbers,
+            b:       strings.Replace(strings.Replace(numbers, "4\n", "", 1), "7\n", "seven\n", 1),
+            context: 2,
+            want:    "--- old\n+++ new\n@@ -2,8 +2,7 @@\n 2\n 3\n-4\n 5\n 6\n-7\n+seven\n 8\n 9\n",
+        },
+        {
+            name:    "distant changes split",
+            a:       numbers,
+            b:       strings.Replace(strings.Replace(numbers, "2\n", "two\n", 1), "14\n", "", 1),
+            context: 1,
+            want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n 1\n-2\n+two\n 3\n" +
+                "@@ -13,3 +13,2 @@\n 13\n-14\n 15\n",
+        },
+        {"into empty file", "", "a\n", 3, "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n"},
+        {"to empty file", "a\nb\n", "", 3, "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Lines(tt.a, tt.b).Unified("old", "new", tt.context); got != tt.want {
+                t.Errorf("Unified =\n%s\nwant\n%s", got, tt.want)
+            }
+        })
+    }
+}
@@ -0,0 +1,225 @@
+// pkg/export/writer.go
+package export
+
+import (
+    "bufio"
+    "compress/gzip"
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+// Record is one exported result together with the cursor a client can
+// pass back to resume the export after it.
+type Record struct {
+    Cursor string `json:"cursor"`
+    *models.CrawlResult
+}
+
+// Writer encodes a stream of records. Close must be called to flush any
+// buffered output and trailing format bytes.
+type Writer interface {
+    Write(record *Record) error
+    Close() error
+}
+
+type Config struct {
+    // Format is "json", "ndjson" or "csv", optionally suffixed with ".gz"
+    // for gzip compression.
+    Format  string
+    Columns []string
+}
+
+var DefaultColumns = []string{
+    "cursor", "session_id", "task_id", "url", "parent_url", "depth", "attempt",
+    "success", "status_code", "error", "start_time", "duration_ms",
+}
+
+var columns = map[string]func(r *Record) string{
+    "cursor":      func(r *Record) string { return r.Cursor },
+    "session_id":  func(r *Record) string { return r.SessionID },
+    "task_id":     func(r *Record) string { return r.TaskID },
+    "url":         func(r *Record) string { return r.URL },
+    "parent_url":  func(r *Record) string { return r.ParentURL },
+    "depth":       func(r *Record) string { return strconv.Itoa(r.Depth) },
+    "attempt":     func(r *Record) string { return strconv.Itoa(r.Attempt) },
+    "worker_id":   func(r *Record) string { return r.WorkerID },
+    "success":     func(r *Record) string { return strconv.FormatBool(r.Success) },
+    "skipped":     func(r *Record) string { return strconv.FormatBool(r.Skipped) },
+    "skip_reason": func(r *Record) string { return r.SkipReason },
+    "error":       func(r *Record) string { return r.Error },
+    "change":      func(r *Record) string { return r.Change },
+    "start_time":  func(r *Record) string { return r.StartTime.Format(time.RFC3339Nano) },
+    "end_time":    func(r *Record) string { return r.EndTime.Format(time.RFC3339Nano) },
+    "duration_ms": func(r *Record) string { return strconv.FormatInt(r.Duration.Milliseconds(), 10) },
+    "status_code": func(r *Record) string {
+        if r.Data == nil {
+            return ""
+        }
+        return strconv.Itoa(r.Data.StatusCode)
+    },
+    "links": func(r *Record) string {
+        if r.Data == nil {
+            return "0"
+        }
+        return strconv.Itoa(len(r.Data.Links))
+    },
+    "content_hash": func(r *Record) string { return r.ContentHash },
+}
+
+// ContentType returns the media type and file extension for a format.
+func ContentType(format string) (string, string) {
+    if base, ok := strings.CutSuffix(format, ".gz"); ok {
+        _, ext := ContentType(base)
+        return "application/gzip", ext + ".gz"
+    }
+    switch format {
+    case "ndjson":
+        return "application/x-ndjson", "ndjson"
+    case "csv":
+        return "text/csv; charset=utf-8", "csv"
+    default:
+        return "application/json", "json"
+    }
+}
+
+func NewWriter(w io.Writer, config *Config) (Writer, error) {
+    format := config.Format
+    if format == "" {
+        format = "json"
+    }
+
+    var closers []io.Closer
+    if base, ok := strings.CutSuffix(format, ".gz"); ok {
+        gz := gzip.NewWriter(w)
+        w = gz
+        closers = append(closers, gz)
+        format = base
+    }
+    buf := bufio.NewWriterSize(w, 64*1024)
+    out := &output{buf: buf, closers: closers}
+
+    switch format {
+    case "json":
+        return &jsonWriter{output: out, enc: json.NewEncoder(buf)}, nil
+    case "ndjson":
+        return &ndjsonWriter{output: out, enc: json.NewEncoder(buf)}, nil
+    case "csv":
+        cols := config.Columns
+        if len(cols) == 0 {
+            cols = DefaultColumns
+        }
+        getters := make([]func(r *Record) string, len(cols))
+        for i, col := range cols {
+            getter, ok := columns[col]
+            if !ok {
+                return nil, fmt.Errorf("unknown column %q", col)
+            }
+            getters[i] = getter
+        }
+        return &csvWriter{output: out, csv: csv.NewWriter(buf), header: cols, getters: getters}, nil
+    default:
+        return nil, fmt.Errorf("unknown export format %q", config.Format)
+    }
+}
+
+type output struct {
+    buf     *bufio.Writer
+    closers []io.Closer
+}
+
+func (o *output) close() error {
+    if err := o.buf.Flush(); err != nil {
+        return err
+    }
+    for _, c := range o.closers {
+        if err := c.Close(); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+type ndjsonWriter struct {
+    *output
+    enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(record *Record) error {
+    return w.enc.Encode(record)
+}
+
+func (w *ndjsonWriter) Close() error {
+    return w.close()
+}
+
+// jsonWriter writes a single JSON array without holding it in memory.
+type jsonWriter struct {
+    *output
+    enc     *json.Encoder
+    started bool
+}
+
+func (w *jsonWriter) Write(record *Record) error {
+    sep := ","
+    if !w.started {
+        sep = "["
+        w.started = true
+    }
+    if _, err := w.buf.WriteString(sep); err != nil {
+        return err
+    }
+    return w.enc.Encode(record)
+}
+
+func (w *jsonWriter) Close() error {
+    end := "]\n"
+    if !w.started {
+        end = "[]\n"
+    }
+    if _, err := w.buf.WriteString(end); err != nil {
+        return err
+    }
+    return w.close()
+}
+
+type csvWriter struct {
+    *output
+    csv     *csv.Writer
+    header  []string
+    getters []func(r *Record) string
+    started bool
+}
+
+func (w *csvWriter) Write(record *Record) error {
+    if !w.started {
+        w.started = true
+        if err := w.csv.Write(w.header); err != nil {
+            return err
+        }
+    }
+
+    row := make([]string, len(w.getters))
+    for i, get := range w.getters {
+        row[i] = get(record)
+    }
+    return w.csv.Write(row)
+}
+
+func (w *csvWriter) Close() error {
+    if !w.started {
+        w.started = true
+        w.csv.Write(w.header)
+    }
+    w.csv.Flush()
+    if err := w.csv.Error(); err != nil {
+        return err
+    }
+    return w.close()
+}
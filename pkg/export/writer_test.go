@@ -0,0 +1,154 @@
+// pkg/export/writer_test.go
+package export
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/json"
+    "io"
+    "strings"
+    "testing"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+func testRecords() []*Record {
+    started := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+    return []*Record{
+        {Cursor: "c1", CrawlResult: &models.CrawlResult{
+            TaskID: "t1", SessionID: "s", URL: "https://example.com/", Depth: 0, Attempt: 1,
+            Success: true, StartTime: started, Duration: 1500 * time.Millisecond,
+            Data: &models.CrawlData{StatusCode: 200, Links: []string{"https://example.com/a", "https://example.com/b"}},
+        }},
+        {Cursor: "c2", CrawlResult: &models.CrawlResult{
+            TaskID: "t2", SessionID: "s", URL: "https://example.com/a", ParentURL: "https://example.com/", Depth: 1, Attempt: 3,
+            Error: `timeout, "again"`, StartTime: started.Add(time.Second),
+        }},
+    }
+}
+
+// export writes records in a format and returns the uncompressed output.
+func export(t *testing.T, config *Config, records []*Record) string {
+    t.Helper()
+
+    var out bytes.Buffer
+    w, err := NewWriter(&out, config)
+    if err != nil {
+        t.Fatalf("NewWriter(%q) failed: %v", config.Format, err)
+    }
+    for _, r := range records {
+        if err := w.Write(r); err != nil {
+            t.Fatalf("Write failed: %v", err)
+        }
+    }
+    if err := w.Close(); err != nil {
+        t.Fatalf("Close failed: %v", err)
+    }
+
+    if !strings.HasSuffix(config.Format, ".gz") {
+        return out.String()
+    }
+    gz, err := gzip.NewReader(&out)
+    if err != nil {
+        t.Fatalf("output is not gzip: %v", err)
+    }
+    data, err := io.ReadAll(gz)
+    if err != nil {
+        t.Fatalf("failed to decompress output: %v", err)
+    }
+    return string(data)
+}
+
+func TestWriterFormats(t *testing.T) {
+    columns := []string{"cursor", "url", "depth", "status_code", "links", "error", "duration_ms"}
+    csvOut := "cursor,url,depth,status_code,links,error,duration_ms\n" +
+        "c1,https://example.com/,0,200,2,,1500\n" +
+        "c2,https://example.com/a,1,,0,\"timeout, \"\"again\"\"\",0\n"
+
+    tests := []struct {
+        format  string
+        records []*Record
+        want    string
+    }{
+        {"csv", testRecords(), csvOut},
+        {"csv.gz", testRecords(), csvOut},
+        {"csv", nil, "cursor,url,depth,status_code,links,error,duration_ms\n"},
+        {"json", nil, "[]\n"},
+        {"ndjson", nil, ""},
+    }
+
+    for _, tt := range tests {
+        got := export(t, &Config{Format: tt.format, Columns: columns}, tt.records)
+        if got != tt.want {
+            t.Errorf("%s export of %d records = %q, want %q", tt.format, len(tt.records), got, tt.want)
+        }
+    }
+}
+
+func TestWriterJSON(t *testing.T) {
+    for _, format := range []string{"", "json", "json.gz", "ndjson", "ndjson.gz"} {
+        out := export(t, &Config{Format: format}, testRecords())
+
+        var decoded []map[string]interface{}
+        if strings.HasPrefix(format, "ndjson") {
+            for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+                var record map[string]interface{}
+                if err := json.Unmarshal([]byte(line), &record); err != nil {
+                    t.Fatalf("%s line %q is not JSON: %v", format, line, err)
+                }
+                decoded = append(decoded, record)
+            }
+        } else if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+            t.Fatalf("%q export is not a JSON array: %v\n%s", format, err, out)
+        }
+
+        if len(decoded) != 2 {
+            t.Fatalf("%q export has %d records, want 2", format, len(decoded))
+        }
+        // The cursor sits beside the flattened result fields
+        if decoded[1]["cursor"] != "c2" || decoded[1]["parent_url"] != "https://example.com/" || decoded[1]["attempt"] != 3.0 {
+            t.Errorf("%q export record = %v", format, decoded[1])
+        }
+    }
+}
+
+func TestNewWriterErrors(t *testing.T) {
+    tests := []struct {
+        config *Config
+        errMsg string
+    }{
+        {&Config{Format: "xml"}, `unknown export format "xml"`},
+        {&Config{Format: "gz"}, `unknown export format "gz"`},
+        {&Config{Format: "csv", Columns: []string{"url", "body"}}, `unknown column "body"`},
+    }
+
+    for _, tt := range tests {
+        _, err := NewWriter(io.Discard, tt.config)
+        if err == nil || err.Error() != tt.errMsg {
+            t.Errorf("NewWriter(%+v) error = %v, want %q", tt.config, err, tt.errMsg)
+        }
+    }
+}
+
+func TestContentType(t *testing.T) {
+    tests := []struct {
+        format    string
+        mediaType string
+        ext       string
+    }{
+        {"json", "application/json", "json"},
+        {"", "application/json", "json"},
+        {"ndjson", "application/x-ndjson", "ndjson"},
+        {"csv", "text/csv; charset=utf-8", "csv"},
+        {"csv.gz", "application/gzip", "csv.gz"},
+        {"ndjson.gz", "application/gzip", "ndjson.gz"},
+    }
+
+    for _, tt := range tests {
+        mediaType, ext := ContentType(tt.format)
+        if mediaType != tt.mediaType || ext != tt.ext {
+            t.Errorf("ContentType(%q) = %q, %q, want %q, %q", tt.format, mediaType, ext, tt.mediaType, tt.ext)
+        }
+    }
+}
@@ -0,0 +1,226 @@
+// pkg/extract/extractor.go
+package extract
+
+import (
+    "bytes"
+    "fmt"
+    "regexp"
+    "strings"
+
+    "crawler666/internal/models"
+
+    "github.com/andybalholm/cascadia"
+    "github.com/antchfx/htmlquery"
+    "github.com/antchfx/xpath"
+    "golang.org/x/net/html"
+)
+
+// Record is one extracted item, keyed by field name.
+type Record map[string]interface{}
+
+// Extractor applies a session's compiled extraction schemas to pages.
+type Extractor struct {
+    schemas []*schema
+}
+
+type schema struct {
+    name   string
+    scope  *selector
+    urls   *regexp.Regexp
+    fields []*field
+}
+
+type field struct {
+    name       string
+    selector   *selector
+    attribute  string
+    multiple   bool
+    required   bool
+    transforms []transform
+}
+
+// selector is a compiled CSS or XPath expression.
+type selector struct {
+    css   cascadia.Selector
+    xpath *xpath.Expr
+}
+
+// New compiles schemas up front so that invalid selectors, patterns and
+// transforms are rejected when a session is created rather than per page.
+func New(schemas []models.ExtractionSchema) (*Extractor, error) {
+    e := &Extractor{}
+    names := make(map[string]bool)
+
+    for _, s := range schemas {
+        if s.Name == "" {
+            return nil, fmt.Errorf("extraction schema without a name")
+        }
+        if names[s.Name] {
+            return nil, fmt.Errorf("duplicate extraction schema %q", s.Name)
+        }
+        names[s.Name] = true
+
+        compiled := &schema{name: s.Name}
+        if s.Selector != "" {
+            scope, err := compileSelector(s.Selector, s.SelectorType)
+            if err != nil {
+                return nil, fmt.Errorf("schema %s: %v", s.Name, err)
+            }
+            compiled.scope = scope
+        }
+        if s.URLPattern != "" {
+            urls, err := regexp.Compile(s.URLPattern)
+            if err != nil {
+                return nil, fmt.Errorf("schema %s: invalid url_pattern: %v", s.Name, err)
+            }
+            compiled.urls = urls
+        }
+        if len(s.Fields) == 0 {
+            return nil, fmt.Errorf("schema %s has no fields", s.Name)
+        }
+
+        for _, f := range s.Fields {
+            if f.Name == "" {
+                return nil, fmt.Errorf("schema %s: field without a name", s.Name)
+            }
+            sel, err := compileSelector(f.Selector, f.SelectorType)
+            if err != nil {
+                return nil, fmt.Errorf("schema %s, field %s: %v", s.Name, f.Name, err)
+            }
+            transforms, err := compileTransforms(f.Transforms)
+            if err != nil {
+                return nil, fmt.Errorf("schema %s, field %s: %v", s.Name, f.Name, err)
+            }
+            compiled.fields = append(compiled.fields, &field{
+                name:       f.Name,
+                selector:   sel,
+                attribute:  f.Attribute,
+                multiple:   f.Multiple,
+                required:   f.Required,
+                transforms: transforms,
+            })
+        }
+
+        e.schemas = append(e.schemas, compiled)
+    }
+
+    return e, nil
+}
+
+func compileSelector(expr, selectorType string) (*selector, error) {
+    if strings.TrimSpace(expr) == "" {
+        return nil, fmt.Errorf("empty selector")
+    }
+    switch selectorType {
+    case "", "css":
+        css, err := cascadia.Compile(expr)
+        if err != nil {
+            return nil, fmt.Errorf("invalid CSS selector %q: %v", expr, err)
+        }
+        return &selector{css: css}, nil
+    case "xpath":
+        xp, err := xpath.Compile(expr)
+        if err != nil {
+            return nil, fmt.Errorf("invalid XPath %q: %v", expr, err)
+        }
+        return &selector{xpath: xp}, nil
+    default:
+        return nil, fmt.Errorf("unknown selector type %q", selectorType)
+    }
+}
+
+func (s *selector) find(node *html.Node) []*html.Node {
+    if s.xpath != nil {
+        return htmlquery.QuerySelectorAll(node, s.xpath)
+    }
+    return s.css.MatchAll(node)
+}
+
+// Empty reports whether there is nothing to extract.
+func (e *Extractor) Empty() bool {
+    return e == nil || len(e.schemas) == 0
+}
+
+// Extract applies every schema matching pageURL to an HTML body and returns
+// the records per schema name. Records missing a required field are
+// dropped.
+func (e *Extractor) Extract(pageURL string, body []byte) (map[string][]Record, error) {
+    if e.Empty() {
+        return nil, nil
+    }
+
+    root, err := html.Parse(bytes.NewReader(body))
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse HTML: %v", err)
+    }
+
+    results := make(map[string][]Record)
+    for _, s := range e.schemas {
+        if s.urls != nil && !s.urls.MatchString(pageURL) {
+            continue
+        }
+
+        scopes := []*html.Node{root}
+        if s.scope != nil {
+            scopes = s.scope.find(root)
+        }
+
+        records := make([]Record, 0, len(scopes))
+        for _, scope := range scopes {
+            if record, ok := s.extract(pageURL, scope); ok {
+                records = append(records, record)
+            }
+        }
+        results[s.name] = records
+    }
+
+    return results, nil
+}
+
+func (s *schema) extract(pageURL string, scope *html.Node) (Record, bool) {
+    record := make(Record, len(s.fields))
+    for _, f := range s.fields {
+        var values []interface{}
+        for _, node := range f.selector.find(scope) {
+            value, ok := applyTransforms(nodeValue(node, f.attribute), f.transforms, pageURL)
+            if !ok {
+                continue
+            }
+            values = append(values, value)
+            if !f.multiple {
+                break
+            }
+        }
+
+        switch {
+        case f.multiple:
+            if values == nil {
+                values = []interface{}{}
+            }
+            record[f.name] = values
+        case len(values) > 0:
+            record[f.name] = values[0]
+        default:
+            record[f.name] = nil
+        }
+
+        if f.required && len(values) == 0 {
+            return nil, false
+        }
+    }
+    return record, true
+}
+
+// nodeValue reads the text, inner HTML or an attribute of a node.
+func nodeValue(node *html.Node, attribute string) string {
+    switch attribute {
+    case "", "text":
+        // XPath attribute steps such as //a/@href yield nodes whose text
+        // is the attribute value
+        return htmlquery.InnerText(node)
+    case "html":
+        return htmlquery.OutputHTML(node, false)
+    default:
+        return htmlquery.SelectAttr(node, attribute)
+    }
+}
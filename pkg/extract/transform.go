@@ -0,0 +1,157 @@
+// pkg/extract/transform.go
+package extract
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strconv"
+    "strings"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+// transform turns an extracted value into another; ok is false when the
+// value should be dropped.
+type transform func(value interface{}, pageURL string) (interface{}, bool)
+
+var (
+    whitespace   = regexp.MustCompile(`\s+`)
+    numberChars  = regexp.MustCompile(`[^0-9.,\-]`)
+    defaultDates = []string{
+        time.RFC3339, time.RFC1123Z, time.RFC1123, time.RFC850,
+        "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02",
+        "January 2, 2006", "Jan 2, 2006", "2 January 2006", "02/01/2006",
+    }
+)
+
+func compileTransforms(specs []models.ExtractionTransform) ([]transform, error) {
+    transforms := make([]transform, 0, len(specs))
+    for _, spec := range specs {
+        t, err := compileTransform(spec)
+        if err != nil {
+            return nil, err
+        }
+        transforms = append(transforms, t)
+    }
+    return transforms, nil
+}
+
+func compileTransform(spec models.ExtractionTransform) (transform, error) {
+    switch spec.Type {
+    case "trim":
+        return stringTransform(func(s string) string {
+            return strings.TrimSpace(whitespace.ReplaceAllString(s, " "))
+        }), nil
+    case "lower":
+        return stringTransform(strings.ToLower), nil
+    case "upper":
+        return stringTransform(strings.ToUpper), nil
+    case "regex":
+        re, err := regexp.Compile(spec.Pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid regex %q: %v", spec.Pattern, err)
+        }
+        group := spec.Group
+        if group == 0 && re.NumSubexp() > 0 {
+            group = 1
+        }
+        if group > re.NumSubexp() {
+            return nil, fmt.Errorf("regex %q has no group %d", spec.Pattern, group)
+        }
+        return func(value interface{}, _ string) (interface{}, bool) {
+            m := re.FindStringSubmatch(toString(value))
+            if m == nil {
+                return nil, false
+            }
+            return m[group], true
+        }, nil
+    case "replace":
+        re, err := regexp.Compile(spec.Pattern)
+        if err != nil {
+            return nil, fmt.Errorf("invalid regex %q: %v", spec.Pattern, err)
+        }
+        return stringTransform(func(s string) string {
+            return re.ReplaceAllString(s, spec.Replacement)
+        }), nil
+    case "number":
+        return func(value interface{}, _ string) (interface{}, bool) {
+            n, err := parseNumber(toString(value))
+            return n, err == nil
+        }, nil
+    case "date":
+        layouts := defaultDates
+        if spec.Layout != "" {
+            layouts = []string{spec.Layout}
+        }
+        return func(value interface{}, _ string) (interface{}, bool) {
+            s := strings.TrimSpace(toString(value))
+            for _, layout := range layouts {
+                if t, err := time.Parse(layout, s); err == nil {
+                    return t.UTC().Format(time.RFC3339), true
+                }
+            }
+            return nil, false
+        }, nil
+    case "url":
+        return func(value interface{}, pageURL string) (interface{}, bool) {
+            base, err := url.Parse(pageURL)
+            if err != nil {
+                return nil, false
+            }
+            u, err := base.Parse(strings.TrimSpace(toString(value)))
+            if err != nil {
+                return nil, false
+            }
+            return u.String(), true
+        }, nil
+    default:
+        return nil, fmt.Errorf("unknown transform %q", spec.Type)
+    }
+}
+
+func stringTransform(fn func(string) string) transform {
+    return func(value interface{}, _ string) (interface{}, bool) {
+        return fn(toString(value)), true
+    }
+}
+
+func applyTransforms(value string, transforms []transform, pageURL string) (interface{}, bool) {
+    var v interface{} = value
+    for _, t := range transforms {
+        var ok bool
+        if v, ok = t(v, pageURL); !ok {
+            return nil, false
+        }
+    }
+    return v, true
+}
+
+func toString(value interface{}) string {
+    switch v := value.(type) {
+    case string:
+        return v
+    case float64:
+        return strconv.FormatFloat(v, 'f', -1, 64)
+    default:
+        return fmt.Sprint(v)
+    }
+}
+
+// parseNumber reads numbers such as "$1,299.00" or "1.299,00 €", treating
+// the last of '.' and ',' as the decimal separator when both appear.
+func parseNumber(s string) (float64, error) {
+    s = numberChars.ReplaceAllString(s, "")
+    dot, comma := strings.LastIndex(s, "."), strings.LastIndex(s, ",")
+    switch {
+    case dot >= 0 && comma >= 0 && comma > dot:
+        s = strings.ReplaceAll(s, ".", "")
+        s = strings.Replace(s, ",", ".", 1)
+    case comma >= 0 && dot < 0 && len(s)-comma-1 != 3:
+        // A lone comma not followed by exactly three digits is decimal
+        s = strings.Replace(s, ",", ".", 1)
+    }
+    s = strings.ReplaceAll(s, ",", "")
+    return strconv.ParseFloat(s, 64)
+}
@@ -0,0 +1,108 @@
+// pkg/extract/transform_test.go
+package extract
+
+import (
+    "testing"
+
+    "crawler666/internal/models"
+)
+
+func TestParseNumber(t *testing.T) {
+    tests := []struct {
+        in   string
+        want float64
+    }{
+        {"12", 12},
+        {"-3.5", -3.5},
+        {"$1,299.00", 1299},
+        {"1.299,00 €", 1299},
+        {"1.234.567,89", 1234567.89},
+        {"1,234,567", 1234567},
+        {"1,299", 1299},
+        {"1,5", 1.5},
+        {"12,50 EUR", 12.5},
+        {"Price: 0.99", 0.99},
+    }
+
+    for _, tt := range tests {
+        got, err := parseNumber(tt.in)
+        if err != nil {
+            t.Errorf("parseNumber(%q): %v", tt.in, err)
+            continue
+        }
+        if got != tt.want {
+            t.Errorf("parseNumber(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+
+    for _, in := range []string{"", "free", "--"} {
+        if got, err := parseNumber(in); err == nil {
+            t.Errorf("parseNumber(%q) = %v, want an error", in, got)
+        }
+    }
+}
+
+func TestTransforms(t *testing.T) {
+    const pageURL = "https://example.com/shop/item"
+
+    tests := []struct {
+        name   string
+        specs  []models.ExtractionTransform
+        in     string
+        want   interface{}
+        wantOK bool
+    }{
+        {"trim collapses whitespace", []models.ExtractionTransform{{Type: "trim"}}, "  a \n\t b  ", "a b", true},
+        {"lower", []models.ExtractionTransform{{Type: "lower"}}, "MiXed", "mixed", true},
+        {"upper", []models.ExtractionTransform{{Type: "upper"}}, "MiXed", "MIXED", true},
+        {"regex defaults to first group", []models.ExtractionTransform{{Type: "regex", Pattern: `(\d+) reviews`}}, "Read 123 reviews", "123", true},
+        {"regex picks group", []models.ExtractionTransform{{Type: "regex", Pattern: `(\w+)-(\w+)`, Group: 2}}, "sku-42x", "42x", true},
+        {"regex without groups", []models.ExtractionTransform{{Type: "regex", Pattern: `\d+`}}, "abc 77 def", "77", true},
+        {"regex miss drops value", []models.ExtractionTransform{{Type: "regex", Pattern: `\d+`}}, "none", nil, false},
+        {"replace", []models.ExtractionTransform{{Type: "replace", Pattern: `\s*\|.*$`, Replacement: ""}}, "Title | Site", "Title", true},
+        {"number", []models.ExtractionTransform{{Type: "number"}}, "$1,299.00", 1299.0, true},
+        {"number drops text", []models.ExtractionTransform{{Type: "number"}}, "sold out", nil, false},
+        {"date default layouts", []models.ExtractionTransform{{Type: "date"}}, " May 6, 2024 ", "2024-05-06T00:00:00Z", true},
+        {"date to UTC", []models.ExtractionTransform{{Type: "date"}}, "2024-05-06T10:00:00+02:00", "2024-05-06T08:00:00Z", true},
+        {"date custom layout", []models.ExtractionTransform{{Type: "date", Layout: "02.01.2006"}}, "06.05.2024", "2024-05-06T00:00:00Z", true},
+        {"date custom layout only", []models.ExtractionTransform{{Type: "date", Layout: "02.01.2006"}}, "2024-05-06", nil, false},
+        {"url resolves against page", []models.ExtractionTransform{{Type: "url"}}, " ../img/a.png ", "https://example.com/img/a.png", true},
+        {"url keeps absolute", []models.ExtractionTransform{{Type: "url"}}, "https://cdn.example.net/a.png", "https://cdn.example.net/a.png", true},
+        {"chain feeds each step", []models.ExtractionTransform{
+            {Type: "regex", Pattern: `Price: (.+)`},
+            {Type: "number"},
+        }, "Price: 1.299,00 €", 1299.0, true},
+        {"chain stops at first drop", []models.ExtractionTransform{
+            {Type: "regex", Pattern: `Price: (.+)`},
+            {Type: "upper"},
+        }, "no price", nil, false},
+        {"number back to text", []models.ExtractionTransform{{Type: "number"}, {Type: "upper"}}, "2.50", "2.5", true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            transforms, err := compileTransforms(tt.specs)
+            if err != nil {
+                t.Fatalf("compileTransforms: %v", err)
+            }
+            got, ok := applyTransforms(tt.in, transforms, pageURL)
+            if ok != tt.wantOK || got != tt.want {
+                t.Errorf("applyTransforms(%q) = %v (%T), %v, want %v (%T), %v", tt.in, got, got, ok, tt.want, tt.want, tt.wantOK)
+            }
+        })
+    }
+}
+
+func TestCompileTransformErrors(t *testing.T) {
+    tests := []models.ExtractionTransform{
+        {Type: "reverse"},
+        {Type: "regex", Pattern: "("},
+        {Type: "regex", Pattern: `(\d+)`, Group: 2},
+        {Type: "replace", Pattern: "[a-"},
+    }
+    for _, spec := range tests {
+        if _, err := compileTransform(spec); err == nil {
+            t.Errorf("compileTransform(%+v) succeeded, want an error", spec)
+        }
+    }
+}
@@ -0,0 +1,91 @@
+// pkg/fingerprint/fingerprint.go
+package fingerprint
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "hash/fnv"
+    "math/bits"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// shingleSize is the number of consecutive words hashed together, so word
+// order matters but a single edit only disturbs a few features.
+const shingleSize = 3
+
+// Fingerprint identifies the text of a page. Hash changes with any edit;
+// SimHash changes in proportion to how much of the text was edited.
+type Fingerprint struct {
+    Hash    string
+    SimHash uint64
+}
+
+// Compute fingerprints text after collapsing whitespace and case, so that
+// reformatting alone does not count as a change.
+func Compute(text string) Fingerprint {
+    words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+        return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+    })
+
+    sum := sha256.Sum256([]byte(strings.Join(words, " ")))
+    return Fingerprint{
+        Hash:    hex.EncodeToString(sum[:]),
+        SimHash: simHash(words),
+    }
+}
+
+func simHash(words []string) uint64 {
+    if len(words) == 0 {
+        return 0
+    }
+
+    var weights [64]int
+    size := shingleSize
+    if len(words) < size {
+        size = len(words)
+    }
+    for i := 0; i+size <= len(words); i++ {
+        h := fnv.New64a()
+        h.Write([]byte(strings.Join(words[i:i+size], " ")))
+        feature := h.Sum64()
+        for bit := 0; bit < 64; bit++ {
+            if feature&(1<<uint(bit)) != 0 {
+                weights[bit]++
+            } else {
+                weights[bit]--
+            }
+        }
+    }
+
+    var hash uint64
+    for bit, weight := range weights {
+        if weight > 0 {
+            hash |= 1 << uint(bit)
+        }
+    }
+    return hash
+}
+
+// Distance is the number of differing SimHash bits; near-duplicates are a
+// few bits apart.
+func Distance(a, b uint64) int {
+    return bits.OnesCount64(a ^ b)
+}
+
+// FormatSimHash renders a SimHash as 16 hex digits, which survives JSON
+// and BSON unlike a uint64.
+func FormatSimHash(hash uint64) string {
+    return fmt.Sprintf("%016x", hash)
+}
+
+// ParseSimHash reverses FormatSimHash.
+func ParseSimHash(s string) (uint64, error) {
+    hash, err := strconv.ParseUint(s, 16, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid simhash %q: %v", s, err)
+    }
+    return hash, nil
+}
@@ -0,0 +1,111 @@
+// pkg/fingerprint/fingerprint_test.go
+package fingerprint
+
+import (
+    "strings"
+    "testing"
+)
+
+const article = `The quick brown fox jumps over the lazy dog while the farmer sleeps
+in the barn next to the old red tractor that has not run in years and the
+chickens wander across the yard looking for seeds between the stones of the
+path that leads down to the river where the children used to swim`
+
+func TestComputeIgnoresFormatting(t *testing.T) {
+    base := Compute(article)
+
+    tests := []struct {
+        name string
+        text string
+    }{
+        {"case", strings.ToUpper(article)},
+        {"whitespace", "  " + strings.Join(strings.Fields(article), "\n\t ") + "  "},
+        {"punctuation", strings.ReplaceAll(article, " the ", ", the ")},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := Compute(tt.text); got != base {
+                t.Errorf("Compute = %+v, want %+v", got, base)
+            }
+        })
+    }
+}
+
+func TestSimHashDistance(t *testing.T) {
+    base := Compute(article)
+
+    tests := []struct {
+        name     string
+        text     string
+        min, max int
+    }{
+        {"one word changed", strings.Replace(article, "lazy", "sleepy", 1), 1, 12},
+        {"sentence appended", article + " and nobody noticed", 1, 12},
+        {"unrelated text", "Quarterly revenue grew by eight percent on strong demand for cloud services in Europe and Asia", 16, 64},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            other := Compute(tt.text)
+            if other.Hash == base.Hash {
+                t.Fatal("edited text kept its hash")
+            }
+            if d := Distance(base.SimHash, other.SimHash); d < tt.min || d > tt.max {
+                t.Errorf("Distance = %d, want within [%d, %d]", d, tt.min, tt.max)
+            }
+        })
+    }
+}
+
+func TestComputeShortTexts(t *testing.T) {
+    if got := Compute(""); got.SimHash != 0 {
+        t.Errorf("empty text SimHash = %x, want 0", got.SimHash)
+    }
+    if got := Compute("!!! ..."); got != Compute("") {
+        t.Errorf("text without words = %+v, want the empty fingerprint", got)
+    }
+    if a, b := Compute("hello"), Compute("hello world"); a.SimHash == 0 || a.SimHash == b.SimHash {
+        t.Errorf("SimHash of short texts = %x, %x, want distinct non-zero hashes", a.SimHash, b.SimHash)
+    }
+}
+
+func TestDistance(t *testing.T) {
+    tests := []struct {
+        a, b uint64
+        want int
+    }{
+        {0, 0, 0},
+        {0, 1, 1},
+        {0xff, 0x0f, 4},
+        {0, ^uint64(0), 64},
+    }
+    for _, tt := range tests {
+        if got := Distance(tt.a, tt.b); got != tt.want {
+            t.Errorf("Distance(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+        }
+    }
+}
+
+func TestSimHashFormat(t *testing.T) {
+    tests := []struct {
+        hash uint64
+        text string
+    }{
+        {0, "0000000000000000"},
+        {0xabc, "0000000000000abc"},
+        {^uint64(0), "ffffffffffffffff"},
+    }
+    for _, tt := range tests {
+        if got := FormatSimHash(tt.hash); got != tt.text {
+            t.Errorf("FormatSimHash(%x) = %q, want %q", tt.hash, got, tt.text)
+        }
+        if got, err := ParseSimHash(tt.text); err != nil || got != tt.hash {
+            t.Errorf("ParseSimHash(%q) = %x, %v, want %x", tt.text, got, err, tt.hash)
+        }
+    }
+
+    for _, text := range []string{"", "xyz", "10000000000000000"} {
+        if _, err := ParseSimHash(text); err == nil {
+            t.Errorf("ParseSimHash(%q) succeeded, want an error", text)
+        }
+    }
+}
@@ -0,0 +1,360 @@
+// pkg/frontier/frontier.go
+package frontier
+
+import (
+    "container/heap"
+    "sync"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+// ReadyFunc returns when host may next be fetched on behalf of a session.
+type ReadyFunc func(host, sessionID string) time.Time
+
+type Config struct {
+    // HostConcurrency caps the tasks of one host that are dispatched and
+    // not yet reported Done.
+    HostConcurrency int
+    Ready           ReadyFunc
+}
+
+// Frontier holds leased tasks in one queue per session and host, ordered
+// by priority. Pop serves sessions by smooth weighted round-robin and,
+// within a session, the host whose next allowed fetch comes first, so a
+// large or slow site cannot starve the others.
+type Frontier struct {
+    config   *Config
+    mu       sync.Mutex
+    sessions map[string]*sessionQueue
+    hosts    map[string]*hostState
+    ids      map[string]bool
+    size     int
+    wake     chan struct{}
+}
+
+type sessionQueue struct {
+    id      string
+    weight  int
+    current int
+    queues  map[string]*hostQueue
+    ready   readyHeap
+}
+
+// hostQueue is one session's tasks for one host.
+type hostQueue struct {
+    host    string
+    session *sessionQueue
+    tasks   taskHeap
+    readyAt time.Time
+    index   int
+    parked  bool
+}
+
+type hostState struct {
+    inFlight int
+    tasks    int
+    parked   []*hostQueue
+}
+
+func New(config *Config) *Frontier {
+    if config.HostConcurrency < 1 {
+        config.HostConcurrency = 1
+    }
+    return &Frontier{
+        config:   config,
+        sessions: make(map[string]*sessionQueue),
+        hosts:    make(map[string]*hostState),
+        ids:      make(map[string]bool),
+        wake:     make(chan struct{}, 1),
+    }
+}
+
+// Push adds a task under its politeness key. A session's weight is taken
+// from its latest push; tasks already held are ignored.
+func (f *Frontier) Push(task *models.CrawlTask, host string, weight int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    if f.ids[task.ID] {
+        return
+    }
+    f.ids[task.ID] = true
+    f.size++
+
+    if weight < 1 {
+        weight = 1
+    }
+    s := f.sessions[task.SessionID]
+    if s == nil {
+        s = &sessionQueue{id: task.SessionID, queues: make(map[string]*hostQueue)}
+        f.sessions[task.SessionID] = s
+    }
+    s.weight = weight
+
+    h := f.host(host)
+    h.tasks++
+
+    q := s.queues[host]
+    if q == nil {
+        q = &hostQueue{host: host, session: s, index: -1}
+        s.queues[host] = q
+    }
+    heap.Push(&q.tasks, task)
+    if q.index >= 0 {
+        heap.Fix(&s.ready, q.index)
+    } else if !q.parked {
+        if h.inFlight >= f.config.HostConcurrency {
+            q.parked = true
+            h.parked = append(h.parked, q)
+        } else {
+            heap.Push(&s.ready, q)
+        }
+    }
+    f.signal()
+}
+
+// Pop removes the next task that may be dispatched now, together with its
+// politeness key. The caller must report the task Done once it finished
+// or was dropped.
+func (f *Frontier) Pop(now time.Time) (*models.CrawlTask, string, bool) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    var best *sessionQueue
+    total := 0
+    for _, s := range f.sessions {
+        if !f.settle(s, now) {
+            continue
+        }
+        s.current += s.weight
+        total += s.weight
+        if best == nil || s.current > best.current {
+            best = s
+        }
+    }
+    if best == nil {
+        return nil, "", false
+    }
+    best.current -= total
+
+    q := best.ready[0]
+    task := heap.Pop(&q.tasks).(*models.CrawlTask)
+    delete(f.ids, task.ID)
+    f.size--
+
+    h := f.hosts[q.host]
+    h.inFlight++
+    h.tasks--
+    if q.tasks.Len() == 0 {
+        heap.Remove(&best.ready, q.index)
+        delete(best.queues, q.host)
+        if len(best.queues) == 0 {
+            delete(f.sessions, best.id)
+        }
+    } else if h.inFlight >= f.config.HostConcurrency {
+        heap.Remove(&best.ready, q.index)
+        q.parked = true
+        h.parked = append(h.parked, q)
+    }
+    return task, q.host, true
+}
+
+// settle brings the head of a session's ready heap up to date and reports
+// whether it may be dispatched now. Readiness is shared between sessions,
+// so a stored ready time may have gone stale.
+func (f *Frontier) settle(s *sessionQueue, now time.Time) bool {
+    for s.ready.Len() > 0 {
+        q := s.ready[0]
+        h := f.hosts[q.host]
+        if h.inFlight >= f.config.HostConcurrency {
+            heap.Pop(&s.ready)
+            q.parked = true
+            h.parked = append(h.parked, q)
+            continue
+        }
+        if q.readyAt.After(now) {
+            return false
+        }
+        readyAt := now
+        if f.config.Ready != nil {
+            readyAt = f.config.Ready(q.host, s.id)
+        }
+        if readyAt.After(now) {
+            q.readyAt = readyAt
+            heap.Fix(&s.ready, 0)
+            continue
+        }
+        return true
+    }
+    return false
+}
+
+// Done reports that a popped task of host finished or was dropped, which
+// frees a dispatch slot for the host.
+func (f *Frontier) Done(host string) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    h, exists := f.hosts[host]
+    if !exists {
+        return
+    }
+    if h.inFlight > 0 {
+        h.inFlight--
+    }
+    for _, q := range h.parked {
+        q.parked = false
+        if _, live := q.session.queues[q.host]; live {
+            heap.Push(&q.session.ready, q)
+        }
+    }
+    h.parked = nil
+    if h.inFlight == 0 && h.tasks == 0 {
+        delete(f.hosts, host)
+    }
+    f.signal()
+}
+
+// RemoveSession drops every task held for a session and returns their IDs.
+func (f *Frontier) RemoveSession(sessionID string) []string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    s, exists := f.sessions[sessionID]
+    if !exists {
+        return nil
+    }
+    delete(f.sessions, sessionID)
+
+    var ids []string
+    for host, q := range s.queues {
+        h := f.hosts[host]
+        for _, task := range q.tasks {
+            ids = append(ids, task.ID)
+            delete(f.ids, task.ID)
+        }
+        f.size -= q.tasks.Len()
+        h.tasks -= q.tasks.Len()
+        if q.parked {
+            for i, p := range h.parked {
+                if p == q {
+                    h.parked = append(h.parked[:i], h.parked[i+1:]...)
+                    break
+                }
+            }
+        }
+        if h.inFlight == 0 && h.tasks == 0 {
+            delete(f.hosts, host)
+        }
+    }
+    return ids
+}
+
+// NextReady returns the earliest known time a held task may become ready;
+// ok is false when no task is waiting on a ready time.
+func (f *Frontier) NextReady() (time.Time, bool) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    var next time.Time
+    for _, s := range f.sessions {
+        if s.ready.Len() == 0 {
+            continue
+        }
+        if at := s.ready[0].readyAt; next.IsZero() || at.Before(next) {
+            next = at
+        }
+    }
+    return next, !next.IsZero()
+}
+
+// Wake is signalled whenever tasks are pushed or a host frees a slot.
+func (f *Frontier) Wake() <-chan struct{} {
+    return f.wake
+}
+
+// Len returns the number of tasks held.
+func (f *Frontier) Len() int {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.size
+}
+
+// FullHosts returns the hosts holding at least limit tasks.
+func (f *Frontier) FullHosts(limit int) []string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    var hosts []string
+    for host, h := range f.hosts {
+        if h.tasks >= limit {
+            hosts = append(hosts, host)
+        }
+    }
+    return hosts
+}
+
+func (f *Frontier) host(key string) *hostState {
+    h := f.hosts[key]
+    if h == nil {
+        h = &hostState{}
+        f.hosts[key] = h
+    }
+    return h
+}
+
+func (f *Frontier) signal() {
+    select {
+    case f.wake <- struct{}{}:
+    default:
+    }
+}
+
+// taskHeap orders tasks by priority, then age.
+type taskHeap []*models.CrawlTask
+
+func (h taskHeap) Len() int { return len(h) }
+func (h taskHeap) Less(i, j int) bool {
+    if h[i].Priority != h[j].Priority {
+        return h[i].Priority > h[j].Priority
+    }
+    return h[i].CreatedAt.Before(h[j].CreatedAt)
+}
+func (h taskHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *taskHeap) Push(x interface{}) { *h = append(*h, x.(*models.CrawlTask)) }
+func (h *taskHeap) Pop() interface{} {
+    old := *h
+    task := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return task
+}
+
+// readyHeap orders a session's host queues by ready time, then by the
+// priority of their best task.
+type readyHeap []*hostQueue
+
+func (h readyHeap) Len() int { return len(h) }
+func (h readyHeap) Less(i, j int) bool {
+    if !h[i].readyAt.Equal(h[j].readyAt) {
+        return h[i].readyAt.Before(h[j].readyAt)
+    }
+    return h[i].tasks[0].Priority > h[j].tasks[0].Priority
+}
+func (h readyHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
+}
+func (h *readyHeap) Push(x interface{}) {
+    q := x.(*hostQueue)
+    q.index = len(*h)
+    *h = append(*h, q)
+}
+func (h *readyHeap) Pop() interface{} {
+    old := *h
+    q := old[len(old)-1]
+    q.index = -1
+    *h = old[:len(old)-1]
+    return q
+}
@@ -0,0 +1,263 @@
+// pkg/frontier/frontier_test.go
+package frontier
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+var epoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func task(id, sessionID string, priority int, age time.Duration) *models.CrawlTask {
+    return &models.CrawlTask{ID: id, SessionID: sessionID, Priority: priority, CreatedAt: epoch.Add(-age)}
+}
+
+// drain pops until nothing is ready at now, reporting each task done.
+func drain(f *Frontier, now time.Time) []string {
+    var ids []string
+    for {
+        t, host, ok := f.Pop(now)
+        if !ok {
+            return ids
+        }
+        ids = append(ids, t.ID)
+        f.Done(host)
+    }
+}
+
+func TestPopOrdersByPriorityThenAge(t *testing.T) {
+    f := New(&Config{})
+    f.Push(task("low", "s", 1, 3*time.Minute), "a.example", 1)
+    f.Push(task("high-new", "s", 9, time.Minute), "a.example", 1)
+    f.Push(task("high-old", "s", 9, 2*time.Minute), "a.example", 1)
+    f.Push(task("mid", "s", 5, 0), "a.example", 1)
+
+    want := "high-old,high-new,mid,low"
+    if got := strings.Join(drain(f, epoch), ","); got != want {
+        t.Errorf("order = %s, want %s", got, want)
+    }
+    if f.Len() != 0 {
+        t.Errorf("Len = %d after draining", f.Len())
+    }
+}
+
+func TestPopFollowsReadyTimes(t *testing.T) {
+    readyAt := map[string]time.Time{
+        "slow.example": epoch.Add(2 * time.Second),
+        "fast.example": epoch,
+        "mid.example":  epoch.Add(time.Second),
+    }
+    f := New(&Config{
+        HostConcurrency: 1,
+        Ready:           func(host, _ string) time.Time { return readyAt[host] },
+    })
+    f.Push(task("slow", "s", 9, 0), "slow.example", 1)
+    f.Push(task("fast", "s", 1, 0), "fast.example", 1)
+    f.Push(task("mid", "s", 5, 0), "mid.example", 1)
+
+    steps := []struct {
+        now  time.Time
+        want string
+        next time.Time
+    }{
+        {epoch, "fast", epoch.Add(time.Second)},
+        {epoch.Add(500 * time.Millisecond), "", epoch.Add(time.Second)},
+        {epoch.Add(time.Second), "mid", epoch.Add(2 * time.Second)},
+        {epoch.Add(3 * time.Second), "slow", time.Time{}},
+    }
+    for i, step := range steps {
+        got := strings.Join(drain(f, step.now), ",")
+        if got != step.want {
+            t.Errorf("step %d: popped %q, want %q", i, got, step.want)
+        }
+        next, ok := f.NextReady()
+        if ok != !step.next.IsZero() || !next.Equal(step.next) {
+            t.Errorf("step %d: NextReady = %v, %v, want %v", i, next, ok, step.next)
+        }
+    }
+}
+
+func TestReadyTimeFollowsFetches(t *testing.T) {
+    // Another session's fetch may push the shared host back after a pop
+    readyAt := epoch
+    f := New(&Config{Ready: func(string, string) time.Time { return readyAt }})
+    f.Push(task("a", "s", 1, 0), "a.example", 1)
+    if got := drain(f, epoch); len(got) != 1 {
+        t.Fatalf("popped %v, want one task", got)
+    }
+
+    f.Push(task("b", "s", 1, 0), "a.example", 1)
+    readyAt = epoch.Add(time.Minute)
+    if got := drain(f, epoch); len(got) != 0 {
+        t.Errorf("popped %v before the host was ready again", got)
+    }
+    if got := drain(f, readyAt); len(got) != 1 {
+        t.Errorf("popped %v once the host was ready, want one task", got)
+    }
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+    tests := []struct {
+        weights map[string]int
+        pops    int
+        want    map[string]int
+        order   string
+    }{
+        {map[string]int{"A": 2, "B": 1}, 6, map[string]int{"A": 4, "B": 2}, "ABAABA"},
+        {map[string]int{"A": 3, "B": 1}, 8, map[string]int{"A": 6, "B": 2}, ""},
+        {map[string]int{"A": 1, "B": 1, "C": 1}, 9, map[string]int{"A": 3, "B": 3, "C": 3}, ""},
+        {map[string]int{"A": 5, "B": 0}, 6, map[string]int{"A": 5, "B": 1}, ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(fmt.Sprint(tt.weights), func(t *testing.T) {
+            f := New(&Config{})
+            for session, weight := range tt.weights {
+                for i := 0; i < tt.pops; i++ {
+                    // One host per task keeps politeness out of the way
+                    f.Push(task(fmt.Sprintf("%s%d", session, i), session, 5, 0), fmt.Sprintf("%s%d.example", session, i), weight)
+                }
+            }
+
+            counts := make(map[string]int)
+            var order strings.Builder
+            for i := 0; i < tt.pops; i++ {
+                got, _, ok := f.Pop(epoch)
+                if !ok {
+                    t.Fatalf("pop %d found nothing", i)
+                }
+                counts[got.SessionID]++
+                order.WriteString(got.SessionID)
+            }
+            for session, want := range tt.want {
+                if counts[session] != want {
+                    t.Errorf("session %s popped %d times, want %d (order %s)", session, counts[session], want, order.String())
+                }
+            }
+            if tt.order != "" && order.String() != tt.order {
+                t.Errorf("order = %s, want %s", order.String(), tt.order)
+            }
+        })
+    }
+}
+
+func TestHostConcurrency(t *testing.T) {
+    f := New(&Config{HostConcurrency: 2})
+    for i := 0; i < 3; i++ {
+        f.Push(task(fmt.Sprintf("s%d", i), "s", 5, 0), "shared.example", 1)
+        f.Push(task(fmt.Sprintf("t%d", i), "t", 5, 0), "shared.example", 1)
+    }
+
+    popped := 0
+    for {
+        if _, _, ok := f.Pop(epoch); !ok {
+            break
+        }
+        popped++
+    }
+    if popped != 2 {
+        t.Fatalf("popped %d tasks of one host, want its 2 slots", popped)
+    }
+
+    f.Done("shared.example")
+    if _, _, ok := f.Pop(epoch); !ok {
+        t.Fatal("a finished task should free a slot")
+    }
+    if _, _, ok := f.Pop(epoch); ok {
+        t.Fatal("popped beyond the host's slots")
+    }
+    if got := len(drain(f, epoch)); got != 0 {
+        t.Fatalf("popped %d beyond the host's slots", got)
+    }
+
+    f.Done("shared.example")
+    f.Done("shared.example")
+    if got := len(drain(f, epoch)); got != 3 {
+        t.Errorf("drained %d remaining tasks, want 3", got)
+    }
+}
+
+func TestPushIgnoresHeldTasks(t *testing.T) {
+    f := New(&Config{})
+    f.Push(task("a", "s", 1, 0), "a.example", 1)
+    f.Push(task("a", "s", 9, 0), "a.example", 1)
+    if f.Len() != 1 {
+        t.Fatalf("Len = %d, want 1", f.Len())
+    }
+    select {
+    case <-f.Wake():
+    default:
+        t.Error("Push did not signal Wake")
+    }
+
+    got, _, _ := f.Pop(epoch)
+    if got.Priority != 1 {
+        t.Errorf("held task was replaced by a duplicate push")
+    }
+    f.Push(task("a", "s", 1, 0), "a.example", 1)
+    if f.Len() != 1 {
+        t.Errorf("a popped task should be accepted again")
+    }
+}
+
+func TestRemoveSession(t *testing.T) {
+    f := New(&Config{HostConcurrency: 1})
+    f.Push(task("s1", "s", 5, 0), "shared.example", 1)
+    if _, _, ok := f.Pop(epoch); !ok {
+        t.Fatal("pop found nothing")
+    }
+
+    // The shared host is now full, so popping parks the queues behind it
+    f.Push(task("s2", "s", 5, 0), "other.example", 1)
+    f.Push(task("t1", "t", 5, 0), "shared.example", 1)
+    f.Push(task("t2", "t", 5, 0), "shared.example", 1)
+    if got := drain(f, epoch); strings.Join(got, ",") != "s2" {
+        t.Fatalf("popped %v, want only s2", got)
+    }
+
+    removed := f.RemoveSession("t")
+    sort.Strings(removed)
+    if got := strings.Join(removed, ","); got != "t1,t2" {
+        t.Errorf("RemoveSession = %s, want t1,t2", got)
+    }
+    if f.RemoveSession("t") != nil {
+        t.Error("removing a session twice should return nothing")
+    }
+    if f.Len() != 0 {
+        t.Errorf("Len = %d, want 0", f.Len())
+    }
+
+    f.Done("shared.example")
+    if got := drain(f, epoch); len(got) != 0 {
+        t.Errorf("popped %v of a removed session", got)
+    }
+}
+
+func TestFullHosts(t *testing.T) {
+    f := New(&Config{})
+    for i := 0; i < 3; i++ {
+        f.Push(task(fmt.Sprintf("a%d", i), "s", 5, 0), "a.example", 1)
+    }
+    f.Push(task("b0", "s", 5, 0), "b.example", 1)
+
+    tests := []struct {
+        limit int
+        want  string
+    }{
+        {1, "a.example,b.example"},
+        {3, "a.example"},
+        {4, ""},
+    }
+    for _, tt := range tests {
+        hosts := f.FullHosts(tt.limit)
+        sort.Strings(hosts)
+        if got := strings.Join(hosts, ","); got != tt.want {
+            t.Errorf("FullHosts(%d) = %s, want %s", tt.limit, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,213 @@
+// pkg/parser/body.go
+package parser
+
+import (
+    "bytes"
+    "compress/flate"
+    "compress/gzip"
+    "compress/zlib"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "regexp"
+    "strings"
+    "unicode/utf8"
+
+    "github.com/andybalholm/brotli"
+    "golang.org/x/net/html/charset"
+)
+
+const DefaultMaxBodySize = 10 * 1024 * 1024
+
+// Body is a response body read in full, up to a size limit.
+type Body struct {
+    // Raw is the body as it came off the wire, still content-encoded
+    Raw             []byte
+    // Content is the decoded body, transcoded to UTF-8 for text types
+    Content         []byte
+    ContentEncoding string
+    Charset         string
+    Truncated       bool
+}
+
+// ReadBody reads resp.Body up to maxSize bytes, undoes its Content-Encoding
+// and converts text to UTF-8 from the charset textEncoding finds. maxSize
+// bounds both the raw and the decoded size; anything beyond it is dropped
+// and reported through Truncated.
+func ReadBody(resp *http.Response, maxSize int64) (*Body, error) {
+    if maxSize <= 0 {
+        maxSize = DefaultMaxBodySize
+    }
+
+    raw, truncated, err := readLimited(resp.Body, maxSize)
+    if err != nil {
+        return nil, err
+    }
+    body := &Body{Raw: raw, Truncated: truncated}
+
+    body.ContentEncoding = strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+    content, truncated, err := decodeContent(raw, body.ContentEncoding, maxSize)
+    if err != nil {
+        return nil, err
+    }
+    body.Truncated = body.Truncated || truncated
+
+    contentType := resp.Header.Get("Content-Type")
+    if contentType == "" {
+        // Sniffed text types claim UTF-8 without looking, so only the
+        // media type is kept
+        contentType, _, _ = strings.Cut(http.DetectContentType(content), ";")
+    }
+    if IsText(contentType) {
+        // A rune cut off by the size limit says nothing about the charset
+        sample := content
+        if body.Truncated {
+            sample = trimPartialRune(content)
+        }
+        body.Charset = textEncoding(sample, contentType)
+        if enc, _ := charset.Lookup(body.Charset); enc != nil && body.Charset != "utf-8" {
+            if decoded, err := enc.NewDecoder().Bytes(content); err == nil {
+                content = decoded
+            }
+        }
+        content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+    }
+    body.Content = content
+
+    return body, nil
+}
+
+var xmlEncoding = regexp.MustCompile(`^<\?xml[^>]*?\sencoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+
+// textEncoding picks the charset of a text body from, in this order, a
+// BOM, the Content-Type charset, and what the format declares: a <meta>
+// charset for HTML, the XML declaration for XML, always UTF-8 for JSON.
+// Anything else that is valid UTF-8 is taken as such; the rest falls back
+// to windows-1252 like browsers do. The result is a canonical charset name.
+func textEncoding(content []byte, contentType string) string {
+    for _, b := range boms {
+        if bytes.HasPrefix(content, b.bom) {
+            return b.name
+        }
+    }
+    mediaType, params, _ := mime.ParseMediaType(contentType)
+    if label := params["charset"]; label != "" {
+        if enc, name := charset.Lookup(label); enc != nil {
+            return name
+        }
+    }
+
+    switch {
+    case mediaType == "text/html":
+        // Sniffing only sees the first 1024 bytes, so its windows-1252
+        // guess is overruled by a body that is UTF-8 throughout
+        _, name, _ := charset.DetermineEncoding(content, mediaType)
+        if name != "windows-1252" || !utf8.Valid(content) {
+            return name
+        }
+    case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+        return "utf-8"
+    case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+        if m := xmlEncoding.FindSubmatch(content); m != nil {
+            if enc, name := charset.Lookup(string(m[1])); enc != nil {
+                return name
+            }
+        }
+    }
+
+    if utf8.Valid(content) {
+        return "utf-8"
+    }
+    return "windows-1252"
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end of content.
+func trimPartialRune(content []byte) []byte {
+    for i := 1; i < utf8.UTFMax && i <= len(content); i++ {
+        tail := content[len(content)-i:]
+        if !utf8.RuneStart(tail[0]) {
+            continue
+        }
+        if !utf8.FullRune(tail) {
+            return content[:len(content)-i]
+        }
+        break
+    }
+    return content
+}
+
+var boms = []struct {
+    bom  []byte
+    name string
+}{
+    {[]byte("\xef\xbb\xbf"), "utf-8"},
+    {[]byte("\xfe\xff"), "utf-16be"},
+    {[]byte("\xff\xfe"), "utf-16le"},
+}
+
+func readLimited(r io.Reader, maxSize int64) ([]byte, bool, error) {
+    data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+    if err != nil && len(data) == 0 {
+        return nil, false, err
+    }
+    if int64(len(data)) > maxSize {
+        return data[:maxSize], true, nil
+    }
+    // A body cut off mid-transfer is kept and reported as truncated
+    return data, err != nil, nil
+}
+
+func decodeContent(raw []byte, encoding string, maxSize int64) ([]byte, bool, error) {
+    var r io.Reader
+    switch encoding {
+    case "", "identity":
+        return raw, false, nil
+    case "gzip", "x-gzip":
+        gz, err := gzip.NewReader(bytes.NewReader(raw))
+        if err != nil {
+            return nil, false, fmt.Errorf("invalid gzip body: %v", err)
+        }
+        defer gz.Close()
+        r = gz
+    case "deflate":
+        // Servers disagree on whether deflate means zlib-wrapped or raw
+        if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+            defer zr.Close()
+            r = zr
+        } else {
+            fr := flate.NewReader(bytes.NewReader(raw))
+            defer fr.Close()
+            r = fr
+        }
+    case "br":
+        r = brotli.NewReader(bytes.NewReader(raw))
+    default:
+        return nil, false, fmt.Errorf("unsupported content encoding %q", encoding)
+    }
+
+    content, truncated, err := readLimited(r, maxSize)
+    if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+        return nil, false, err
+    }
+    return content, truncated, nil
+}
+
+// IsText reports whether a Content-Type header value denotes a textual
+// format.
+func IsText(contentType string) bool {
+    mediaType, _, err := mime.ParseMediaType(contentType)
+    if err != nil {
+        return false
+    }
+    if strings.HasPrefix(mediaType, "text/") {
+        return true
+    }
+    switch mediaType {
+    case "application/xhtml+xml", "application/xml", "application/json",
+        "application/javascript", "application/rss+xml", "application/atom+xml":
+        return true
+    }
+    return strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,129 @@
+// pkg/parser/body_test.go
+package parser
+
+import (
+    "bytes"
+    "compress/gzip"
+    "io"
+    "net/http"
+    "strings"
+    "testing"
+)
+
+func gzipped(s string) string {
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    gz.Write([]byte(s))
+    gz.Close()
+    return buf.String()
+}
+
+func TestReadBody(t *testing.T) {
+    // Pushes the first non-ASCII byte past what HTML sniffing looks at
+    pad := strings.Repeat("a", 2000)
+
+    tests := []struct {
+        name            string
+        contentType     string
+        contentEncoding string
+        raw             string
+        maxSize         int64
+        want            string
+        charset         string
+        truncated       bool
+    }{
+        {"latin-1 HTML by header", "text/html; charset=ISO-8859-1", "", "<p>caf\xe9</p>", 0, "<p>café</p>", "windows-1252", false},
+        {"latin-1 HTML by meta", "text/html", "", `<meta charset="latin1"><p>caf` + "\xe9", 0, `<meta charset="latin1"><p>café`, "windows-1252", false},
+        {"undeclared latin-1 HTML", "text/html", "", "<p>caf\xe9</p>", 0, "<p>café</p>", "windows-1252", false},
+        {"UTF-8 HTML past the sniffed prefix", "text/html", "", "<p>" + pad + "café</p>", 0, "<p>" + pad + "café</p>", "utf-8", false},
+        {"UTF-8 JSON", "application/json", "", `{"pad":"` + pad + `","name":"café"}`, 0, `{"pad":"` + pad + `","name":"café"}`, "utf-8", false},
+        {"JSON is never sniffed", "application/ld+json", "", `{"name":"caf` + "\xe9" + `"}`, 0, `{"name":"caf` + "\xe9" + `"}`, "utf-8", false},
+        {"undeclared UTF-8 XML", "application/xml", "", "<t>" + pad + "café</t>", 0, "<t>" + pad + "café</t>", "utf-8", false},
+        {"XML declaration", "application/rss+xml", "", `<?xml version="1.0" encoding="ISO-8859-1"?><t>caf` + "\xe9</t>", 0,
+            `<?xml version="1.0" encoding="ISO-8859-1"?><t>café</t>`, "windows-1252", false},
+        {"header beats XML declaration", "text/xml; charset=utf-8", "", `<?xml version='1.0' encoding='ISO-8859-1'?><t>café</t>`, 0,
+            `<?xml version='1.0' encoding='ISO-8859-1'?><t>café</t>`, "utf-8", false},
+        {"UTF-8 BOM stripped", "application/json", "", "\xef\xbb\xbf{\"a\":1}", 0, `{"a":1}`, "utf-8", false},
+        {"UTF-16 BOM beats header", "text/plain; charset=iso-8859-1", "", "\xff\xfeh\x00i\x00", 0, "hi", "utf-16le", false},
+        {"invalid UTF-8 text falls back", "text/plain", "", "caf\xe9", 0, "café", "windows-1252", false},
+        {"sniffed HTML", "", "", "<html><p>caf\xe9", 0, "<html><p>café", "windows-1252", false},
+        {"rune cut by the limit", "text/plain", "", "café", 4, "caf\xc3", "utf-8", true},
+        {"gzip", "text/plain", "gzip", gzipped("hello"), 0, "hello", "utf-8", false},
+        {"binary left alone", "image/png", "", "\x89PNG\xe9", 0, "\x89PNG\xe9", "", false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            resp := &http.Response{
+                Header: http.Header{},
+                Body:   io.NopCloser(strings.NewReader(tt.raw)),
+            }
+            if tt.contentType != "" {
+                resp.Header.Set("Content-Type", tt.contentType)
+            }
+            if tt.contentEncoding != "" {
+                resp.Header.Set("Content-Encoding", tt.contentEncoding)
+            }
+
+            body, err := ReadBody(resp, tt.maxSize)
+            if err != nil {
+                t.Fatalf("ReadBody: %v", err)
+            }
+            if string(body.Content) != tt.want {
+                t.Errorf("Content = %q, want %q", body.Content, tt.want)
+            }
+            if body.Charset != tt.charset {
+                t.Errorf("Charset = %q, want %q", body.Charset, tt.charset)
+            }
+            if body.Truncated != tt.truncated {
+                t.Errorf("Truncated = %v, want %v", body.Truncated, tt.truncated)
+            }
+            if tt.maxSize == 0 && string(body.Raw) != tt.raw {
+                t.Errorf("Raw = %q, want the body as sent", body.Raw)
+            }
+        })
+    }
+}
+
+func TestReadBodyDecodingLimit(t *testing.T) {
+    // A small compressed body must not expand past the limit
+    resp := &http.Response{
+        Header: http.Header{"Content-Encoding": {"gzip"}, "Content-Type": {"text/plain"}},
+        Body:   io.NopCloser(strings.NewReader(gzipped(strings.Repeat("x", 5000)))),
+    }
+    body, err := ReadBody(resp, 1000)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(body.Content) != 1000 || !body.Truncated {
+        t.Errorf("decoded %d bytes, truncated %v; want 1000, true", len(body.Content), body.Truncated)
+    }
+
+    resp = &http.Response{
+        Header: http.Header{"Content-Encoding": {"compress"}},
+        Body:   io.NopCloser(strings.NewReader("x")),
+    }
+    if _, err := ReadBody(resp, 0); err == nil {
+        t.Error("ReadBody accepted an unsupported content encoding")
+    }
+}
+
+func TestIsText(t *testing.T) {
+    tests := map[string]bool{
+        "text/html; charset=utf-8": true,
+        "text/css":                 true,
+        "application/json":         true,
+        "application/ld+json":      true,
+        "application/atom+xml":     true,
+        "image/svg+xml":            true,
+        "application/javascript":   true,
+        "image/png":                false,
+        "application/pdf":          false,
+        "":                         false,
+    }
+    for contentType, want := range tests {
+        if got := IsText(contentType); got != want {
+            t.Errorf("IsText(%q) = %v, want %v", contentType, got, want)
+        }
+    }
+}
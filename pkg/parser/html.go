@@ -0,0 +1,140 @@
+// pkg/parser/html.go
+package parser
+
+import (
+    "fmt"
+    "io"
+    "net/url"
+    "strings"
+
+    "github.com/PuerkitoBio/goquery"
+)
+
+type Document struct {
+    Title     string
+    Language  string
+    Canonical string
+    // Meta holds <meta name|property content> pairs, e.g. "description"
+    // or "og:title", keyed by lowercased name
+    Meta      map[string]string
+    Links     []string
+    Images    []string
+}
+
+var linkSelectors = []struct {
+    selector string
+    attr     string
+}{
+    {"a[href]", "href"},
+    {"area[href]", "href"},
+    {"frame[src]", "src"},
+    {"iframe[src]", "src"},
+}
+
+// ParseHTML extracts the outgoing links and image URLs of an HTML page.
+// Relative references are resolved against pageURL, or against the
+// document's <base href> when one is present.
+func ParseHTML(pageURL string, body io.Reader) (*Document, error) {
+    base, err := url.Parse(pageURL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid page URL: %v", err)
+    }
+
+    doc, err := goquery.NewDocumentFromReader(body)
+    if err != nil {
+        return nil, fmt.Errorf("failed to parse HTML: %v", err)
+    }
+
+    if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+        if baseHref, err := base.Parse(strings.TrimSpace(href)); err == nil {
+            base = baseHref
+        }
+    }
+
+    result := &Document{
+        Title:    strings.TrimSpace(doc.Find("title").First().Text()),
+        Language: strings.TrimSpace(doc.Find("html").AttrOr("lang", "")),
+        Meta:     make(map[string]string),
+    }
+
+    if href, ok := doc.Find("link[rel][href]").FilterFunction(func(_ int, sel *goquery.Selection) bool {
+        return hasToken(sel.AttrOr("rel", ""), "canonical")
+    }).First().Attr("href"); ok {
+        result.Canonical = resolve(base, href)
+    }
+
+    doc.Find("meta[content]").Each(func(_ int, sel *goquery.Selection) {
+        name := sel.AttrOr("name", sel.AttrOr("property", ""))
+        name = strings.ToLower(strings.TrimSpace(name))
+        if name == "" {
+            return
+        }
+        if _, exists := result.Meta[name]; !exists {
+            result.Meta[name] = strings.TrimSpace(sel.AttrOr("content", ""))
+        }
+    })
+
+    seenLinks := make(map[string]bool)
+    for _, s := range linkSelectors {
+        doc.Find(s.selector).Each(func(_ int, sel *goquery.Selection) {
+            if sel.Is("a, area") {
+                if rel, _ := sel.Attr("rel"); hasToken(rel, "nofollow") {
+                    return
+                }
+            }
+            value, _ := sel.Attr(s.attr)
+            if link := resolve(base, value); link != "" && !seenLinks[link] {
+                seenLinks[link] = true
+                result.Links = append(result.Links, link)
+            }
+        })
+    }
+
+    seenImages := make(map[string]bool)
+    doc.Find("img[src]").Each(func(_ int, sel *goquery.Selection) {
+        value, _ := sel.Attr("src")
+        if image := resolve(base, value); image != "" && !seenImages[image] {
+            seenImages[image] = true
+            result.Images = append(result.Images, image)
+        }
+    })
+
+    return result, nil
+}
+
+// IsHTML reports whether a Content-Type header value denotes an HTML document.
+func IsHTML(contentType string) bool {
+    mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+    return mediaType == "" || mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+func resolve(base *url.URL, ref string) string {
+    ref = strings.TrimSpace(ref)
+    if ref == "" || strings.HasPrefix(ref, "#") {
+        return ""
+    }
+
+    u, err := base.Parse(ref)
+    if err != nil {
+        return ""
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return ""
+    }
+    if u.Host == "" {
+        return ""
+    }
+
+    u.Fragment = ""
+    u.RawFragment = ""
+    return u.String()
+}
+
+func hasToken(list, token string) bool {
+    for _, field := range strings.Fields(strings.ToLower(list)) {
+        if field == token {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,73 @@
+// pkg/parser/html_test.go
+package parser
+
+import (
+    "net/url"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+const linkPage = `<html><head><title> Links </title><base href="/docs/"></head><body>
+<a href="intro.html#setup">intro</a>
+<a href="intro.html">intro again</a>
+<a href="https://other.example/x" rel="external nofollow">sponsor</a>
+<a href="mailto:me@example.com">mail</a>
+<a href="#top">top</a>
+<map><area href="/map"></map>
+<iframe src="//cdn.example/frame"></iframe>
+<img src="logo.png"><img src="logo.png"><img src="data:image/png;base64,AA">
+</body></html>`
+
+func TestParseHTMLLinks(t *testing.T) {
+    doc, err := ParseHTML("https://example.com/start/page", strings.NewReader(linkPage))
+    if err != nil {
+        t.Fatalf("ParseHTML failed: %v", err)
+    }
+
+    if doc.Title != "Links" {
+        t.Errorf("Title = %q, want %q", doc.Title, "Links")
+    }
+    wantLinks := []string{
+        "https://example.com/docs/intro.html",
+        "https://example.com/map",
+        "https://cdn.example/frame",
+    }
+    if !reflect.DeepEqual(doc.Links, wantLinks) {
+        t.Errorf("Links = %q, want %q", doc.Links, wantLinks)
+    }
+    wantImages := []string{"https://example.com/docs/logo.png"}
+    if !reflect.DeepEqual(doc.Images, wantImages) {
+        t.Errorf("Images = %q, want %q", doc.Images, wantImages)
+    }
+}
+
+func TestParseHTMLInvalidPageURL(t *testing.T) {
+    if _, err := ParseHTML("://broken", strings.NewReader("<a href=x>")); err == nil {
+        t.Error("ParseHTML with an invalid page URL succeeded, want an error")
+    }
+}
+
+func TestResolve(t *testing.T) {
+    base, _ := url.Parse("http://example.com/a/b")
+    tests := []struct {
+        ref  string
+        want string
+    }{
+        {"c", "http://example.com/a/c"},
+        {"  ../c  ", "http://example.com/c"},
+        {"/c?q=1#frag", "http://example.com/c?q=1"},
+        {"https://x.example", "https://x.example"},
+        {"", ""},
+        {"#frag", ""},
+        {"javascript:void(0)", ""},
+        {"ftp://example.com/file", ""},
+        {"http:///nohost", ""},
+    }
+
+    for _, tt := range tests {
+        if got := resolve(base, tt.ref); got != tt.want {
+            t.Errorf("resolve(%q) = %q, want %q", tt.ref, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,77 @@
+// pkg/parser/text.go
+package parser
+
+import (
+    "fmt"
+    "io"
+    "strings"
+
+    "golang.org/x/net/html"
+    "golang.org/x/net/html/atom"
+)
+
+var hiddenElements = map[atom.Atom]bool{
+    atom.Head:     true,
+    atom.Script:   true,
+    atom.Style:    true,
+    atom.Noscript: true,
+    atom.Template: true,
+    atom.Svg:      true,
+    atom.Iframe:   true,
+}
+
+var blockElements = map[atom.Atom]bool{
+    atom.Address: true, atom.Article: true, atom.Aside: true, atom.Blockquote: true,
+    atom.Br: true, atom.Dd: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+    atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true, atom.Footer: true,
+    atom.Form: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true,
+    atom.H5: true, atom.H6: true, atom.Header: true, atom.Hr: true, atom.Li: true,
+    atom.Main: true, atom.Nav: true, atom.Ol: true, atom.P: true, atom.Pre: true,
+    atom.Section: true, atom.Table: true, atom.Td: true, atom.Th: true, atom.Tr: true,
+    atom.Ul: true,
+}
+
+// Text returns the visible text of an HTML document with one line per
+// block element and whitespace collapsed within lines.
+func Text(body io.Reader) (string, error) {
+    root, err := html.Parse(body)
+    if err != nil {
+        return "", fmt.Errorf("failed to parse HTML: %v", err)
+    }
+
+    var lines []string
+    var line strings.Builder
+    flush := func() {
+        if text := strings.Join(strings.Fields(line.String()), " "); text != "" {
+            lines = append(lines, text)
+        }
+        line.Reset()
+    }
+
+    var walk func(n *html.Node)
+    walk = func(n *html.Node) {
+        switch n.Type {
+        case html.TextNode:
+            line.WriteString(n.Data)
+            line.WriteByte(' ')
+            return
+        case html.ElementNode:
+            if hiddenElements[n.DataAtom] {
+                return
+            }
+            if blockElements[n.DataAtom] {
+                flush()
+                defer flush()
+            }
+        case html.CommentNode:
+            return
+        }
+        for c := n.FirstChild; c != nil; c = c.NextSibling {
+            walk(c)
+        }
+    }
+    walk(root)
+    flush()
+
+    return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,66 @@
+// pkg/processor/blob.go
+package processor
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "image"
+    _ "image/gif"
+    _ "image/jpeg"
+    _ "image/png"
+    "regexp"
+    "strings"
+)
+
+// BlobStore keeps binary bodies out of the result documents.
+type BlobStore interface {
+    StoreBlob(name, contentType string, data []byte) (string, error)
+}
+
+// BlobProcessor stores the body in a BlobStore and keeps only a reference
+// and a few properties inline.
+type BlobProcessor struct {
+    store BlobStore
+}
+
+func NewBlobProcessor(store BlobStore) *BlobProcessor {
+    return &BlobProcessor{store: store}
+}
+
+var (
+    pdfVersion = regexp.MustCompile(`^%PDF-(\d\.\d)`)
+    pdfPage    = regexp.MustCompile(`/Type\s*/Page[^s]`)
+)
+
+func (b *BlobProcessor) Process(input *Input) (*Output, error) {
+    id, err := b.store.StoreBlob(input.URL, input.ContentType, input.Body)
+    if err != nil {
+        return nil, fmt.Errorf("failed to store blob: %v", err)
+    }
+
+    sum := sha256.Sum256(input.Body)
+    metadata := map[string]interface{}{
+        "blob_id": id,
+        "size":    len(input.Body),
+        "sha256":  hex.EncodeToString(sum[:]),
+    }
+
+    switch {
+    case input.MediaType == "application/pdf":
+        if m := pdfVersion.FindSubmatch(input.Body); m != nil {
+            metadata["pdf_version"] = string(m[1])
+        }
+        // Counts uncompressed page objects only, so it may be a lower bound
+        metadata["pages"] = len(pdfPage.FindAllIndex(input.Body, -1))
+    case strings.HasPrefix(input.MediaType, "image/"):
+        if config, format, err := image.DecodeConfig(bytes.NewReader(input.Body)); err == nil {
+            metadata["width"] = config.Width
+            metadata["height"] = config.Height
+            metadata["format"] = format
+        }
+    }
+
+    return &Output{Metadata: metadata}, nil
+}
@@ -0,0 +1,225 @@
+// pkg/processor/feed.go
+package processor
+
+import (
+    "bytes"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "net/url"
+    "strings"
+)
+
+// FeedEntry is one item of an RSS, RDF or Atom feed.
+type FeedEntry struct {
+    ID        string `json:"id,omitempty" bson:"id,omitempty"`
+    Title     string `json:"title,omitempty" bson:"title,omitempty"`
+    Link      string `json:"link,omitempty" bson:"link,omitempty"`
+    Summary   string `json:"summary,omitempty" bson:"summary,omitempty"`
+    Published string `json:"published,omitempty" bson:"published,omitempty"`
+}
+
+type FeedData struct {
+    Format  string      `json:"format" bson:"format"`
+    Title   string      `json:"title,omitempty" bson:"title,omitempty"`
+    Link    string      `json:"link,omitempty" bson:"link,omitempty"`
+    Entries []FeedEntry `json:"entries" bson:"entries"`
+}
+
+// rssLink is a link element of an RSS channel or item. RSS 2.0 feeds
+// often carry atom:link elements alongside, which share the local name.
+type rssLink struct {
+    XMLName xml.Name
+    Value   string `xml:",chardata"`
+}
+
+// rss10Namespace is the default namespace of RSS 1.0 (RDF) elements.
+const rss10Namespace = "http://purl.org/rss/1.0/"
+
+type rssItem struct {
+    Title       string    `xml:"title"`
+    Links       []rssLink `xml:"link"`
+    GUID        string    `xml:"guid"`
+    Description string    `xml:"description"`
+    PubDate     string    `xml:"pubDate"`
+    Date        string    `xml:"http://purl.org/dc/elements/1.1/ date"`
+    About       string    `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+}
+
+type rssChannel struct {
+    Title string    `xml:"title"`
+    Links []rssLink `xml:"link"`
+    Items []rssItem `xml:"item"`
+}
+
+// rssDocument covers RSS 2.0, whose items sit in the channel, and RSS 1.0
+// (RDF), whose items are siblings of it.
+type rssDocument struct {
+    XMLName xml.Name
+    Channel rssChannel `xml:"channel"`
+    Items   []rssItem  `xml:"item"`
+}
+
+type atomLink struct {
+    Href string `xml:"href,attr"`
+    Rel  string `xml:"rel,attr"`
+}
+
+type atomEntry struct {
+    ID        string     `xml:"id"`
+    Title     string     `xml:"title"`
+    Links     []atomLink `xml:"link"`
+    Summary   string     `xml:"summary"`
+    Content   string     `xml:"content"`
+    Published string     `xml:"published"`
+    Updated   string     `xml:"updated"`
+}
+
+type atomFeed struct {
+    Title   string      `xml:"title"`
+    Links   []atomLink  `xml:"link"`
+    Entries []atomEntry `xml:"entry"`
+}
+
+// Feed parses RSS, RDF and Atom feeds into entries and follows their
+// links. Other XML documents are kept as text.
+func Feed(input *Input) (*Output, error) {
+    root, err := rootElement(input.Body)
+    if err != nil {
+        return nil, err
+    }
+
+    var feed *FeedData
+    switch root {
+    case "rss", "RDF":
+        feed, err = parseRSS(input.Body, root)
+    case "feed":
+        feed, err = parseAtom(input.Body)
+    default:
+        return Text(input)
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    base, _ := url.Parse(input.URL)
+    output := &Output{Metadata: map[string]interface{}{"feed": feed}}
+    for i := range feed.Entries {
+        entry := &feed.Entries[i]
+        entry.Link = absolute(base, entry.Link)
+        if entry.Link != "" {
+            output.Links = append(output.Links, entry.Link)
+        }
+    }
+    return output, nil
+}
+
+func rootElement(body []byte) (string, error) {
+    decoder := newDecoder(body)
+    for {
+        token, err := decoder.Token()
+        if err != nil {
+            return "", fmt.Errorf("failed to parse XML: %v", err)
+        }
+        if start, ok := token.(xml.StartElement); ok {
+            return start.Name.Local, nil
+        }
+    }
+}
+
+func newDecoder(body []byte) *xml.Decoder {
+    decoder := xml.NewDecoder(bytes.NewReader(body))
+    decoder.Strict = false
+    // Bodies have already been transcoded to UTF-8
+    decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+        return input, nil
+    }
+    return decoder
+}
+
+func parseRSS(body []byte, root string) (*FeedData, error) {
+    var doc rssDocument
+    if err := newDecoder(body).Decode(&doc); err != nil {
+        return nil, fmt.Errorf("failed to parse RSS feed: %v", err)
+    }
+
+    feed := &FeedData{Format: "rss", Title: strings.TrimSpace(doc.Channel.Title), Link: rssLinkValue(doc.Channel.Links)}
+    if root == "RDF" {
+        feed.Format = "rdf"
+    }
+
+    items := append(doc.Channel.Items, doc.Items...)
+    for _, item := range items {
+        link := rssLinkValue(item.Links)
+        entry := FeedEntry{
+            ID:        firstNonEmpty(item.GUID, item.About, link),
+            Title:     strings.TrimSpace(item.Title),
+            Link:      link,
+            Summary:   strings.TrimSpace(item.Description),
+            Published: strings.TrimSpace(firstNonEmpty(item.PubDate, item.Date)),
+        }
+        feed.Entries = append(feed.Entries, entry)
+    }
+    return feed, nil
+}
+
+// rssLinkValue returns the first link in the RSS namespaces, skipping
+// links of other vocabularies such as atom:link.
+func rssLinkValue(links []rssLink) string {
+    for _, link := range links {
+        if link.XMLName.Space == "" || link.XMLName.Space == rss10Namespace {
+            return strings.TrimSpace(link.Value)
+        }
+    }
+    return ""
+}
+
+func parseAtom(body []byte) (*FeedData, error) {
+    var doc atomFeed
+    if err := newDecoder(body).Decode(&doc); err != nil {
+        return nil, fmt.Errorf("failed to parse Atom feed: %v", err)
+    }
+
+    feed := &FeedData{Format: "atom", Title: strings.TrimSpace(doc.Title), Link: alternateLink(doc.Links)}
+    for _, e := range doc.Entries {
+        entry := FeedEntry{
+            ID:        strings.TrimSpace(e.ID),
+            Title:     strings.TrimSpace(e.Title),
+            Link:      alternateLink(e.Links),
+            Summary:   strings.TrimSpace(firstNonEmpty(e.Summary, e.Content)),
+            Published: strings.TrimSpace(firstNonEmpty(e.Published, e.Updated)),
+        }
+        feed.Entries = append(feed.Entries, entry)
+    }
+    return feed, nil
+}
+
+func alternateLink(links []atomLink) string {
+    for _, link := range links {
+        if link.Rel == "" || link.Rel == "alternate" {
+            return strings.TrimSpace(link.Href)
+        }
+    }
+    return ""
+}
+
+func absolute(base *url.URL, ref string) string {
+    if ref == "" || base == nil {
+        return ref
+    }
+    u, err := base.Parse(ref)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+        return ""
+    }
+    u.Fragment = ""
+    return u.String()
+}
+
+func firstNonEmpty(values ...string) string {
+    for _, v := range values {
+        if strings.TrimSpace(v) != "" {
+            return v
+        }
+    }
+    return ""
+}
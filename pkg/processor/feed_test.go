@@ -0,0 +1,144 @@
+// pkg/processor/feed_test.go
+package processor
+
+import (
+    "reflect"
+    "testing"
+)
+
+const rss2Feed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title> News </title>
+    <atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"/>
+    <link>https://example.com/</link>
+    <item>
+      <title>First</title>
+      <atom:link href="https://example.com/first.xml" rel="self"/>
+      <link>/posts/first#comments</link>
+      <guid>urn:post:1</guid>
+      <description>One</description>
+      <pubDate>Mon, 06 May 2024 10:00:00 GMT</pubDate>
+    </item>
+    <item>
+      <title>Second</title>
+      <link>https://example.com/posts/second</link>
+      <dc:date>2024-05-07</dc:date>
+    </item>
+    <item>
+      <title>Scripted</title>
+      <link>javascript:alert(1)</link>
+    </item>
+  </channel>
+</rss>`
+
+const rdfFeed = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/">
+  <channel rdf:about="https://example.org/">
+    <title>RDF News</title>
+    <link>https://example.org/</link>
+  </channel>
+  <item rdf:about="https://example.org/1">
+    <title>One</title>
+    <link>https://example.org/1</link>
+  </item>
+</rdf:RDF>`
+
+const atomFeedXML = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom News</title>
+  <link href="https://example.net/feed" rel="self"/>
+  <link href="https://example.net/"/>
+  <entry>
+    <id>tag:example.net,2024:1</id>
+    <title>Entry</title>
+    <link href="https://example.net/edit/1" rel="edit"/>
+    <link href="entries/1" rel="alternate"/>
+    <content>Body</content>
+    <updated>2024-05-08T00:00:00Z</updated>
+  </entry>
+  <entry>
+    <id>tag:example.net,2024:2</id>
+    <link href="https://example.net/edit/2" rel="edit"/>
+    <summary>Short</summary>
+    <content>Long</content>
+    <published>2024-05-09T00:00:00Z</published>
+    <updated>2024-05-10T00:00:00Z</updated>
+  </entry>
+</feed>`
+
+func TestFeed(t *testing.T) {
+    tests := []struct {
+        name  string
+        url   string
+        body  string
+        want  *FeedData
+        links []string
+    }{
+        {
+            name: "RSS 2.0 with atom:link",
+            url:  "https://example.com/feed.xml",
+            body: rss2Feed,
+            want: &FeedData{Format: "rss", Title: "News", Link: "https://example.com/", Entries: []FeedEntry{
+                {ID: "urn:post:1", Title: "First", Link: "https://example.com/posts/first", Summary: "One", Published: "Mon, 06 May 2024 10:00:00 GMT"},
+                {ID: "https://example.com/posts/second", Title: "Second", Link: "https://example.com/posts/second", Published: "2024-05-07"},
+                {ID: "javascript:alert(1)", Title: "Scripted"},
+            }},
+            links: []string{"https://example.com/posts/first", "https://example.com/posts/second"},
+        },
+        {
+            name: "RSS 1.0",
+            url:  "https://example.org/index.rdf",
+            body: rdfFeed,
+            want: &FeedData{Format: "rdf", Title: "RDF News", Link: "https://example.org/", Entries: []FeedEntry{
+                {ID: "https://example.org/1", Title: "One", Link: "https://example.org/1"},
+            }},
+            links: []string{"https://example.org/1"},
+        },
+        {
+            name: "Atom",
+            url:  "https://example.net/feed",
+            body: atomFeedXML,
+            want: &FeedData{Format: "atom", Title: "Atom News", Link: "https://example.net/", Entries: []FeedEntry{
+                {ID: "tag:example.net,2024:1", Title: "Entry", Link: "https://example.net/entries/1", Summary: "Body", Published: "2024-05-08T00:00:00Z"},
+                {ID: "tag:example.net,2024:2", Summary: "Short", Published: "2024-05-09T00:00:00Z"},
+            }},
+            links: []string{"https://example.net/entries/1"},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            output, err := Feed(&Input{URL: tt.url, Body: []byte(tt.body)})
+            if err != nil {
+                t.Fatalf("Feed: %v", err)
+            }
+            feed, ok := output.Metadata["feed"].(*FeedData)
+            if !ok {
+                t.Fatalf("Metadata = %v, want a feed", output.Metadata)
+            }
+            if !reflect.DeepEqual(feed, tt.want) {
+                t.Errorf("feed = %+v\nwant %+v", feed, tt.want)
+            }
+            if !reflect.DeepEqual(output.Links, tt.links) {
+                t.Errorf("Links = %v, want %v", output.Links, tt.links)
+            }
+        })
+    }
+}
+
+func TestFeedFallsBackToText(t *testing.T) {
+    output, err := Feed(&Input{URL: "https://example.com/data.xml", Body: []byte(`<catalog><book>Go</book></catalog>`)})
+    if err != nil {
+        t.Fatalf("Feed: %v", err)
+    }
+    if _, ok := output.Metadata["feed"]; ok {
+        t.Errorf("plain XML was parsed as a feed: %v", output.Metadata)
+    }
+}
+
+func TestFeedRejectsNonXML(t *testing.T) {
+    if _, err := Feed(&Input{URL: "https://example.com/", Body: []byte("not xml at all")}); err == nil {
+        t.Error("Feed accepted a body without elements")
+    }
+}
@@ -0,0 +1,38 @@
+// pkg/processor/html.go
+package processor
+
+import (
+    "bytes"
+
+    "crawler666/pkg/parser"
+)
+
+// HTML keeps the page source and extracts its links, images and page
+// metadata.
+func HTML(input *Input) (*Output, error) {
+    doc, err := parser.ParseHTML(input.URL, bytes.NewReader(input.Body))
+    if err != nil {
+        return nil, err
+    }
+
+    metadata := map[string]interface{}{}
+    if doc.Title != "" {
+        metadata["title"] = doc.Title
+    }
+    if doc.Language != "" {
+        metadata["language"] = doc.Language
+    }
+    if doc.Canonical != "" {
+        metadata["canonical"] = doc.Canonical
+    }
+    if len(doc.Meta) > 0 {
+        metadata["meta"] = doc.Meta
+    }
+
+    return &Output{
+        Content:  string(input.Body),
+        Links:    doc.Links,
+        Images:   doc.Images,
+        Metadata: metadata,
+    }, nil
+}
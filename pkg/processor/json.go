@@ -0,0 +1,17 @@
+// pkg/processor/json.go
+package processor
+
+import (
+    "encoding/json"
+    "fmt"
+)
+
+// JSON stores the decoded document under the "json" metadata key instead
+// of as text.
+func JSON(input *Input) (*Output, error) {
+    var value interface{}
+    if err := json.Unmarshal(input.Body, &value); err != nil {
+        return nil, fmt.Errorf("failed to parse JSON: %v", err)
+    }
+    return &Output{Metadata: map[string]interface{}{"json": value}}, nil
+}
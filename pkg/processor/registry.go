@@ -0,0 +1,132 @@
+// pkg/processor/registry.go
+package processor
+
+import (
+    "mime"
+    "net/http"
+    "strings"
+    "sync"
+
+    "crawler666/pkg/parser"
+)
+
+// Input is a decoded response body handed to a processor.
+type Input struct {
+    // URL is the final URL after redirects
+    URL         string
+    ContentType string
+    MediaType   string
+    Body        []byte
+}
+
+// Output is what a processor extracted from a response.
+type Output struct {
+    Content  string
+    Links    []string
+    Images   []string
+    Metadata map[string]interface{}
+}
+
+type Processor interface {
+    Process(input *Input) (*Output, error)
+}
+
+// Func adapts a plain function to the Processor interface.
+type Func func(input *Input) (*Output, error)
+
+func (f Func) Process(input *Input) (*Output, error) {
+    return f(input)
+}
+
+// Registry picks a processor by the media type of a response. Entries are
+// exact media types such as "application/pdf" or wildcards such as
+// "image/*"; exact entries win.
+type Registry struct {
+    processors map[string]Processor
+    fallback   Processor
+    mu         sync.RWMutex
+}
+
+func NewRegistry(fallback Processor) *Registry {
+    return &Registry{
+        processors: make(map[string]Processor),
+        fallback:   fallback,
+    }
+}
+
+// NewDefaultRegistry registers the built-in processors: HTML, feeds and
+// other XML, JSON, and blobs for PDFs, images and any other binary type.
+func NewDefaultRegistry(blobs BlobStore) *Registry {
+    blob := NewBlobProcessor(blobs)
+    registry := NewRegistry(Func(func(input *Input) (*Output, error) {
+        if parser.IsText(input.ContentType) {
+            return Text(input)
+        }
+        return blob.Process(input)
+    }))
+
+    registry.Register("text/html", Func(HTML))
+    registry.Register("application/xhtml+xml", Func(HTML))
+    for _, mediaType := range []string{"application/rss+xml", "application/atom+xml",
+        "application/rdf+xml", "application/xml", "text/xml"} {
+        registry.Register(mediaType, Func(Feed))
+    }
+    registry.Register("application/json", Func(JSON))
+    registry.Register("application/ld+json", Func(JSON))
+    registry.Register("application/pdf", blob)
+    registry.Register("image/*", blob)
+
+    return registry
+}
+
+func (r *Registry) Register(mediaType string, processor Processor) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.processors[strings.ToLower(mediaType)] = processor
+}
+
+func (r *Registry) Lookup(mediaType string) Processor {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+
+    mediaType = strings.ToLower(mediaType)
+    if p, ok := r.processors[mediaType]; ok {
+        return p
+    }
+    if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+        if p, ok := r.processors[mediaType[:i]+"/*"]; ok {
+            return p
+        }
+    }
+    return r.fallback
+}
+
+// Process runs the processor registered for the input's media type,
+// sniffing the type when the server did not send one.
+func (r *Registry) Process(input *Input) (*Output, error) {
+    if input.ContentType == "" {
+        input.ContentType = http.DetectContentType(input.Body)
+    }
+    if input.MediaType == "" {
+        mediaType, _, err := mime.ParseMediaType(input.ContentType)
+        if err != nil {
+            mediaType = "application/octet-stream"
+        }
+        input.MediaType = mediaType
+    }
+
+    output, err := r.Lookup(input.MediaType).Process(input)
+    if err != nil {
+        return nil, err
+    }
+    if output.Metadata == nil {
+        output.Metadata = make(map[string]interface{})
+    }
+    output.Metadata["media_type"] = input.MediaType
+    return output, nil
+}
+
+// Text keeps a textual body as the content.
+func Text(input *Input) (*Output, error) {
+    return &Output{Content: string(input.Body)}, nil
+}
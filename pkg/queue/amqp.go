@@ -0,0 +1,236 @@
+// pkg/queue/amqp.go
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+    "time"
+
+    "crawler666/internal/models"
+
+    "github.com/streadway/amqp"
+)
+
+// AMQPQueue keeps tasks in a durable RabbitMQ priority queue. The broker
+// redelivers whatever a consumer held when its connection dropped.
+type AMQPQueue struct {
+    config *Config
+
+    mu      sync.Mutex
+    conn    *amqp.Connection
+    publish *amqp.Channel
+    // publishClosed fires when the broker closes the publishing channel,
+    // e.g. after a channel error, while the connection stays up
+    publishClosed chan *amqp.Error
+    consume       *amqp.Channel
+    deliveries    <-chan amqp.Delivery
+    closed        bool
+}
+
+func NewAMQPQueue(config *Config) (*AMQPQueue, error) {
+    q := &AMQPQueue{config: config}
+    if err := q.connect(); err != nil {
+        return nil, err
+    }
+    return q, nil
+}
+
+// connect must be called with mu held, or before the queue is shared.
+func (q *AMQPQueue) connect() error {
+    conn, err := amqp.Dial(q.config.AMQPURL)
+    if err != nil {
+        return fmt.Errorf("failed to connect to broker: %v", err)
+    }
+    publish, err := conn.Channel()
+    if err != nil {
+        conn.Close()
+        return err
+    }
+    _, err = publish.QueueDeclare(q.config.Name, true, false, false, false, amqp.Table{
+        "x-max-priority": int32(MaxPriority),
+    })
+    if err != nil {
+        conn.Close()
+        return fmt.Errorf("failed to declare queue %s: %v", q.config.Name, err)
+    }
+
+    q.conn = conn
+    q.setPublish(publish)
+    q.consume = nil
+    q.deliveries = nil
+    return nil
+}
+
+// setPublish must be called with mu held, or before the queue is shared.
+func (q *AMQPQueue) setPublish(publish *amqp.Channel) {
+    q.publish = publish
+    q.publishClosed = publish.NotifyClose(make(chan *amqp.Error, 1))
+}
+
+// channel returns the publishing channel, reconnecting if the connection
+// was lost and reopening the channel if only the channel was.
+func (q *AMQPQueue) channel() (*amqp.Channel, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    if q.closed {
+        return nil, ErrClosed
+    }
+    if q.conn == nil || q.conn.IsClosed() {
+        if err := q.connect(); err != nil {
+            return nil, err
+        }
+        return q.publish, nil
+    }
+
+    select {
+    case <-q.publishClosed:
+        q.publish = nil
+    default:
+    }
+    if q.publish == nil {
+        publish, err := q.conn.Channel()
+        if err != nil {
+            return nil, err
+        }
+        q.setPublish(publish)
+    }
+    return q.publish, nil
+}
+
+// subscribe starts consuming on a channel of its own, so that prefetch
+// applies to this process' workers alone.
+func (q *AMQPQueue) subscribe() (<-chan amqp.Delivery, error) {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    if q.closed {
+        return nil, ErrClosed
+    }
+    if q.conn == nil || q.conn.IsClosed() {
+        if err := q.connect(); err != nil {
+            return nil, err
+        }
+    }
+    if q.deliveries != nil {
+        return q.deliveries, nil
+    }
+
+    ch, err := q.conn.Channel()
+    if err != nil {
+        return nil, err
+    }
+    if err := ch.Qos(q.config.Prefetch, 0, false); err != nil {
+        ch.Close()
+        return nil, err
+    }
+    deliveries, err := ch.Consume(q.config.Name, q.config.Consumer, false, false, false, false, nil)
+    if err != nil {
+        ch.Close()
+        return nil, fmt.Errorf("failed to consume from %s: %v", q.config.Name, err)
+    }
+    q.consume = ch
+    q.deliveries = deliveries
+    return deliveries, nil
+}
+
+func (q *AMQPQueue) Publish(ctx context.Context, task *models.CrawlTask) error {
+    data, err := json.Marshal(task)
+    if err != nil {
+        return err
+    }
+    ch, err := q.channel()
+    if err != nil {
+        return err
+    }
+    return ch.Publish("", q.config.Name, false, false, amqp.Publishing{
+        ContentType:  "application/json",
+        DeliveryMode: amqp.Persistent,
+        Priority:     uint8(clampPriority(task.Priority)),
+        MessageId:    task.ID,
+        Timestamp:    time.Now(),
+        Body:         data,
+    })
+}
+
+func (q *AMQPQueue) Consume(ctx context.Context) (Delivery, error) {
+    for {
+        deliveries, err := q.subscribe()
+        if err != nil {
+            if err == ErrClosed {
+                return nil, err
+            }
+            // Wait out the broker before trying again
+            select {
+            case <-time.After(time.Second):
+                continue
+            case <-ctx.Done():
+                return nil, ctx.Err()
+            }
+        }
+
+        select {
+        case d, ok := <-deliveries:
+            if !ok {
+                q.mu.Lock()
+                if q.deliveries == deliveries {
+                    q.deliveries = nil
+                }
+                q.mu.Unlock()
+                continue
+            }
+            var task models.CrawlTask
+            if err := json.Unmarshal(d.Body, &task); err != nil {
+                d.Reject(false)
+                return nil, fmt.Errorf("failed to decode task %s: %v", d.MessageId, err)
+            }
+            return &amqpDelivery{delivery: d, task: &task}, nil
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+}
+
+func (q *AMQPQueue) Len() int {
+    ch, err := q.channel()
+    if err != nil {
+        return 0
+    }
+    queue, err := ch.QueueInspect(q.config.Name)
+    if err != nil {
+        return 0
+    }
+    return queue.Messages
+}
+
+func (q *AMQPQueue) Remote() bool {
+    return true
+}
+
+func (q *AMQPQueue) Close() error {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+
+    q.closed = true
+    if q.conn == nil {
+        return nil
+    }
+    return q.conn.Close()
+}
+
+type amqpDelivery struct {
+    delivery amqp.Delivery
+    task     *models.CrawlTask
+}
+
+func (d *amqpDelivery) Task() *models.CrawlTask { return d.task }
+
+func (d *amqpDelivery) Ack() error {
+    return d.delivery.Ack(false)
+}
+
+func (d *amqpDelivery) Nack(requeue bool) error {
+    return d.delivery.Nack(false, requeue)
+}
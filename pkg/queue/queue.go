@@ -0,0 +1,211 @@
+// pkg/queue/queue.go
+package queue
+
+import (
+    "container/heap"
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+var ErrClosed = errors.New("queue closed")
+
+// TaskQueue carries dispatched tasks from the scheduler to the workers,
+// possibly in other processes. Higher-priority tasks are delivered first
+// where the backend allows it. A delivery that is neither acknowledged
+// nor rejected, e.g. because its worker died, is redelivered to another
+// consumer.
+type TaskQueue interface {
+    // Publish blocks while the queue is full, if it is bounded.
+    Publish(ctx context.Context, task *models.CrawlTask) error
+    // Consume blocks until a task is available or ctx is done.
+    Consume(ctx context.Context) (Delivery, error)
+    // Len is the number of tasks waiting, as far as the backend knows.
+    Len() int
+    // Remote reports whether consumers may live in other processes.
+    Remote() bool
+    Close() error
+}
+
+// Delivery is a task handed to one consumer.
+type Delivery interface {
+    Task() *models.CrawlTask
+    // Ack confirms the task was handled and must not be redelivered.
+    Ack() error
+    // Nack gives the task back, to be redelivered when requeue is set.
+    Nack(requeue bool) error
+}
+
+type Config struct {
+    // Backend is "memory", "redis" for Redis Streams or "amqp"
+    Backend  string
+    Name     string
+    Capacity int
+    // Consumer names this process to the broker
+    Consumer string
+    // Prefetch bounds the deliveries a process holds unacknowledged
+    Prefetch int
+    // VisibilityTimeout is how long a Redis Streams delivery may stay
+    // unacknowledged before another consumer claims it
+    VisibilityTimeout time.Duration
+    AMQPURL           string
+    Redis             RedisConfig
+}
+
+type RedisConfig struct {
+    Host     string
+    Port     int
+    Password string
+    DB       int
+}
+
+// MaxPriority is the highest task priority the brokers distinguish; higher
+// ones are treated alike.
+const MaxPriority = 9
+
+func New(config *Config) (TaskQueue, error) {
+    if config.Name == "" {
+        config.Name = "crawl_tasks"
+    }
+    if config.Prefetch < 1 {
+        config.Prefetch = 1
+    }
+    switch config.Backend {
+    case "", "memory":
+        return NewMemoryQueue(config.Capacity), nil
+    case "redis":
+        return NewRedisQueue(config)
+    case "amqp":
+        return NewAMQPQueue(config)
+    }
+    return nil, fmt.Errorf("unknown queue backend %q", config.Backend)
+}
+
+func clampPriority(priority int) int {
+    if priority < 0 {
+        return 0
+    }
+    if priority > MaxPriority {
+        return MaxPriority
+    }
+    return priority
+}
+
+// MemoryQueue is a bounded in-process priority queue. Its deliveries need
+// no acknowledgement: a task only disappears with the process, and the
+// task's storage lease brings it back then.
+type MemoryQueue struct {
+    mu     sync.Mutex
+    tasks  priorityHeap
+    seq    uint64
+    slots  chan struct{}
+    ready  chan struct{}
+    closed chan struct{}
+    once   sync.Once
+}
+
+func NewMemoryQueue(capacity int) *MemoryQueue {
+    if capacity < 1 {
+        capacity = 1
+    }
+    return &MemoryQueue{
+        slots:  make(chan struct{}, capacity),
+        ready:  make(chan struct{}, capacity),
+        closed: make(chan struct{}),
+    }
+}
+
+func (q *MemoryQueue) Publish(ctx context.Context, task *models.CrawlTask) error {
+    select {
+    case q.slots <- struct{}{}:
+    case <-ctx.Done():
+        return ctx.Err()
+    case <-q.closed:
+        return ErrClosed
+    }
+
+    q.mu.Lock()
+    q.seq++
+    heap.Push(&q.tasks, &queued{task: task, seq: q.seq})
+    q.mu.Unlock()
+
+    q.ready <- struct{}{}
+    return nil
+}
+
+func (q *MemoryQueue) Consume(ctx context.Context) (Delivery, error) {
+    select {
+    case <-q.ready:
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    case <-q.closed:
+        return nil, ErrClosed
+    }
+
+    q.mu.Lock()
+    item := heap.Pop(&q.tasks).(*queued)
+    q.mu.Unlock()
+
+    <-q.slots
+    return &memoryDelivery{queue: q, task: item.task}, nil
+}
+
+func (q *MemoryQueue) Len() int {
+    q.mu.Lock()
+    defer q.mu.Unlock()
+    return q.tasks.Len()
+}
+
+func (q *MemoryQueue) Remote() bool {
+    return false
+}
+
+func (q *MemoryQueue) Close() error {
+    q.once.Do(func() { close(q.closed) })
+    return nil
+}
+
+type memoryDelivery struct {
+    queue *MemoryQueue
+    task  *models.CrawlTask
+}
+
+func (d *memoryDelivery) Task() *models.CrawlTask { return d.task }
+func (d *memoryDelivery) Ack() error              { return nil }
+
+// Nack requeues in the background so a worker never blocks on a full queue
+// it is itself meant to drain.
+func (d *memoryDelivery) Nack(requeue bool) error {
+    if requeue {
+        go d.queue.Publish(context.Background(), d.task)
+    }
+    return nil
+}
+
+type queued struct {
+    task *models.CrawlTask
+    seq  uint64
+}
+
+// priorityHeap orders by priority, then first in first out.
+type priorityHeap []*queued
+
+func (h priorityHeap) Len() int { return len(h) }
+func (h priorityHeap) Less(i, j int) bool {
+    if h[i].task.Priority != h[j].task.Priority {
+        return h[i].task.Priority > h[j].task.Priority
+    }
+    return h[i].seq < h[j].seq
+}
+func (h priorityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x interface{}) { *h = append(*h, x.(*queued)) }
+func (h *priorityHeap) Pop() interface{} {
+    old := *h
+    item := old[len(old)-1]
+    *h = old[:len(old)-1]
+    return item
+}
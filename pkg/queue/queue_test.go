@@ -0,0 +1,173 @@
+// pkg/queue/queue_test.go
+package queue
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+func publish(t *testing.T, q TaskQueue, tasks ...*models.CrawlTask) {
+    t.Helper()
+    for _, task := range tasks {
+        if err := q.Publish(context.Background(), task); err != nil {
+            t.Fatalf("Publish(%s): %v", task.ID, err)
+        }
+    }
+}
+
+func consume(t *testing.T, q TaskQueue) Delivery {
+    t.Helper()
+    ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+    defer cancel()
+    delivery, err := q.Consume(ctx)
+    if err != nil {
+        t.Fatalf("Consume: %v", err)
+    }
+    return delivery
+}
+
+func TestMemoryQueueOrder(t *testing.T) {
+    tests := []struct {
+        name  string
+        tasks []*models.CrawlTask
+        want  string
+    }{
+        {"first in first out", []*models.CrawlTask{
+            {ID: "a", Priority: 5}, {ID: "b", Priority: 5}, {ID: "c", Priority: 5},
+        }, "a,b,c"},
+        {"priority first", []*models.CrawlTask{
+            {ID: "low", Priority: 1}, {ID: "high", Priority: 9}, {ID: "mid", Priority: 5},
+        }, "high,mid,low"},
+        {"ties keep publish order", []*models.CrawlTask{
+            {ID: "a", Priority: 1}, {ID: "b", Priority: 7}, {ID: "c", Priority: 1}, {ID: "d", Priority: 7},
+        }, "b,d,a,c"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            q := NewMemoryQueue(len(tt.tasks))
+            publish(t, q, tt.tasks...)
+            if q.Len() != len(tt.tasks) {
+                t.Fatalf("Len = %d, want %d", q.Len(), len(tt.tasks))
+            }
+
+            var got []string
+            for range tt.tasks {
+                got = append(got, consume(t, q).Task().ID)
+            }
+            if strings.Join(got, ",") != tt.want {
+                t.Errorf("order = %s, want %s", strings.Join(got, ","), tt.want)
+            }
+            if q.Len() != 0 {
+                t.Errorf("Len = %d after consuming everything", q.Len())
+            }
+        })
+    }
+}
+
+func TestMemoryQueueBlocksWhenFull(t *testing.T) {
+    q := NewMemoryQueue(1)
+    publish(t, q, &models.CrawlTask{ID: "a"})
+
+    ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+    defer cancel()
+    if err := q.Publish(ctx, &models.CrawlTask{ID: "b"}); !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("Publish to a full queue = %v, want deadline exceeded", err)
+    }
+
+    // Consuming frees the slot
+    consume(t, q)
+    publish(t, q, &models.CrawlTask{ID: "c"})
+    if got := consume(t, q).Task().ID; got != "c" {
+        t.Errorf("consumed %s, want c", got)
+    }
+}
+
+func TestMemoryQueueNack(t *testing.T) {
+    tests := []struct {
+        requeue bool
+        want    int
+    }{
+        {true, 1},
+        {false, 0},
+    }
+
+    for _, tt := range tests {
+        q := NewMemoryQueue(2)
+        publish(t, q, &models.CrawlTask{ID: "a"})
+        if err := consume(t, q).Nack(tt.requeue); err != nil {
+            t.Fatalf("Nack(%v): %v", tt.requeue, err)
+        }
+
+        ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+        got := 0
+        if _, err := q.Consume(ctx); err == nil {
+            got++
+        }
+        cancel()
+        if got != tt.want {
+            t.Errorf("Nack(%v) redelivered %d tasks, want %d", tt.requeue, got, tt.want)
+        }
+    }
+}
+
+func TestMemoryQueueClose(t *testing.T) {
+    q := NewMemoryQueue(1)
+    done := make(chan error, 1)
+    go func() {
+        _, err := q.Consume(context.Background())
+        done <- err
+    }()
+
+    q.Close()
+    q.Close()
+    select {
+    case err := <-done:
+        if !errors.Is(err, ErrClosed) {
+            t.Errorf("Consume after Close = %v, want ErrClosed", err)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("Close did not wake the consumer")
+    }
+
+
+    // A publisher waiting on a full queue gives up as well
+    full := NewMemoryQueue(1)
+    publish(t, full, &models.CrawlTask{ID: "a"})
+    full.Close()
+    if err := full.Publish(context.Background(), &models.CrawlTask{ID: "b"}); !errors.Is(err, ErrClosed) {
+        t.Errorf("Publish after Close = %v, want ErrClosed", err)
+    }
+}
+
+func TestClampPriority(t *testing.T) {
+    tests := map[int]int{-3: 0, 0: 0, 4: 4, MaxPriority: MaxPriority, 42: MaxPriority}
+    for in, want := range tests {
+        if got := clampPriority(in); got != want {
+            t.Errorf("clampPriority(%d) = %d, want %d", in, got, want)
+        }
+    }
+}
+
+func TestNewDefaults(t *testing.T) {
+    config := &Config{}
+    q, err := New(config)
+    if err != nil {
+        t.Fatalf("New: %v", err)
+    }
+    if _, ok := q.(*MemoryQueue); !ok || q.Remote() {
+        t.Errorf("New picked %T, want a local MemoryQueue", q)
+    }
+    if config.Name != "crawl_tasks" || config.Prefetch != 1 {
+        t.Errorf("defaults = %q, %d", config.Name, config.Prefetch)
+    }
+
+    if _, err := New(&Config{Backend: "kafka"}); err == nil {
+        t.Error("New accepted an unknown backend")
+    }
+}
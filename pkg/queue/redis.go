@@ -0,0 +1,247 @@
+// pkg/queue/redis.go
+package queue
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "crawler666/internal/models"
+
+    "github.com/go-redis/redis/v8"
+)
+
+const redisGroup = "workers"
+
+// redisBands split priorities over streams, highest first, since a stream
+// itself is strictly first in first out.
+var redisBands = []struct {
+    suffix string
+    min    int
+}{
+    {"high", 7},
+    {"normal", 4},
+    {"low", 0},
+}
+
+// RedisQueue keeps tasks in Redis Streams read through one consumer group.
+// Deliveries that stay unacknowledged past the visibility timeout are
+// claimed by whichever consumer asks next.
+type RedisQueue struct {
+    client     *redis.Client
+    streams    []string
+    consumer   string
+    visibility time.Duration
+    mu         sync.Mutex
+    lastClaim  time.Time
+}
+
+func NewRedisQueue(config *Config) (*RedisQueue, error) {
+    client := redis.NewClient(&redis.Options{
+        Addr:     fmt.Sprintf("%s:%d", config.Redis.Host, config.Redis.Port),
+        Password: config.Redis.Password,
+        DB:       config.Redis.DB,
+    })
+
+    ctx := context.Background()
+    if err := client.Ping(ctx).Err(); err != nil {
+        return nil, err
+    }
+
+    q := &RedisQueue{
+        client:     client,
+        consumer:   config.Consumer,
+        visibility: config.VisibilityTimeout,
+    }
+    if q.visibility <= 0 {
+        q.visibility = 5 * time.Minute
+    }
+    for _, band := range redisBands {
+        stream := config.Name + ":" + band.suffix
+        err := client.XGroupCreateMkStream(ctx, stream, redisGroup, "0").Err()
+        if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+            return nil, fmt.Errorf("failed to create consumer group on %s: %v", stream, err)
+        }
+        q.streams = append(q.streams, stream)
+    }
+    return q, nil
+}
+
+func (q *RedisQueue) stream(priority int) string {
+    priority = clampPriority(priority)
+    for i, band := range redisBands {
+        if priority >= band.min {
+            return q.streams[i]
+        }
+    }
+    return q.streams[len(q.streams)-1]
+}
+
+func (q *RedisQueue) Publish(ctx context.Context, task *models.CrawlTask) error {
+    data, err := json.Marshal(task)
+    if err != nil {
+        return err
+    }
+    return q.client.XAdd(ctx, &redis.XAddArgs{
+        Stream: q.stream(task.Priority),
+        Values: map[string]interface{}{"task": data},
+    }).Err()
+}
+
+// Consume takes over stale deliveries first, then reads the bands in order
+// without blocking, and only then waits on all of them at once.
+func (q *RedisQueue) Consume(ctx context.Context) (Delivery, error) {
+    for {
+        if d, err := q.claim(ctx); d != nil || err != nil {
+            return d, err
+        }
+
+        for _, stream := range q.streams {
+            d, err := q.read(ctx, []string{stream, ">"}, -1)
+            if d != nil || err != nil {
+                return d, err
+            }
+        }
+
+        args := make([]string, 0, 2*len(q.streams))
+        args = append(args, q.streams...)
+        for range q.streams {
+            args = append(args, ">")
+        }
+        d, err := q.read(ctx, args, 5*time.Second)
+        if d != nil || err != nil {
+            return d, err
+        }
+        if err := ctx.Err(); err != nil {
+            return nil, err
+        }
+    }
+}
+
+// read returns nil without error when nothing arrived in time. A negative
+// block does not wait at all.
+func (q *RedisQueue) read(ctx context.Context, streams []string, block time.Duration) (Delivery, error) {
+    res, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+        Group:    redisGroup,
+        Consumer: q.consumer,
+        Streams:  streams,
+        Count:    1,
+        Block:    block,
+    }).Result()
+    if err == redis.Nil {
+        return nil, nil
+    }
+    if err != nil {
+        if ctx.Err() != nil {
+            return nil, ctx.Err()
+        }
+        return nil, err
+    }
+    for _, stream := range res {
+        for _, msg := range stream.Messages {
+            return q.delivery(ctx, stream.Stream, msg)
+        }
+    }
+    return nil, nil
+}
+
+// claim looks for stale deliveries at most once per visibility timeout
+// fraction, so idle consumers do not hammer the server.
+func (q *RedisQueue) claim(ctx context.Context) (Delivery, error) {
+    q.mu.Lock()
+    if time.Since(q.lastClaim) < q.visibility/4 {
+        q.mu.Unlock()
+        return nil, nil
+    }
+    q.lastClaim = time.Now()
+    q.mu.Unlock()
+
+    for _, stream := range q.streams {
+        msgs, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+            Stream:   stream,
+            Group:    redisGroup,
+            Consumer: q.consumer,
+            MinIdle:  q.visibility,
+            Start:    "0",
+            Count:    1,
+        }).Result()
+        if err != nil && err != redis.Nil {
+            return nil, err
+        }
+        if len(msgs) > 0 {
+            // More may be waiting
+            q.mu.Lock()
+            q.lastClaim = time.Time{}
+            q.mu.Unlock()
+            return q.delivery(ctx, stream, msgs[0])
+        }
+    }
+    return nil, nil
+}
+
+// delivery decodes a message. Undecodable ones are dropped, as no consumer
+// would fare better with them.
+func (q *RedisQueue) delivery(ctx context.Context, stream string, msg redis.XMessage) (Delivery, error) {
+    d := &redisDelivery{queue: q, stream: stream, id: msg.ID}
+    raw, _ := msg.Values["task"].(string)
+    var task models.CrawlTask
+    if err := json.Unmarshal([]byte(raw), &task); err != nil {
+        d.Ack()
+        return nil, fmt.Errorf("failed to decode task %s on %s: %v", msg.ID, stream, err)
+    }
+    d.task = &task
+    return d, nil
+}
+
+func (q *RedisQueue) Len() int {
+    ctx := context.Background()
+    total := 0
+    for _, stream := range q.streams {
+        n, err := q.client.XLen(ctx, stream).Result()
+        if err != nil {
+            continue
+        }
+        total += int(n)
+    }
+    return total
+}
+
+func (q *RedisQueue) Remote() bool {
+    return true
+}
+
+func (q *RedisQueue) Close() error {
+    return q.client.Close()
+}
+
+type redisDelivery struct {
+    queue  *RedisQueue
+    stream string
+    id     string
+    task   *models.CrawlTask
+}
+
+func (d *redisDelivery) Task() *models.CrawlTask { return d.task }
+
+// Ack also deletes the entry, so stream length tracks the backlog.
+func (d *redisDelivery) Ack() error {
+    ctx := context.Background()
+    if err := d.queue.client.XAck(ctx, d.stream, redisGroup, d.id).Err(); err != nil {
+        return err
+    }
+    return d.queue.client.XDel(ctx, d.stream, d.id).Err()
+}
+
+// Nack with requeue publishes the task afresh, which puts it behind the
+// tasks already waiting instead of leaving it pending until it goes stale.
+func (d *redisDelivery) Nack(requeue bool) error {
+    if requeue {
+        if err := d.queue.Publish(context.Background(), d.task); err != nil {
+            return err
+        }
+    }
+    return d.Ack()
+}
@@ -0,0 +1,165 @@
+// pkg/ratelimit/host.go
+package ratelimit
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+type Config struct {
+    // Interval is the average time between two requests to one host.
+    Interval time.Duration
+    // Burst is how many requests may be issued back to back after a host
+    // has been idle.
+    Burst int
+    // Concurrency caps the number of in-flight requests per host.
+    Concurrency int
+}
+
+// HostLimiter paces requests per host with a token bucket and a cap on
+// concurrent requests. Hosts may additionally impose a minimum delay
+// between requests, e.g. from a robots.txt Crawl-delay.
+type HostLimiter struct {
+    config *Config
+    mu     sync.Mutex
+    hosts  map[string]*bucket
+}
+
+type bucket struct {
+    tokens      float64
+    lastRefill  time.Time
+    lastRequest time.Time
+    minDelay    time.Duration
+    inFlight    int
+}
+
+// pollInterval bounds how long Wait sleeps before re-checking a host
+// whose concurrency slots are all taken.
+const pollInterval = 50 * time.Millisecond
+
+func NewHostLimiter(config *Config) *HostLimiter {
+    if config.Burst < 1 {
+        config.Burst = 1
+    }
+    if config.Concurrency < 1 {
+        config.Concurrency = 1
+    }
+    return &HostLimiter{
+        config: config,
+        hosts:  make(map[string]*bucket),
+    }
+}
+
+// Wait blocks until a request to host may be issued, then reserves a
+// concurrency slot that must be returned with Release. minInterval is an
+// extra per-caller spacing, such as a session's configured delay.
+func (l *HostLimiter) Wait(ctx context.Context, host string, minInterval time.Duration) error {
+    for {
+        l.mu.Lock()
+        b := l.bucket(host)
+        wait := l.delay(b, minInterval, time.Now())
+        if wait == 0 {
+            b.tokens--
+            b.inFlight++
+            b.lastRequest = time.Now()
+            l.mu.Unlock()
+            return nil
+        }
+        l.mu.Unlock()
+
+        timer := time.NewTimer(wait)
+        select {
+        case <-ctx.Done():
+            timer.Stop()
+            return ctx.Err()
+        case <-timer.C:
+        }
+    }
+}
+
+// Ready reports whether a request to host could be issued right now
+// without consuming anything.
+func (l *HostLimiter) Ready(host string, minInterval time.Duration) bool {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    return l.delay(l.bucket(host), minInterval, time.Now()) == 0
+}
+
+// ReadyAt returns when a request to host could next be issued, without
+// consuming anything. Hosts whose concurrency slots are all taken report
+// a short poll interval from now.
+func (l *HostLimiter) ReadyAt(host string, minInterval time.Duration) time.Time {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := time.Now()
+    return now.Add(l.delay(l.bucket(host), minInterval, now))
+}
+
+// Reserve charges a request to host without waiting or taking a
+// concurrency slot, for requests paced here but issued elsewhere.
+func (l *HostLimiter) Reserve(host string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    now := time.Now()
+    b := l.bucket(host)
+    l.delay(b, 0, now)
+    b.tokens--
+    b.lastRequest = now
+}
+
+// Release returns the concurrency slot reserved by Wait.
+func (l *HostLimiter) Release(host string) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if b, exists := l.hosts[host]; exists && b.inFlight > 0 {
+        b.inFlight--
+    }
+}
+
+// SetMinDelay sets a host-imposed minimum delay between requests.
+func (l *HostLimiter) SetMinDelay(host string, delay time.Duration) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    l.bucket(host).minDelay = delay
+}
+
+func (l *HostLimiter) bucket(host string) *bucket {
+    b, exists := l.hosts[host]
+    if !exists {
+        b = &bucket{tokens: float64(l.config.Burst), lastRefill: time.Now()}
+        l.hosts[host] = b
+    }
+    return b
+}
+
+// delay refills the bucket and returns how long to wait before the next
+// request to it; zero means now.
+func (l *HostLimiter) delay(b *bucket, minInterval time.Duration, now time.Time) time.Duration {
+    if l.config.Interval > 0 {
+        elapsed := now.Sub(b.lastRefill)
+        b.tokens += float64(elapsed) / float64(l.config.Interval)
+        if b.tokens > float64(l.config.Burst) {
+            b.tokens = float64(l.config.Burst)
+        }
+    } else {
+        b.tokens = float64(l.config.Burst)
+    }
+    b.lastRefill = now
+
+    if b.inFlight >= l.config.Concurrency {
+        return pollInterval
+    }
+
+    var wait time.Duration
+    if b.tokens < 1 {
+        wait = time.Duration((1 - b.tokens) * float64(l.config.Interval))
+    }
+    if minInterval < b.minDelay {
+        minInterval = b.minDelay
+    }
+    if gap := b.lastRequest.Add(minInterval).Sub(now); gap > wait {
+        wait = gap
+    }
+    return wait
+}
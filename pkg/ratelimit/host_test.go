@@ -0,0 +1,113 @@
+// pkg/ratelimit/host_test.go
+package ratelimit
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestDelay(t *testing.T) {
+    now := time.Now()
+
+    tests := []struct {
+        name        string
+        config      Config
+        bucket      bucket
+        minInterval time.Duration
+        want        time.Duration
+    }{
+        {"full bucket", Config{Interval: time.Second, Burst: 2},
+            bucket{tokens: 2, lastRefill: now}, 0, 0},
+        {"empty bucket waits an interval", Config{Interval: time.Second, Burst: 2},
+            bucket{tokens: 0, lastRefill: now}, 0, time.Second},
+        {"partial token waits the rest", Config{Interval: time.Second, Burst: 1},
+            bucket{tokens: 0.25, lastRefill: now}, 0, 750 * time.Millisecond},
+        {"refills over time", Config{Interval: time.Second, Burst: 1},
+            bucket{tokens: 0, lastRefill: now.Add(-time.Second)}, 0, 0},
+        {"refill is capped at burst", Config{Interval: time.Second, Burst: 1},
+            bucket{tokens: 0, lastRefill: now.Add(-time.Hour), lastRequest: now}, 0, 0},
+        {"no interval means no token limit", Config{Burst: 1},
+            bucket{tokens: -5, lastRefill: now}, 0, 0},
+        {"concurrency slots taken", Config{Interval: time.Second, Burst: 1, Concurrency: 1},
+            bucket{tokens: 1, lastRefill: now, inFlight: 1}, 0, pollInterval},
+        {"caller spacing", Config{Burst: 1},
+            bucket{tokens: 1, lastRefill: now, lastRequest: now.Add(-time.Second)}, 3 * time.Second, 2 * time.Second},
+        {"host delay beats shorter caller spacing", Config{Burst: 1},
+            bucket{tokens: 1, lastRefill: now, lastRequest: now, minDelay: 5 * time.Second}, time.Second, 5 * time.Second},
+        {"longest of tokens and spacing", Config{Interval: 4 * time.Second, Burst: 1},
+            bucket{tokens: 0, lastRefill: now, lastRequest: now}, time.Second, 4 * time.Second},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            config := tt.config
+            l := NewHostLimiter(&config)
+            b := tt.bucket
+            if got := l.delay(&b, tt.minInterval, now); got != tt.want {
+                t.Errorf("delay = %v, want %v", got, tt.want)
+            }
+        })
+    }
+}
+
+func TestWaitAndRelease(t *testing.T) {
+    l := NewHostLimiter(&Config{Interval: time.Hour, Burst: 2, Concurrency: 1})
+    ctx := context.Background()
+
+    if err := l.Wait(ctx, "a.example", 0); err != nil {
+        t.Fatalf("Wait: %v", err)
+    }
+    if l.Ready("a.example", 0) {
+        t.Error("host with its only slot taken should not be ready")
+    }
+    if !l.Ready("b.example", 0) {
+        t.Error("other hosts should not be affected")
+    }
+
+    l.Release("a.example")
+    if !l.Ready("a.example", 0) {
+        t.Error("second token of the burst should be available after release")
+    }
+    if err := l.Wait(ctx, "a.example", 0); err != nil {
+        t.Fatalf("Wait: %v", err)
+    }
+    l.Release("a.example")
+    l.Release("a.example")
+
+    if at := l.ReadyAt("a.example", 0); time.Until(at) < 59*time.Minute {
+        t.Errorf("ReadyAt = %v from now, want about an hour after the burst", time.Until(at))
+    }
+
+    cancelled, cancel := context.WithCancel(ctx)
+    cancel()
+    if err := l.Wait(cancelled, "a.example", 0); err != context.Canceled {
+        t.Errorf("Wait on a cancelled context = %v, want %v", err, context.Canceled)
+    }
+}
+
+func TestReserveTakesNoSlot(t *testing.T) {
+    l := NewHostLimiter(&Config{Interval: time.Hour, Burst: 2, Concurrency: 1})
+
+    l.Reserve("a.example")
+    if !l.Ready("a.example", 0) {
+        t.Error("reserving should leave the concurrency slot and second token free")
+    }
+    l.Reserve("a.example")
+    if l.Ready("a.example", 0) {
+        t.Error("reserving should consume tokens")
+    }
+}
+
+func TestSetMinDelay(t *testing.T) {
+    l := NewHostLimiter(&Config{Burst: 10})
+    l.SetMinDelay("a.example", time.Minute)
+
+    l.Reserve("a.example")
+    if l.Ready("a.example", 0) {
+        t.Error("host should honour its minimum delay")
+    }
+    if wait := time.Until(l.ReadyAt("a.example", 0)); wait < 59*time.Second || wait > time.Minute {
+        t.Errorf("ReadyAt = %v from now, want a minute", wait)
+    }
+}
@@ -0,0 +1,216 @@
+// pkg/render/renderer.go
+package render
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "crawler666/pkg/browserpool"
+
+    "github.com/chromedp/cdproto/cdp"
+    "github.com/chromedp/cdproto/emulation"
+    "github.com/chromedp/cdproto/network"
+    "github.com/chromedp/cdproto/page"
+    "github.com/chromedp/cdproto/runtime"
+    "github.com/chromedp/chromedp"
+)
+
+type Config struct {
+    PageTimeout time.Duration
+}
+
+// Options controls how a single page is rendered.
+type Options struct {
+    // WaitFor is "load" (default), "networkidle", "selector" or "delay"
+    WaitFor        string
+    WaitSelector   string
+    WaitDelay      time.Duration
+    Screenshot     bool
+    Proxy          string
+    UserAgent      string
+    Width          int
+    Height         int
+    // BlockResources overrides the pool's blocked resource types when
+    // non-nil
+    BlockResources []string
+}
+
+// Page is the outcome of rendering a URL.
+type Page struct {
+    URL           string
+    // IP is the address the main document came from
+    IP            string
+    StatusCode    int
+    Headers       map[string]string
+    HTML          string
+    ConsoleErrors []string
+    Screenshot    []byte
+}
+
+// Renderer renders pages in tabs borrowed from a browser pool, so the
+// pool bounds how many pages are open at once.
+type Renderer struct {
+    config *Config
+    pool   *browserpool.Pool
+}
+
+func NewRenderer(config *Config, pool *browserpool.Pool) *Renderer {
+    if config.PageTimeout <= 0 {
+        config.PageTimeout = 60 * time.Second
+    }
+    return &Renderer{config: config, pool: pool}
+}
+
+// Render loads url in a pooled tab, waits as requested and captures the
+// final DOM. The page is abandoned after PageTimeout at the latest.
+func (r *Renderer) Render(ctx context.Context, url string, opts *Options) (*Page, error) {
+    tab, err := r.pool.Acquire(ctx, &browserpool.TabOptions{
+        Proxy:          opts.Proxy,
+        BlockResources: opts.BlockResources,
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    page, err := r.render(ctx, tab, url, opts)
+    // A tab that failed may be stuck mid-navigation, so it is not reused
+    r.pool.Release(tab, err != nil)
+    return page, err
+}
+
+func (r *Renderer) render(ctx context.Context, tab *browserpool.Tab, url string, opts *Options) (*Page, error) {
+    tabCtx, cancel := context.WithTimeout(tab.Context(), r.config.PageTimeout)
+    defer cancel()
+
+    // Abandon the page when the caller gives up
+    stop := context.AfterFunc(ctx, cancel)
+    defer stop()
+
+    mainFrame := cdp.FrameID(chromedp.FromContext(tabCtx).Target.TargetID)
+
+    c := newCapture(mainFrame)
+    chromedp.ListenTarget(tabCtx, c.handle)
+
+    var actions []chromedp.Action
+    if opts.UserAgent != "" {
+        actions = append(actions, emulation.SetUserAgentOverride(opts.UserAgent))
+    }
+    if opts.Width > 0 && opts.Height > 0 {
+        actions = append(actions, chromedp.EmulateViewport(int64(opts.Width), int64(opts.Height)))
+    }
+    actions = append(actions, chromedp.Navigate(url))
+
+    switch opts.WaitFor {
+    case "", "load":
+    case "networkidle":
+        actions = append(actions, chromedp.ActionFunc(func(ctx context.Context) error {
+            select {
+            case <-c.idle:
+                return nil
+            case <-ctx.Done():
+                return ctx.Err()
+            }
+        }))
+    case "selector":
+        if opts.WaitSelector == "" {
+            return nil, fmt.Errorf("wait selector is required")
+        }
+        actions = append(actions, chromedp.WaitReady(opts.WaitSelector, chromedp.ByQuery))
+    case "delay":
+        actions = append(actions, chromedp.Sleep(opts.WaitDelay))
+    default:
+        return nil, fmt.Errorf("unknown wait condition %q", opts.WaitFor)
+    }
+
+    result := &Page{}
+    actions = append(actions,
+        chromedp.Location(&result.URL),
+        chromedp.OuterHTML("html", &result.HTML, chromedp.ByQuery),
+    )
+    if opts.Screenshot {
+        actions = append(actions, chromedp.FullScreenshot(&result.Screenshot, 80))
+    }
+
+    if err := chromedp.Run(tabCtx, actions...); err != nil {
+        if errors.Is(tabCtx.Err(), context.DeadlineExceeded) {
+            return nil, fmt.Errorf("page exceeded its lifetime of %s: %w", r.config.PageTimeout, context.DeadlineExceeded)
+        }
+        return nil, err
+    }
+
+    result.StatusCode, result.IP, result.Headers, result.ConsoleErrors = c.results()
+    return result, nil
+}
+
+// capture collects what a page reports while it loads.
+type capture struct {
+    mainFrame cdp.FrameID
+    idle      chan struct{}
+    idleOnce  sync.Once
+    status    int
+    ip        string
+    headers   map[string]string
+    errors    []string
+    mu        sync.Mutex
+}
+
+func newCapture(mainFrame cdp.FrameID) *capture {
+    return &capture{mainFrame: mainFrame, idle: make(chan struct{})}
+}
+
+func (c *capture) handle(ev interface{}) {
+    switch ev := ev.(type) {
+    case *network.EventResponseReceived:
+        if ev.Type != network.ResourceTypeDocument || ev.FrameID != c.mainFrame {
+            return
+        }
+        c.mu.Lock()
+        // Redirects replace the response, leaving the final one
+        c.status = int(ev.Response.Status)
+        c.ip = ev.Response.RemoteIPAddress
+        c.headers = make(map[string]string, len(ev.Response.Headers))
+        for k, v := range ev.Response.Headers {
+            c.headers[k] = fmt.Sprint(v)
+        }
+        c.mu.Unlock()
+    case *page.EventLifecycleEvent:
+        if ev.Name == "networkIdle" && ev.FrameID == c.mainFrame {
+            c.idleOnce.Do(func() { close(c.idle) })
+        }
+    case *runtime.EventConsoleAPICalled:
+        if ev.Type != runtime.APITypeError {
+            return
+        }
+        parts := make([]string, 0, len(ev.Args))
+        for _, arg := range ev.Args {
+            if arg.Value != nil {
+                parts = append(parts, strings.Trim(string(arg.Value), `"`))
+            } else if arg.Description != "" {
+                parts = append(parts, arg.Description)
+            }
+        }
+        c.addError(strings.Join(parts, " "))
+    case *runtime.EventExceptionThrown:
+        message := ev.ExceptionDetails.Text
+        if ev.ExceptionDetails.Exception != nil && ev.ExceptionDetails.Exception.Description != "" {
+            message = ev.ExceptionDetails.Exception.Description
+        }
+        c.addError(message)
+    }
+}
+
+func (c *capture) addError(message string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.errors = append(c.errors, message)
+}
+
+func (c *capture) results() (int, string, map[string]string, []string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.status, c.ip, c.headers, c.errors
+}
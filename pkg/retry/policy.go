@@ -0,0 +1,123 @@
+// pkg/retry/policy.go
+package retry
+
+import (
+    "context"
+    "errors"
+    "math"
+    "math/rand"
+    "net"
+    "net/http"
+    "strconv"
+    "strings"
+    "time"
+)
+
+type Config struct {
+    MaxAttempts          int
+    BackoffBase          time.Duration
+    BackoffCap           time.Duration
+    Jitter               float64
+    RetryableStatusCodes []int
+    RetryTimeouts        bool
+    RetryNetworkErrors   bool
+}
+
+// Policy decides whether a failed fetch is retried and when.
+type Policy struct {
+    config    *Config
+    retryable map[int]bool
+}
+
+func NewPolicy(config *Config) *Policy {
+    policy := &Policy{
+        config:    config,
+        retryable: make(map[int]bool),
+    }
+    for _, code := range config.RetryableStatusCodes {
+        policy.retryable[code] = true
+    }
+    return policy
+}
+
+// Decide reports whether a fetch that ended with statusCode or err after
+// the given number of attempts should be retried, and after what delay.
+// retryAfter is the raw Retry-After response header, if any.
+func (p *Policy) Decide(attempts, statusCode int, err error, retryAfter string) (bool, time.Duration) {
+    if attempts >= p.config.MaxAttempts || !p.Retryable(statusCode, err) {
+        return false, 0
+    }
+
+    delay := p.Backoff(attempts)
+    if wait, ok := parseRetryAfter(retryAfter, time.Now()); ok && wait > delay {
+        delay = wait
+    }
+    return true, delay
+}
+
+// Retryable reports whether the failure is transient under this policy.
+func (p *Policy) Retryable(statusCode int, err error) bool {
+    if err != nil {
+        if isTimeout(err) {
+            return p.config.RetryTimeouts
+        }
+        var netErr net.Error
+        if errors.As(err, &netErr) {
+            return p.config.RetryNetworkErrors
+        }
+        // Errors may declare themselves transient, e.g. an unreachable robots.txt
+        var temporary interface{ Temporary() bool }
+        return errors.As(err, &temporary) && temporary.Temporary()
+    }
+    return p.retryable[statusCode]
+}
+
+// Backoff returns the exponential delay before the next attempt, capped
+// and spread by the configured jitter fraction.
+func (p *Policy) Backoff(attempts int) time.Duration {
+    if attempts < 1 {
+        attempts = 1
+    }
+
+    delay := float64(p.config.BackoffBase) * math.Pow(2, float64(attempts-1))
+    if p.config.BackoffCap > 0 && delay > float64(p.config.BackoffCap) {
+        delay = float64(p.config.BackoffCap)
+    }
+    if p.config.Jitter > 0 {
+        delay += delay * p.config.Jitter * (2*rand.Float64() - 1)
+    }
+    if delay < 0 {
+        delay = 0
+    }
+    return time.Duration(delay)
+}
+
+func isTimeout(err error) bool {
+    if errors.Is(err, context.DeadlineExceeded) {
+        return true
+    }
+    var netErr net.Error
+    return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// parseRetryAfter accepts both forms allowed by RFC 9110: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+    value = strings.TrimSpace(value)
+    if value == "" {
+        return 0, false
+    }
+    if seconds, err := strconv.Atoi(value); err == nil {
+        if seconds < 0 {
+            return 0, false
+        }
+        return time.Duration(seconds) * time.Second, true
+    }
+    if at, err := http.ParseTime(value); err == nil {
+        if at.Before(now) {
+            return 0, true
+        }
+        return at.Sub(now), true
+    }
+    return 0, false
+}
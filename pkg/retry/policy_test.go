@@ -0,0 +1,179 @@
+// pkg/retry/policy_test.go
+package retry
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "testing"
+    "time"
+)
+
+func newTestPolicy() *Policy {
+    return NewPolicy(&Config{
+        MaxAttempts:          3,
+        BackoffBase:          time.Second,
+        BackoffCap:           10 * time.Second,
+        RetryableStatusCodes: []int{429, 503},
+        RetryTimeouts:        true,
+        RetryNetworkErrors:   true,
+    })
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+type temporaryError struct{ temporary bool }
+
+func (e temporaryError) Error() string   { return "temporary" }
+func (e temporaryError) Temporary() bool { return e.temporary }
+
+func TestRetryable(t *testing.T) {
+    refused := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+    tests := []struct {
+        name       string
+        config     Config
+        statusCode int
+        err        error
+        want       bool
+    }{
+        {"listed status", Config{RetryableStatusCodes: []int{503}}, 503, nil, true},
+        {"unlisted status", Config{RetryableStatusCodes: []int{503}}, 404, nil, false},
+        {"success", Config{RetryableStatusCodes: []int{503}}, 200, nil, false},
+        {"timeout", Config{RetryTimeouts: true}, 0, timeoutError{}, true},
+        {"timeout not retried", Config{RetryTimeouts: false, RetryNetworkErrors: true}, 0, timeoutError{}, false},
+        {"deadline", Config{RetryTimeouts: true}, 0, context.DeadlineExceeded, true},
+        {"wrapped deadline", Config{RetryTimeouts: true}, 0, fmt.Errorf("fetch: %w", context.DeadlineExceeded), true},
+        {"network error", Config{RetryNetworkErrors: true}, 0, refused, true},
+        {"network error not retried", Config{}, 0, refused, false},
+        {"temporary error", Config{}, 0, temporaryError{true}, true},
+        {"permanent error", Config{}, 0, temporaryError{false}, false},
+        {"plain error", Config{RetryTimeouts: true, RetryNetworkErrors: true}, 0, errors.New("bad"), false},
+        {"error beats status", Config{RetryableStatusCodes: []int{503}}, 503, errors.New("bad"), false},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            config := tt.config
+            if got := NewPolicy(&config).Retryable(tt.statusCode, tt.err); got != tt.want {
+                t.Errorf("Retryable(%d, %v) = %v, want %v", tt.statusCode, tt.err, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestBackoff(t *testing.T) {
+    p := newTestPolicy()
+
+    tests := []struct {
+        attempts int
+        want     time.Duration
+    }{
+        {0, time.Second},
+        {1, time.Second},
+        {2, 2 * time.Second},
+        {3, 4 * time.Second},
+        {4, 8 * time.Second},
+        {5, 10 * time.Second},
+        {60, 10 * time.Second},
+    }
+    for _, tt := range tests {
+        if got := p.Backoff(tt.attempts); got != tt.want {
+            t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+        }
+    }
+}
+
+func TestBackoffJitter(t *testing.T) {
+    tests := []struct {
+        jitter   float64
+        min, max time.Duration
+    }{
+        {0.25, 3 * time.Second, 5 * time.Second},
+        {1, 0, 8 * time.Second},
+        {2, 0, 12 * time.Second},
+    }
+
+    for _, tt := range tests {
+        p := NewPolicy(&Config{BackoffBase: time.Second, Jitter: tt.jitter})
+        spread := false
+        for i := 0; i < 200; i++ {
+            got := p.Backoff(3)
+            if got < tt.min || got > tt.max {
+                t.Fatalf("jitter %v: Backoff(3) = %v, want within [%v, %v]", tt.jitter, got, tt.min, tt.max)
+            }
+            if got != 4*time.Second {
+                spread = true
+            }
+        }
+        if !spread {
+            t.Errorf("jitter %v: Backoff never moved from 4s", tt.jitter)
+        }
+    }
+}
+
+func TestDecide(t *testing.T) {
+    p := newTestPolicy()
+    inAMinute := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+
+    tests := []struct {
+        name       string
+        attempts   int
+        statusCode int
+        retryAfter string
+        retry      bool
+        min, max   time.Duration
+    }{
+        {"backs off", 1, 503, "", true, time.Second, time.Second},
+        {"backs off longer", 2, 429, "", true, 2 * time.Second, 2 * time.Second},
+        {"out of attempts", 3, 503, "", false, 0, 0},
+        {"not retryable", 1, 404, "", false, 0, 0},
+        {"Retry-After seconds", 1, 429, "30", true, 30 * time.Second, 30 * time.Second},
+        {"Retry-After shorter than backoff", 2, 429, "1", true, 2 * time.Second, 2 * time.Second},
+        {"Retry-After date", 1, 503, inAMinute, true, 58 * time.Second, time.Minute},
+        {"Retry-After garbage", 1, 503, "soon", true, time.Second, time.Second},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            retry, delay := p.Decide(tt.attempts, tt.statusCode, nil, tt.retryAfter)
+            if retry != tt.retry || delay < tt.min || delay > tt.max {
+                t.Errorf("Decide = %v, %v, want %v within [%v, %v]", retry, delay, tt.retry, tt.min, tt.max)
+            }
+        })
+    }
+}
+
+func TestParseRetryAfter(t *testing.T) {
+    now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+    tests := []struct {
+        value string
+        want  time.Duration
+        ok    bool
+    }{
+        {"120", 2 * time.Minute, true},
+        {" 5 ", 5 * time.Second, true},
+        {"0", 0, true},
+        {"-1", 0, false},
+        {"", 0, false},
+        {"1.5", 0, false},
+        {"Wed, 01 May 2024 12:01:30 GMT", 90 * time.Second, true},
+        {"Wed, 01 May 2024 11:00:00 GMT", 0, true},
+        {"Wednesday, 01-May-24 12:00:10 GMT", 10 * time.Second, true},
+        {"tomorrow", 0, false},
+    }
+
+    for _, tt := range tests {
+        got, ok := parseRetryAfter(tt.value, now)
+        if got != tt.want || ok != tt.ok {
+            t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+        }
+    }
+}
@@ -0,0 +1,226 @@
+// pkg/robots/checker.go
+package robots
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+)
+
+// Cache persists raw robots.txt bodies between processes, together with
+// when they expire.
+type Cache interface {
+    GetRobotsTxt(host string) ([]byte, time.Time, bool, error)
+    CacheRobotsTxt(host string, body []byte, expires time.Time) error
+}
+
+type Config struct {
+    UserAgent string
+    CacheTTL  time.Duration
+    Timeout   time.Duration
+    // MaxHosts caps how many hosts' rules are held in memory, 10000 by
+    // default
+    MaxHosts int
+}
+
+// Checker fetches, caches and evaluates robots.txt files per host.
+type Checker struct {
+    config *Config
+    agent  string
+    cache  Cache
+    client *http.Client
+
+    mu       sync.Mutex
+    entries  map[string]*entry
+    maxHosts int
+}
+
+type entry struct {
+    ready   chan struct{}
+    rules   *Rules
+    err     error
+    expires time.Time
+}
+
+// UnreachableError reports a robots.txt that could not be fetched because
+// the host or server failed. RFC 9309 treats the host as fully disallowed
+// meanwhile, which only lasts until the file can be read.
+type UnreachableError struct {
+    Origin string
+    Reason string
+}
+
+func (e *UnreachableError) Error() string {
+    return fmt.Sprintf("robots.txt of %s unreachable: %s", e.Origin, e.Reason)
+}
+
+// Temporary marks the failure as worth retrying.
+func (e *UnreachableError) Temporary() bool {
+    return true
+}
+
+// disallowAll stands in for robots.txt files that could not be fetched.
+var disallowAll = []byte("User-agent: *\nDisallow: /\n")
+
+// maxBodySize is the parsing limit RFC 9309 requires crawlers to support.
+const maxBodySize = 500 * 1024
+
+func NewChecker(config *Config, cache Cache) *Checker {
+    maxHosts := config.MaxHosts
+    if maxHosts <= 0 {
+        maxHosts = 10000
+    }
+    return &Checker{
+        config:   config,
+        agent:    ProductToken(config.UserAgent),
+        cache:    cache,
+        client:   &http.Client{Timeout: config.Timeout},
+        entries:  make(map[string]*entry),
+        maxHosts: maxHosts,
+    }
+}
+
+// ProductToken extracts the robots.txt user-agent token from a full
+// User-Agent header, e.g. "Crawler666" from "Crawler666/1.0".
+func ProductToken(userAgent string) string {
+    fields := strings.Fields(userAgent)
+    if len(fields) == 0 {
+        return ""
+    }
+    token := fields[0]
+    if i := strings.IndexByte(token, '/'); i >= 0 {
+        token = token[:i]
+    }
+    return token
+}
+
+// Allowed reports whether rawURL may be fetched, together with the
+// Crawl-delay that applies to its host. The error is an *UnreachableError
+// while the host's robots.txt cannot be fetched.
+func (c *Checker) Allowed(rawURL string) (bool, time.Duration, error) {
+    rules, err := c.Rules(rawURL)
+    if err != nil {
+        return false, 0, err
+    }
+    return rules.Allowed(c.agent, rawURL), rules.CrawlDelay(c.agent), nil
+}
+
+// Rules returns the robots.txt rules for the host of rawURL, fetching
+// them at most once per host and cache period. When the file is
+// unreachable, the rules disallow everything and come with an
+// *UnreachableError.
+func (c *Checker) Rules(rawURL string) (*Rules, error) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return nil, fmt.Errorf("invalid URL: %v", err)
+    }
+    origin := u.Scheme + "://" + u.Host
+
+    c.mu.Lock()
+    if e, exists := c.entries[origin]; exists {
+        select {
+        case <-e.ready:
+            if time.Now().Before(e.expires) {
+                c.mu.Unlock()
+                return e.rules, e.err
+            }
+        default:
+            // Another worker is fetching this host's file
+            c.mu.Unlock()
+            <-e.ready
+            return e.rules, e.err
+        }
+    }
+    if len(c.entries) >= c.maxHosts {
+        c.evict(time.Now())
+    }
+    e := &entry{ready: make(chan struct{})}
+    c.entries[origin] = e
+    c.mu.Unlock()
+
+    body, expires, err := c.load(origin)
+    e.rules, e.err = Parse(body), err
+    e.expires = expires
+    close(e.ready)
+
+    return e.rules, e.err
+}
+
+// evict drops expired entries, then settled ones at random until a tenth
+// of the room is free again. c.mu must be held.
+func (c *Checker) evict(now time.Time) {
+    keep := c.maxHosts - c.maxHosts/10 - 1
+    for origin, e := range c.entries {
+        select {
+        case <-e.ready:
+        default:
+            // Still being fetched, with workers waiting on it
+            continue
+        }
+        if now.After(e.expires) || len(c.entries) > keep {
+            delete(c.entries, origin)
+        }
+    }
+}
+
+// load returns the host's robots.txt and when it must be read again.
+func (c *Checker) load(origin string) ([]byte, time.Time, error) {
+    if c.cache != nil {
+        body, expires, found, err := c.cache.GetRobotsTxt(origin)
+        if err == nil && found && time.Now().Before(expires) {
+            return body, expires, nil
+        }
+    }
+
+    body, ttl, err := c.fetch(origin)
+    expires := time.Now().Add(ttl)
+    if err != nil {
+        // Not shared, so that every process retries the host on its own
+        return disallowAll, expires, err
+    }
+    if c.cache != nil {
+        c.cache.CacheRobotsTxt(origin, body, expires)
+    }
+    return body, expires, nil
+}
+
+func (c *Checker) fetch(origin string) ([]byte, time.Duration, error) {
+    // Retry unreachable hosts sooner than the regular cache period
+    retryTTL := c.config.CacheTTL
+    if retryTTL > time.Hour {
+        retryTTL = time.Hour
+    }
+    unreachable := func(reason string) ([]byte, time.Duration, error) {
+        return nil, retryTTL, &UnreachableError{Origin: origin, Reason: reason}
+    }
+
+    req, err := http.NewRequest("GET", origin+"/robots.txt", nil)
+    if err != nil {
+        return unreachable(err.Error())
+    }
+    req.Header.Set("User-Agent", c.config.UserAgent)
+
+    resp, err := c.client.Do(req)
+    if err != nil {
+        return unreachable(err.Error())
+    }
+    defer resp.Body.Close()
+
+    switch {
+    case resp.StatusCode >= 500:
+        return unreachable(resp.Status)
+    case resp.StatusCode >= 400:
+        // No robots.txt means no restrictions
+        return []byte{}, c.config.CacheTTL, nil
+    }
+
+    body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+    if err != nil {
+        return unreachable(err.Error())
+    }
+    return body, c.config.CacheTTL, nil
+}
@@ -0,0 +1,129 @@
+// pkg/robots/checker_test.go
+package robots
+
+import (
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestCheckerFetch(t *testing.T) {
+    tests := []struct {
+        name        string
+        status      int
+        body        string
+        allowed     bool
+        unreachable bool
+        ttl         time.Duration
+    }{
+        {"rules apply", http.StatusOK, "User-agent: *\nDisallow: /x\n", false, false, 24 * time.Hour},
+        {"missing file allows all", http.StatusNotFound, "", true, false, 24 * time.Hour},
+        {"server error disallows for a while", http.StatusServiceUnavailable, "", false, true, time.Hour},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                w.WriteHeader(tt.status)
+                w.Write([]byte(tt.body))
+            }))
+            defer server.Close()
+
+            checker := NewChecker(&Config{UserAgent: "Crawler666/1.0", CacheTTL: 24 * time.Hour, Timeout: time.Second}, nil)
+            allowed, _, err := checker.Allowed(server.URL + "/x")
+
+            var unreachable *UnreachableError
+            if errors.As(err, &unreachable) != tt.unreachable {
+                t.Fatalf("Allowed error = %v, want unreachable %v", err, tt.unreachable)
+            }
+            if allowed != tt.allowed {
+                t.Errorf("Allowed = %v, want %v", allowed, tt.allowed)
+            }
+
+            e := checker.entries[server.URL]
+            if ttl := time.Until(e.expires); ttl > tt.ttl || ttl < tt.ttl-time.Minute {
+                t.Errorf("cached for %v, want %v", ttl.Round(time.Second), tt.ttl)
+            }
+        })
+    }
+}
+
+type memoryCache map[string]cached
+
+type cached struct {
+    body    []byte
+    expires time.Time
+}
+
+func (m memoryCache) GetRobotsTxt(host string) ([]byte, time.Time, bool, error) {
+    c, found := m[host]
+    return c.body, c.expires, found, nil
+}
+
+func (m memoryCache) CacheRobotsTxt(host string, body []byte, expires time.Time) error {
+    m[host] = cached{body, expires}
+    return nil
+}
+
+func TestCheckerSharedCache(t *testing.T) {
+    fetches := 0
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fetches++
+        w.Write([]byte("User-agent: *\nDisallow: /fetched\n"))
+    }))
+    defer server.Close()
+
+    config := &Config{UserAgent: "Crawler666/1.0", CacheTTL: 24 * time.Hour, Timeout: time.Second}
+    expires := time.Now().Add(10 * time.Minute)
+    cache := memoryCache{server.URL: {[]byte("User-agent: *\nDisallow: /cached\n"), expires}}
+
+    checker := NewChecker(config, cache)
+    if allowed, _, _ := checker.Allowed(server.URL + "/cached"); allowed {
+        t.Errorf("Allowed(/cached) = true, want the cached rules")
+    }
+    if got := checker.entries[server.URL].expires; !got.Equal(expires) {
+        t.Errorf("cached entry expires %v, want the shared expiry %v", got, expires)
+    }
+
+    // An expired shared entry is fetched again and stored with a new expiry
+    cache[server.URL] = cached{[]byte("User-agent: *\nDisallow: /cached\n"), time.Now().Add(-time.Second)}
+    checker = NewChecker(config, cache)
+    if allowed, _, _ := checker.Allowed(server.URL + "/cached"); !allowed {
+        t.Errorf("Allowed(/cached) = false, want the fetched rules")
+    }
+    if fetches != 1 {
+        t.Errorf("fetched %d times, want 1", fetches)
+    }
+    if ttl := time.Until(cache[server.URL].expires); ttl < 23*time.Hour {
+        t.Errorf("shared entry expires in %v, want about 24h", ttl.Round(time.Second))
+    }
+}
+
+func TestCheckerEviction(t *testing.T) {
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer server.Close()
+
+    checker := NewChecker(&Config{CacheTTL: time.Hour, Timeout: time.Second, MaxHosts: 10}, nil)
+    for i := 0; i < 25; i++ {
+        // Distinct origins for the same server
+        host := strings.Replace(server.URL, "127.0.0.1", fmt.Sprintf("127.0.0.%d", i+1), 1)
+        checker.Rules(host + "/")
+        if n := len(checker.entries); n > 10 {
+            t.Fatalf("holding %d hosts, want at most 10", n)
+        }
+    }
+
+    // Expired entries go first
+    past := time.Now().Add(-time.Minute)
+    for _, e := range checker.entries {
+        e.expires = past
+    }
+    checker.evict(time.Now())
+    if n := len(checker.entries); n != 0 {
+        t.Errorf("holding %d expired hosts after eviction, want 0", n)
+    }
+}
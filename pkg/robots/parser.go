@@ -0,0 +1,166 @@
+// pkg/robots/parser.go
+package robots
+
+import (
+    "bufio"
+    "bytes"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Rules is a parsed robots.txt file.
+type Rules struct {
+    groups   []*group
+    Sitemaps []string
+}
+
+type group struct {
+    agents     []string
+    rules      []rule
+    crawlDelay time.Duration
+}
+
+type rule struct {
+    allow   bool
+    pattern string
+}
+
+// Parse reads a robots.txt body. Unknown directives and malformed lines
+// are ignored, as recommended by RFC 9309.
+func Parse(body []byte) *Rules {
+    rules := &Rules{}
+
+    var current *group
+    lastWasAgent := false
+
+    scanner := bufio.NewScanner(bytes.NewReader(body))
+    for scanner.Scan() {
+        line := scanner.Text()
+        if i := strings.IndexByte(line, '#'); i >= 0 {
+            line = line[:i]
+        }
+
+        colon := strings.IndexByte(line, ':')
+        if colon < 0 {
+            continue
+        }
+        key := strings.ToLower(strings.TrimSpace(line[:colon]))
+        value := strings.TrimSpace(line[colon+1:])
+
+        switch key {
+        case "user-agent":
+            // Consecutive user-agent lines share one group
+            if current == nil || !lastWasAgent {
+                current = &group{}
+                rules.groups = append(rules.groups, current)
+            }
+            current.agents = append(current.agents, strings.ToLower(value))
+            lastWasAgent = true
+            continue
+        case "allow", "disallow":
+            if current != nil && value != "" {
+                current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+            }
+        case "crawl-delay":
+            if current != nil {
+                if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+                    current.crawlDelay = time.Duration(seconds * float64(time.Second))
+                }
+            }
+        case "sitemap":
+            if value != "" {
+                rules.Sitemaps = append(rules.Sitemaps, value)
+            }
+        }
+        lastWasAgent = false
+    }
+
+    return rules
+}
+
+// Allowed reports whether the given user-agent token may fetch rawURL.
+func (r *Rules) Allowed(agent, rawURL string) bool {
+    g := r.match(agent)
+    if g == nil {
+        return true
+    }
+
+    path := "/"
+    if u, err := url.Parse(rawURL); err == nil {
+        path = u.EscapedPath()
+        if path == "" {
+            path = "/"
+        }
+        if u.RawQuery != "" {
+            path += "?" + u.RawQuery
+        }
+    }
+
+    // The longest matching rule wins; Allow wins a tie.
+    allowed, best := true, -1
+    for _, rl := range g.rules {
+        if rl.pattern == "" || !matchPattern(rl.pattern, path) {
+            continue
+        }
+        if len(rl.pattern) > best || (len(rl.pattern) == best && rl.allow) {
+            allowed, best = rl.allow, len(rl.pattern)
+        }
+    }
+    return allowed
+}
+
+// CrawlDelay returns the Crawl-delay declared for the given user-agent
+// token, or zero when there is none.
+func (r *Rules) CrawlDelay(agent string) time.Duration {
+    if g := r.match(agent); g != nil {
+        return g.crawlDelay
+    }
+    return 0
+}
+
+func (r *Rules) match(agent string) *group {
+    agent = strings.ToLower(agent)
+
+    var fallback *group
+    for _, g := range r.groups {
+        for _, a := range g.agents {
+            if a == agent {
+                return g
+            }
+            if a == "*" && fallback == nil {
+                fallback = g
+            }
+        }
+    }
+    return fallback
+}
+
+// matchPattern implements the robots.txt path syntax, where '*' matches
+// any sequence of characters and a trailing '$' anchors the end.
+func matchPattern(pattern, path string) bool {
+    anchored := strings.HasSuffix(pattern, "$")
+    if anchored {
+        pattern = pattern[:len(pattern)-1]
+    }
+
+    parts := strings.Split(pattern, "*")
+    if !strings.HasPrefix(path, parts[0]) {
+        return false
+    }
+    pos := len(parts[0])
+
+    for i := 1; i < len(parts); i++ {
+        if i == len(parts)-1 && anchored {
+            return len(path)-pos >= len(parts[i]) && strings.HasSuffix(path, parts[i])
+        }
+        idx := strings.Index(path[pos:], parts[i])
+        if idx < 0 {
+            return false
+        }
+        pos += idx + len(parts[i])
+    }
+
+    return !anchored || pos == len(path)
+}
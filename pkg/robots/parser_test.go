@@ -0,0 +1,140 @@
+// pkg/robots/parser_test.go
+package robots
+
+import (
+    "reflect"
+    "testing"
+    "time"
+)
+
+const sampleRobots = `
+# Comments and unknown lines are ignored
+User-agent: *
+Disallow: /private/
+Allow: /private/public/
+Disallow: /*.pdf$
+Crawl-delay: 2
+
+User-agent: Crawler666
+User-agent: OtherBot
+Disallow: /search
+Allow: /search/about
+Crawl-delay: 0.5
+
+User-agent: Greedy
+Disallow: /
+Allow: /$
+
+Sitemap: https://example.com/sitemap.xml
+Sitemap: https://example.com/news.xml
+`
+
+func TestAllowed(t *testing.T) {
+    rules := Parse([]byte(sampleRobots))
+
+    tests := []struct {
+        name  string
+        agent string
+        url   string
+        want  bool
+    }{
+        {"fallback group allows", "SomeBot", "https://example.com/", true},
+        {"fallback group disallows", "SomeBot", "https://example.com/private/x", false},
+        {"longer allow wins", "SomeBot", "https://example.com/private/public/x", true},
+        {"anchored wildcard", "SomeBot", "https://example.com/docs/a.pdf", false},
+        {"anchor stops at end", "SomeBot", "https://example.com/docs/a.pdf?v=1", true},
+        {"named group replaces fallback", "Crawler666", "https://example.com/private/x", true},
+        {"agent match ignores case", "crawler666", "https://example.com/search?q=1", false},
+        {"second agent of a group", "OtherBot", "https://example.com/search", false},
+        {"longer allow in named group", "Crawler666", "https://example.com/search/about", true},
+        {"anchored allow of root", "Greedy", "https://example.com/", true},
+        {"disallow all but root", "Greedy", "https://example.com/page", false},
+        {"query is part of the path", "SomeBot", "https://example.com/x?private/", true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := rules.Allowed(tt.agent, tt.url); got != tt.want {
+                t.Errorf("Allowed(%q, %q) = %v, want %v", tt.agent, tt.url, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestAllowedTieGoesToAllow(t *testing.T) {
+    rules := Parse([]byte("User-agent: *\nDisallow: /page\nAllow: /page\n"))
+    if !rules.Allowed("bot", "https://example.com/page") {
+        t.Error("equally long allow and disallow rules should allow")
+    }
+}
+
+func TestAllowedWithoutRules(t *testing.T) {
+    for _, body := range []string{"", "Disallow: /\n", "User-agent: OtherBot\nDisallow: /\n"} {
+        if !Parse([]byte(body)).Allowed("bot", "https://example.com/x") {
+            t.Errorf("robots.txt %q should not restrict bot", body)
+        }
+    }
+}
+
+func TestMatchPattern(t *testing.T) {
+    tests := []struct {
+        pattern string
+        path    string
+        want    bool
+    }{
+        {"/", "/anything", true},
+        {"/fish", "/fish.html", true},
+        {"/fish", "/Fish", false},
+        {"/fish/", "/fish", false},
+        {"/*.php", "/index.php", true},
+        {"/*.php", "/dir/index.php?x=1", true},
+        {"/*.php$", "/index.php", true},
+        {"/*.php$", "/index.php5", false},
+        {"/fish*", "/fishheads", true},
+        {"/a*b*c", "/axxbyyc", true},
+        {"/a*b*c", "/axxcyyb", false},
+        {"/a*c$", "/abcbc", true},
+        {"/page$", "/page", true},
+        {"/page$", "/page/", false},
+        {"*", "/", true},
+    }
+
+    for _, tt := range tests {
+        if got := matchPattern(tt.pattern, tt.path); got != tt.want {
+            t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+        }
+    }
+}
+
+func TestCrawlDelayAndSitemaps(t *testing.T) {
+    rules := Parse([]byte(sampleRobots))
+
+    delays := map[string]time.Duration{
+        "SomeBot":    2 * time.Second,
+        "Crawler666": 500 * time.Millisecond,
+        "Greedy":     0,
+    }
+    for agent, want := range delays {
+        if got := rules.CrawlDelay(agent); got != want {
+            t.Errorf("CrawlDelay(%q) = %v, want %v", agent, got, want)
+        }
+    }
+
+    want := []string{"https://example.com/sitemap.xml", "https://example.com/news.xml"}
+    if !reflect.DeepEqual(rules.Sitemaps, want) {
+        t.Errorf("Sitemaps = %v, want %v", rules.Sitemaps, want)
+    }
+}
+
+func TestProductToken(t *testing.T) {
+    tests := map[string]string{
+        "Crawler666/1.0 (+https://example.com/bot)": "Crawler666",
+        "Crawler666":                                "Crawler666",
+        "":                                          "",
+    }
+    for userAgent, want := range tests {
+        if got := ProductToken(userAgent); got != want {
+            t.Errorf("ProductToken(%q) = %q, want %q", userAgent, got, want)
+        }
+    }
+}
@@ -0,0 +1,275 @@
+// pkg/sitemap/sitemap.go
+package sitemap
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/xml"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "golang.org/x/net/html/charset"
+)
+
+// DefaultPriority is what the sitemap protocol assumes when <priority> is
+// absent.
+const DefaultPriority = 0.5
+
+// The protocol caps a sitemap at 50MB uncompressed
+const defaultMaxBodySize = 50 * 1024 * 1024
+
+// Indexes are not supposed to nest, but some sites do; bound the recursion
+const maxNesting = 5
+
+// ErrStop may be returned by a Walk callback to end the walk early without
+// reporting an error.
+var ErrStop = errors.New("stop walking sitemaps")
+
+// Entry is one <url> of a sitemap.
+type Entry struct {
+    Loc        string
+    LastMod    time.Time
+    Priority   float64
+    ChangeFreq string
+}
+
+type Config struct {
+    UserAgent   string
+    Timeout     time.Duration
+    MaxSitemaps int
+    MaxBodySize int64
+}
+
+// Fetcher downloads and parses sitemaps, following sitemap indexes.
+type Fetcher struct {
+    config *Config
+    client *http.Client
+}
+
+type document struct {
+    XMLName  xml.Name
+    URLs     []xmlEntry `xml:"url"`
+    Sitemaps []xmlEntry `xml:"sitemap"`
+}
+
+type xmlEntry struct {
+    Loc        string `xml:"loc"`
+    LastMod    string `xml:"lastmod"`
+    Priority   string `xml:"priority"`
+    ChangeFreq string `xml:"changefreq"`
+}
+
+func NewFetcher(config *Config) *Fetcher {
+    if config.MaxBodySize <= 0 {
+        config.MaxBodySize = defaultMaxBodySize
+    }
+    return &Fetcher{
+        config: config,
+        client: &http.Client{Timeout: config.Timeout},
+    }
+}
+
+// Candidates returns the sitemaps to try for a site: those its robots.txt
+// declares, or the conventional /sitemap.xml when it declares none.
+func Candidates(origin string, declared []string) []string {
+    if len(declared) > 0 {
+        return declared
+    }
+    return []string{strings.TrimSuffix(origin, "/") + "/sitemap.xml"}
+}
+
+// Walk calls fn for every URL listed in the given sitemaps, descending into
+// sitemap indexes. Each sitemap is fetched at most once, and no more than
+// MaxSitemaps are fetched in total. A sitemap that fails to download or
+// parse is skipped and reported in the returned error once the walk ends.
+func (f *Fetcher) Walk(ctx context.Context, sitemaps []string, fn func(Entry) error) error {
+    w := &walk{fetcher: f, fn: fn, visited: make(map[string]bool)}
+    for _, sitemapURL := range sitemaps {
+        if err := w.visit(ctx, sitemapURL, 0); err != nil {
+            if errors.Is(err, ErrStop) {
+                break
+            }
+            return err
+        }
+    }
+    return errors.Join(w.failures...)
+}
+
+type walk struct {
+    fetcher  *Fetcher
+    fn       func(Entry) error
+    visited  map[string]bool
+    failures []error
+}
+
+func (w *walk) visit(ctx context.Context, sitemapURL string, depth int) error {
+    if w.visited[sitemapURL] || depth > maxNesting {
+        return nil
+    }
+    if max := w.fetcher.config.MaxSitemaps; max > 0 && len(w.visited) >= max {
+        return nil
+    }
+    w.visited[sitemapURL] = true
+
+    body, err := w.fetcher.fetch(ctx, sitemapURL)
+    if err != nil {
+        if ctx.Err() != nil {
+            return ctx.Err()
+        }
+        w.failures = append(w.failures, fmt.Errorf("%s: %v", sitemapURL, err))
+        return nil
+    }
+
+    doc, err := parse(body)
+    if err != nil {
+        w.failures = append(w.failures, fmt.Errorf("%s: %v", sitemapURL, err))
+        return nil
+    }
+
+    for _, child := range doc.Sitemaps {
+        loc := resolve(sitemapURL, child.Loc)
+        if loc == "" {
+            continue
+        }
+        if err := w.visit(ctx, loc, depth+1); err != nil {
+            return err
+        }
+    }
+
+    for _, u := range doc.URLs {
+        loc := resolve(sitemapURL, u.Loc)
+        if loc == "" {
+            continue
+        }
+        entry := Entry{
+            Loc:        loc,
+            LastMod:    parseLastMod(u.LastMod),
+            Priority:   parsePriority(u.Priority),
+            ChangeFreq: strings.ToLower(strings.TrimSpace(u.ChangeFreq)),
+        }
+        if err := w.fn(entry); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, sitemapURL string) ([]byte, error) {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, sitemapURL, nil)
+    if err != nil {
+        return nil, err
+    }
+    req.Header.Set("User-Agent", f.config.UserAgent)
+
+    resp, err := f.client.Do(req)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+    }
+
+    limit := f.config.MaxBodySize
+    body, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+    if err != nil {
+        return nil, err
+    }
+
+    // Go's transport already undoes Content-Encoding: gzip, so this only
+    // catches .xml.gz files served as they are
+    if len(body) >= 2 && body[0] == 0x1f && body[1] == 0x8b {
+        gz, err := gzip.NewReader(bytes.NewReader(body))
+        if err != nil {
+            return nil, fmt.Errorf("invalid gzip: %v", err)
+        }
+        defer gz.Close()
+        body, err = io.ReadAll(io.LimitReader(gz, limit))
+        if err != nil {
+            return nil, fmt.Errorf("invalid gzip: %v", err)
+        }
+    }
+    return body, nil
+}
+
+// parse reads an XML urlset or sitemapindex, or a plain text sitemap with
+// one URL per line.
+func parse(body []byte) (*document, error) {
+    trimmed := bytes.TrimSpace(body)
+    if len(trimmed) > 0 && trimmed[0] != '<' {
+        doc := &document{}
+        scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+        for scanner.Scan() {
+            if line := strings.TrimSpace(scanner.Text()); line != "" {
+                doc.URLs = append(doc.URLs, xmlEntry{Loc: line})
+            }
+        }
+        return doc, scanner.Err()
+    }
+
+    doc := &document{}
+    decoder := xml.NewDecoder(bytes.NewReader(body))
+    decoder.CharsetReader = charset.NewReaderLabel
+    decoder.Strict = false
+    if err := decoder.Decode(doc); err != nil {
+        return nil, fmt.Errorf("invalid sitemap: %v", err)
+    }
+    switch doc.XMLName.Local {
+    case "urlset", "sitemapindex":
+        return doc, nil
+    }
+    return nil, fmt.Errorf("unexpected root element <%s>", doc.XMLName.Local)
+}
+
+func resolve(base, loc string) string {
+    loc = strings.TrimSpace(loc)
+    if loc == "" {
+        return ""
+    }
+    b, err := url.Parse(base)
+    if err != nil {
+        return ""
+    }
+    u, err := b.Parse(loc)
+    if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+        return ""
+    }
+    return u.String()
+}
+
+// W3C datetime in decreasing precision; RFC 3339 parsing accepts
+// fractional seconds on its own
+var lastModLayouts = []string{
+    time.RFC3339,
+    "2006-01-02T15:04Z07:00",
+    "2006-01-02",
+    "2006-01",
+    "2006",
+}
+
+func parseLastMod(s string) time.Time {
+    s = strings.TrimSpace(s)
+    for _, layout := range lastModLayouts {
+        if t, err := time.Parse(layout, s); err == nil {
+            return t
+        }
+    }
+    return time.Time{}
+}
+
+func parsePriority(s string) float64 {
+    p, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+    if err != nil || p < 0 || p > 1 {
+        return DefaultPriority
+    }
+    return p
+}
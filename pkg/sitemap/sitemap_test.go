@@ -0,0 +1,240 @@
+// pkg/sitemap/sitemap_test.go
+package sitemap
+
+import (
+    "bytes"
+    "compress/gzip"
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestParse(t *testing.T) {
+    tests := []struct {
+        name     string
+        body     string
+        urls     []string
+        sitemaps []string
+        wantErr  bool
+    }{
+        {
+            name: "urlset",
+            body: `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>https://example.com/a</loc><lastmod>2024-05-01</lastmod></url>
+  <url><loc> https://example.com/b </loc></url>
+</urlset>`,
+            urls: []string{"https://example.com/a", " https://example.com/b "},
+        },
+        {
+            name: "index",
+            body: `<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://example.com/s1.xml</loc></sitemap>
+  <sitemap><loc>https://example.com/s2.xml.gz</loc></sitemap>
+</sitemapindex>`,
+            sitemaps: []string{"https://example.com/s1.xml", "https://example.com/s2.xml.gz"},
+        },
+        {
+            name: "latin-1 encoding",
+            body: "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><urlset><url><loc>https://example.com/caf\xe9</loc></url></urlset>",
+            urls: []string{"https://example.com/café"},
+        },
+        {
+            name: "plain text",
+            body: "\nhttps://example.com/a\n\n  https://example.com/b  \n",
+            urls: []string{"https://example.com/a", "https://example.com/b"},
+        },
+        {name: "other root element", body: `<rss><channel/></rss>`, wantErr: true},
+        {name: "broken XML", body: `<urlset><url>`, wantErr: true},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            doc, err := parse([]byte(tt.body))
+            if tt.wantErr {
+                if err == nil {
+                    t.Fatal("parse succeeded, want an error")
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("parse: %v", err)
+            }
+            if got := locs(doc.URLs); !reflect.DeepEqual(got, tt.urls) {
+                t.Errorf("URLs = %q, want %q", got, tt.urls)
+            }
+            if got := locs(doc.Sitemaps); !reflect.DeepEqual(got, tt.sitemaps) {
+                t.Errorf("Sitemaps = %q, want %q", got, tt.sitemaps)
+            }
+        })
+    }
+}
+
+func locs(entries []xmlEntry) []string {
+    var out []string
+    for _, e := range entries {
+        out = append(out, e.Loc)
+    }
+    return out
+}
+
+func TestParseLastMod(t *testing.T) {
+    tests := []struct {
+        in   string
+        want time.Time
+    }{
+        {"2024-05-06T10:20:30+02:00", time.Date(2024, 5, 6, 8, 20, 30, 0, time.UTC)},
+        {"2024-05-06T10:20:30.5Z", time.Date(2024, 5, 6, 10, 20, 30, 5e8, time.UTC)},
+        {"2024-05-06T10:20Z", time.Date(2024, 5, 6, 10, 20, 0, 0, time.UTC)},
+        {" 2024-05-06 ", time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+        {"2024-05", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+        {"2024", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+        {"yesterday", time.Time{}},
+        {"", time.Time{}},
+    }
+    for _, tt := range tests {
+        if got := parseLastMod(tt.in); !got.Equal(tt.want) {
+            t.Errorf("parseLastMod(%q) = %v, want %v", tt.in, got, tt.want)
+        }
+    }
+}
+
+func TestParsePriority(t *testing.T) {
+    tests := map[string]float64{
+        "0.8":  0.8,
+        " 1 ":  1,
+        "0":    0,
+        "":     DefaultPriority,
+        "high": DefaultPriority,
+        "1.5":  DefaultPriority,
+        "-0.1": DefaultPriority,
+    }
+    for in, want := range tests {
+        if got := parsePriority(in); got != want {
+            t.Errorf("parsePriority(%q) = %v, want %v", in, got, want)
+        }
+    }
+}
+
+func TestResolve(t *testing.T) {
+    const base = "https://example.com/sitemaps/index.xml"
+    tests := map[string]string{
+        "https://other.example/a": "https://other.example/a",
+        "pages.xml":               "https://example.com/sitemaps/pages.xml",
+        "/root.xml":               "https://example.com/root.xml",
+        "  ":                      "",
+        "ftp://example.com/a":     "",
+        "javascript:void(0)":      "",
+    }
+    for loc, want := range tests {
+        if got := resolve(base, loc); got != want {
+            t.Errorf("resolve(%q) = %q, want %q", loc, got, want)
+        }
+    }
+}
+
+func TestCandidates(t *testing.T) {
+    tests := []struct {
+        origin   string
+        declared []string
+        want     []string
+    }{
+        {"https://example.com", nil, []string{"https://example.com/sitemap.xml"}},
+        {"https://example.com/", nil, []string{"https://example.com/sitemap.xml"}},
+        {"https://example.com", []string{"https://cdn.example.com/map.xml"}, []string{"https://cdn.example.com/map.xml"}},
+    }
+    for _, tt := range tests {
+        if got := Candidates(tt.origin, tt.declared); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("Candidates(%q, %q) = %q, want %q", tt.origin, tt.declared, got, tt.want)
+        }
+    }
+}
+
+func gzipped(s string) string {
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+    gz.Write([]byte(s))
+    gz.Close()
+    return buf.String()
+}
+
+func TestWalk(t *testing.T) {
+    files := map[string]string{
+        "/index.xml": `<sitemapindex>
+  <sitemap><loc>/pages.xml</loc></sitemap>
+  <sitemap><loc>/posts.xml.gz</loc></sitemap>
+  <sitemap><loc>/missing.xml</loc></sitemap>
+  <sitemap><loc>/index.xml</loc></sitemap>
+</sitemapindex>`,
+        "/pages.xml": `<urlset>
+  <url><loc>/a</loc><priority>0.9</priority><changefreq> Daily </changefreq></url>
+  <url><loc>mailto:someone@example.com</loc></url>
+</urlset>`,
+        "/posts.xml.gz": gzipped(`<urlset><url><loc>/b</loc><lastmod>2024-05-06</lastmod></url></urlset>`),
+    }
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        body, ok := files[r.URL.Path]
+        if !ok {
+            http.NotFound(w, r)
+            return
+        }
+        w.Write([]byte(body))
+    }))
+    defer server.Close()
+
+    tests := []struct {
+        name        string
+        maxSitemaps int
+        stopAfter   int
+        want        []Entry
+        wantErr     string
+    }{
+        {
+            name: "whole index",
+            want: []Entry{
+                {Loc: server.URL + "/a", Priority: 0.9, ChangeFreq: "daily"},
+                {Loc: server.URL + "/b", Priority: DefaultPriority, LastMod: time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)},
+            },
+            wantErr: "missing.xml: HTTP 404",
+        },
+        {
+            name:        "sitemap budget",
+            maxSitemaps: 2,
+            want:        []Entry{{Loc: server.URL + "/a", Priority: 0.9, ChangeFreq: "daily"}},
+        },
+        {
+            name:      "stopped by callback",
+            stopAfter: 1,
+            want:      []Entry{{Loc: server.URL + "/a", Priority: 0.9, ChangeFreq: "daily"}},
+        },
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fetcher := NewFetcher(&Config{UserAgent: "Crawler666/1.0", Timeout: time.Second, MaxSitemaps: tt.maxSitemaps})
+
+            var got []Entry
+            err := fetcher.Walk(context.Background(), []string{server.URL + "/index.xml"}, func(entry Entry) error {
+                got = append(got, entry)
+                if tt.stopAfter > 0 && len(got) >= tt.stopAfter {
+                    return ErrStop
+                }
+                return nil
+            })
+
+            if tt.wantErr == "" && err != nil {
+                t.Errorf("Walk: %v", err)
+            }
+            if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+                t.Errorf("Walk error = %v, want it to mention %q", err, tt.wantErr)
+            }
+            if !reflect.DeepEqual(got, tt.want) {
+                t.Errorf("entries = %+v\nwant %+v", got, tt.want)
+            }
+        })
+    }
+}
@@ -2,27 +2,64 @@
 package storage
 
 import (
+    "bytes"
     "context"
     "database/sql"
     "encoding/json"
+    "errors"
     "fmt"
+    "strconv"
     "time"
 
     "crawler666/internal/models"
+    "crawler666/pkg/warc"
 
-    _ "github.com/lib/pq"
+    "github.com/google/uuid"
+    "github.com/lib/pq"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
     "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/gridfs"
     "go.mongodb.org/mongo-driver/mongo/options"
     "github.com/go-redis/redis/v8"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Interface interface {
     StoreCrawlResult(result *models.CrawlResult) error
-    GetPendingTasks(limit int) ([]*models.CrawlTask, error)
+    CreateTasks(tasks []*models.CrawlTask) ([]*models.CrawlTask, error)
+    LeaseTasks(owner string, limit, perHost int, skipHosts []string, leaseFor time.Duration) ([]*models.CrawlTask, error)
+    StartTask(taskID, leaseID, workerID string, leaseFor time.Duration) (bool, error)
+    CompleteTask(taskID, status, message string) error
+    ReleaseTasks(taskIDs []string) error
+    RetryTask(taskID string, at time.Time, message string) error
+    ReclaimExpiredLeases() (int64, error)
+    ReleaseNodeTasks(nodeID string) (int64, error)
+    GetDeadLetterTasks(sessionID string, limit int) ([]*models.CrawlTask, error)
     CreateCrawlSession(session *models.CrawlSession) error
-    UpdateSessionStats(sessionID string, stats *models.SessionStats) error
+    AddSessionStats(sessionID, nodeID string, delta *models.SessionStats, rate int) (*models.SessionStats, error)
     GetCrawlSessions() ([]*models.CrawlSession, error)
+    GetCrawlSession(sessionID string) (*models.CrawlSession, error)
+    UpdateSessionStatus(sessionID, status string, startedAt, completedAt *time.Time) error
+    CancelSessionTasks(sessionID string) (int64, error)
+    ReleaseSessionLeases(sessionID string) (int64, error)
+    CompleteFinishedSessions() ([]string, error)
     GetCrawlResults(sessionID string, limit int) ([]*models.CrawlResult, error)
+    GetCrawlResult(taskID string) (*models.CrawlResult, error)
+    GetLatestSnapshot(url, excludeSessionID string) (*models.CrawlResult, error)
+    GetURLValidators(url string) (*models.URLValidators, error)
+    SaveURLValidators(validators *models.URLValidators) error
+    StreamCrawlResults(ctx context.Context, sessionID, after string, limit int, fn func(cursor string, result *models.CrawlResult) error) (string, error)
+    StoreBlob(name, contentType string, data []byte) (string, error)
+    ArchiveExchange(sessionID string, exchange *warc.Exchange) error
+    SessionArchives(sessionID string) ([]warc.File, error)
+    CloseSessionArchive(sessionID string) error
+    GetRobotsTxt(host string) ([]byte, time.Time, bool, error)
+    CacheRobotsTxt(host string, body []byte, expires time.Time) error
+    AddSeenURL(sessionID, url string) (bool, error)
+    RemoveSeenURL(sessionID, url string) (bool, error)
+    SetSeenBits(sessionID string, offsets []uint64) (bool, error)
     Close() error
 }
 
@@ -30,6 +67,7 @@ type MultiStorage struct {
     postgres *PostgreSQLStorage
     mongodb  *MongoDBStorage
     redis    *RedisStorage
+    archive  *warc.Archive
 }
 
 type PostgreSQLStorage struct {
@@ -49,6 +87,7 @@ type Config struct {
     PostgreSQL PostgreSQLConfig
     MongoDB    MongoDBConfig
     Redis      RedisConfig
+    WARC       WARCConfig
 }
 
 type PostgreSQLConfig struct {
@@ -71,6 +110,14 @@ type RedisConfig struct {
     DB       int
 }
 
+type WARCConfig struct {
+    Enabled     bool
+    Dir         string
+    Prefix      string
+    MaxFileSize int64
+    Software    string
+}
+
 func NewMultiStorage(config Config) (*MultiStorage, error) {
     // Initialize PostgreSQL
     postgres, err := NewPostgreSQLStorage(config.PostgreSQL)
@@ -90,10 +137,25 @@ func NewMultiStorage(config Config) (*MultiStorage, error) {
         return nil, fmt.Errorf("failed to initialize Redis: %v", err)
     }
 
+    // Initialize WARC archive
+    var archive *warc.Archive
+    if config.WARC.Enabled {
+        archive, err = warc.NewArchive(&warc.Config{
+            Dir:         config.WARC.Dir,
+            Prefix:      config.WARC.Prefix,
+            MaxFileSize: config.WARC.MaxFileSize,
+            Software:    config.WARC.Software,
+        })
+        if err != nil {
+            return nil, fmt.Errorf("failed to initialize WARC archive: %v", err)
+        }
+    }
+
     return &MultiStorage{
         postgres: postgres,
         mongodb:  mongodb,
         redis:    redisStorage,
+        archive:  archive,
     }, nil
 }
 
@@ -146,6 +208,33 @@ func (s *PostgreSQLStorage) createTables() error {
             scheduled_at TIMESTAMP,
             status VARCHAR(50) DEFAULT 'pending'
         )`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS depth INTEGER DEFAULT 0`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS attempts INTEGER DEFAULT 0`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS worker_id VARCHAR(255)`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS leased_by VARCHAR(255)`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS lease_id VARCHAR(255)`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS last_error TEXT`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS parent_url TEXT`,
+        `ALTER TABLE crawl_tasks ADD COLUMN IF NOT EXISTS host VARCHAR(255)`,
+        `ALTER TABLE crawl_sessions ADD COLUMN IF NOT EXISTS schemas JSONB`,
+        `CREATE TABLE IF NOT EXISTS session_rates (
+            session_id VARCHAR(255) REFERENCES crawl_sessions(id) ON DELETE CASCADE,
+            node_id VARCHAR(255),
+            pages_per_minute INTEGER NOT NULL,
+            updated_at TIMESTAMP NOT NULL,
+            PRIMARY KEY (session_id, node_id)
+        )`,
+        `CREATE TABLE IF NOT EXISTS url_validators (
+            url TEXT PRIMARY KEY,
+            etag TEXT,
+            last_modified TEXT,
+            task_id VARCHAR(255),
+            session_id VARCHAR(255),
+            content_length BIGINT DEFAULT 0,
+            fetched_at TIMESTAMP
+        )`,
         `CREATE TABLE IF NOT EXISTS proxy_info (
             id VARCHAR(255) PRIMARY KEY,
             host VARCHAR(255) NOT NULL,
@@ -170,6 +259,8 @@ func (s *PostgreSQLStorage) createTables() error {
         )`,
         `CREATE INDEX IF NOT EXISTS idx_crawl_tasks_status ON crawl_tasks(status)`,
         `CREATE INDEX IF NOT EXISTS idx_crawl_tasks_session ON crawl_tasks(session_id)`,
+        `CREATE INDEX IF NOT EXISTS idx_crawl_tasks_frontier ON crawl_tasks(session_id, priority DESC, created_at) WHERE status = 'pending'`,
+        `CREATE INDEX IF NOT EXISTS idx_crawl_tasks_lease ON crawl_tasks(lease_expires_at) WHERE status IN ('leased', 'running')`,
         `CREATE INDEX IF NOT EXISTS idx_detection_events_timestamp ON detection_events(timestamp)`,
     }
 
@@ -190,10 +281,30 @@ func NewMongoDBStorage(config MongoDBConfig) (*MongoDBStorage, error) {
 
     database := client.Database(config.Database)
 
-    return &MongoDBStorage{
+    storage := &MongoDBStorage{
         client:   client,
         database: database,
-    }, nil
+    }
+    if err := storage.createIndexes(); err != nil {
+        return nil, fmt.Errorf("failed to create indexes: %v", err)
+    }
+
+    return storage, nil
+}
+
+func (m *MongoDBStorage) createIndexes() error {
+    collection := m.database.Collection("crawl_results")
+
+    indexes := []mongo.IndexModel{
+        {Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "start_time", Value: -1}}},
+        {Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "depth", Value: 1}}},
+        {Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "parent_url", Value: 1}}},
+        {Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "attempt", Value: 1}}},
+        {Keys: bson.D{{Key: "url", Value: 1}, {Key: "start_time", Value: -1}}},
+    }
+
+    _, err := collection.Indexes().CreateMany(context.Background(), indexes)
+    return err
 }
 
 func NewRedisStorage(config RedisConfig) (*RedisStorage, error) {
@@ -220,35 +331,267 @@ func (m *MultiStorage) StoreCrawlResult(result *models.CrawlResult) error {
     return m.redis.CacheCrawlResult(result)
 }
 
-func (m *MultiStorage) GetPendingTasks(limit int) ([]*models.CrawlTask, error) {
-    return m.postgres.GetPendingTasks(limit)
+func (m *MultiStorage) LeaseTasks(owner string, limit, perHost int, skipHosts []string, leaseFor time.Duration) ([]*models.CrawlTask, error) {
+    return m.postgres.LeaseTasks(owner, limit, perHost, skipHosts, leaseFor)
+}
+
+func (m *MultiStorage) StartTask(taskID, leaseID, workerID string, leaseFor time.Duration) (bool, error) {
+    return m.postgres.StartTask(taskID, leaseID, workerID, leaseFor)
+}
+
+func (m *MultiStorage) CompleteTask(taskID, status, message string) error {
+    return m.postgres.CompleteTask(taskID, status, message)
+}
+
+func (m *MultiStorage) ReleaseTasks(taskIDs []string) error {
+    return m.postgres.ReleaseTasks(taskIDs)
+}
+
+func (m *MultiStorage) RetryTask(taskID string, at time.Time, message string) error {
+    return m.postgres.RetryTask(taskID, at, message)
+}
+
+func (m *MultiStorage) GetDeadLetterTasks(sessionID string, limit int) ([]*models.CrawlTask, error) {
+    return m.postgres.GetDeadLetterTasks(sessionID, limit)
+}
+
+func (m *MultiStorage) ReclaimExpiredLeases() (int64, error) {
+    return m.postgres.ReclaimExpiredLeases()
+}
+
+func (m *MultiStorage) ReleaseNodeTasks(nodeID string) (int64, error) {
+    return m.postgres.ReleaseNodeTasks(nodeID)
+}
+
+func (m *MultiStorage) CreateTasks(tasks []*models.CrawlTask) ([]*models.CrawlTask, error) {
+    return m.postgres.CreateTasks(tasks)
 }
 
 func (m *MultiStorage) CreateCrawlSession(session *models.CrawlSession) error {
     return m.postgres.CreateCrawlSession(session)
 }
 
-func (m *MultiStorage) UpdateSessionStats(sessionID string, stats *models.SessionStats) error {
-    return m.postgres.UpdateSessionStats(sessionID, stats)
+func (m *MultiStorage) AddSessionStats(sessionID, nodeID string, delta *models.SessionStats, rate int) (*models.SessionStats, error) {
+    return m.postgres.AddSessionStats(sessionID, nodeID, delta, rate)
 }
 
 func (m *MultiStorage) GetCrawlSessions() ([]*models.CrawlSession, error) {
     return m.postgres.GetCrawlSessions()
 }
 
+func (m *MultiStorage) GetCrawlSession(sessionID string) (*models.CrawlSession, error) {
+    return m.postgres.GetCrawlSession(sessionID)
+}
+
+func (m *MultiStorage) UpdateSessionStatus(sessionID, status string, startedAt, completedAt *time.Time) error {
+    return m.postgres.UpdateSessionStatus(sessionID, status, startedAt, completedAt)
+}
+
+func (m *MultiStorage) CancelSessionTasks(sessionID string) (int64, error) {
+    return m.postgres.CancelSessionTasks(sessionID)
+}
+
+func (m *MultiStorage) ReleaseSessionLeases(sessionID string) (int64, error) {
+    return m.postgres.ReleaseSessionLeases(sessionID)
+}
+
+func (m *MultiStorage) CompleteFinishedSessions() ([]string, error) {
+    return m.postgres.CompleteFinishedSessions()
+}
+
 func (m *MultiStorage) GetCrawlResults(sessionID string, limit int) ([]*models.CrawlResult, error) {
     return m.mongodb.GetCrawlResults(sessionID, limit)
 }
 
-func (s *PostgreSQLStorage) GetPendingTasks(limit int) ([]*models.CrawlTask, error) {
-    query := `SELECT id, session_id, url, method, headers, priority, max_depth, 
-              created_at, scheduled_at, status 
-              FROM crawl_tasks 
-              WHERE status = 'pending' 
-              ORDER BY priority DESC, created_at ASC 
-              LIMIT $1`
+func (m *MultiStorage) GetCrawlResult(taskID string) (*models.CrawlResult, error) {
+    return m.mongodb.GetCrawlResult(taskID)
+}
+
+func (m *MultiStorage) GetLatestSnapshot(url, excludeSessionID string) (*models.CrawlResult, error) {
+    return m.mongodb.GetLatestSnapshot(url, excludeSessionID)
+}
+
+func (m *MultiStorage) GetURLValidators(url string) (*models.URLValidators, error) {
+    return m.postgres.GetURLValidators(url)
+}
+
+func (m *MultiStorage) SaveURLValidators(validators *models.URLValidators) error {
+    return m.postgres.SaveURLValidators(validators)
+}
+
+func (m *MultiStorage) StoreBlob(name, contentType string, data []byte) (string, error) {
+    return m.mongodb.StoreBlob(name, contentType, data)
+}
+
+// ArchiveExchange records a fetch in the session's WARC files. It is a
+// no-op when archiving is disabled.
+func (m *MultiStorage) ArchiveExchange(sessionID string, exchange *warc.Exchange) error {
+    if m.archive == nil {
+        return nil
+    }
+    return m.archive.Write(sessionID, exchange)
+}
+
+func (m *MultiStorage) SessionArchives(sessionID string) ([]warc.File, error) {
+    if m.archive == nil {
+        return nil, nil
+    }
+    return m.archive.Files(sessionID)
+}
+
+func (m *MultiStorage) CloseSessionArchive(sessionID string) error {
+    if m.archive == nil {
+        return nil
+    }
+    return m.archive.CloseSession(sessionID)
+}
+
+func (m *MultiStorage) StreamCrawlResults(ctx context.Context, sessionID, after string, limit int, fn func(cursor string, result *models.CrawlResult) error) (string, error) {
+    return m.mongodb.StreamCrawlResults(ctx, sessionID, after, limit, fn)
+}
+
+func (m *MultiStorage) GetRobotsTxt(host string) ([]byte, time.Time, bool, error) {
+    return m.redis.GetRobotsTxt(host)
+}
+
+func (m *MultiStorage) CacheRobotsTxt(host string, body []byte, expires time.Time) error {
+    return m.redis.CacheRobotsTxt(host, body, expires)
+}
+
+func (m *MultiStorage) AddSeenURL(sessionID, url string) (bool, error) {
+    return m.redis.AddSeenURL(sessionID, url)
+}
+
+func (m *MultiStorage) RemoveSeenURL(sessionID, url string) (bool, error) {
+    return m.redis.RemoveSeenURL(sessionID, url)
+}
+
+func (m *MultiStorage) SetSeenBits(sessionID string, offsets []uint64) (bool, error) {
+    return m.redis.SetSeenBits(sessionID, offsets)
+}
+
+const taskColumns = `id, session_id, url, method, headers, priority, max_depth, depth,
+              created_at, scheduled_at, status, attempts, worker_id, leased_by,
+              lease_expires_at, last_error, parent_url, host, lease_id`
+
+// LeaseTasks atomically claims up to limit due pending tasks for owner.
+// Every running session contributes its best tasks, and the claim takes
+// them round-robin across hosts, at most perHost per host and none of
+// skipHosts. Rows locked by a concurrent scheduler are skipped rather than
+// waited on, and the status is checked again on the rows locked, which a
+// concurrent scheduler may have leased since the candidates were picked.
+// So several schedulers never lease the same task. Each claim gets a new
+// lease ID, which StartTask checks.
+func (s *PostgreSQLStorage) LeaseTasks(owner string, limit, perHost int, skipHosts []string, leaseFor time.Duration) ([]*models.CrawlTask, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'leased', leased_by = $1, worker_id = NULL, lease_id = $6,
+                  lease_expires_at = NOW() + $3 * INTERVAL '1 second'
+              WHERE id IN (
+                  SELECT id FROM crawl_tasks
+                  WHERE id IN (
+                      SELECT id FROM (
+                          SELECT t.id, t.priority, t.created_at,
+                                 ROW_NUMBER() OVER (PARTITION BY t.host ORDER BY t.priority DESC, t.created_at ASC) AS host_rank
+                          FROM crawl_sessions s
+                          CROSS JOIN LATERAL (
+                              SELECT id, COALESCE(host, '') AS host, priority, created_at
+                              FROM crawl_tasks
+                              WHERE session_id = s.id AND status = 'pending'
+                                AND (scheduled_at IS NULL OR scheduled_at <= NOW())
+                                AND NOT (COALESCE(host, '') = ANY($5))
+                              ORDER BY priority DESC, created_at ASC
+                              LIMIT $2
+                          ) t
+                          WHERE s.status = 'running'
+                      ) candidates
+                      WHERE host_rank <= $4
+                      ORDER BY host_rank, priority DESC, created_at ASC
+                      LIMIT $2
+                  )
+                  AND status = 'pending'
+                  FOR UPDATE SKIP LOCKED
+              )
+              AND status = 'pending'
+              RETURNING ` + taskColumns
+
+    if skipHosts == nil {
+        skipHosts = []string{}
+    }
+    rows, err := s.db.Query(query, owner, limit, leaseFor.Seconds(), perHost, pq.Array(skipHosts), uuid.New().String())
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var tasks []*models.CrawlTask
+    for rows.Next() {
+        task, err := scanCrawlTask(rows)
+        if err != nil {
+            return nil, err
+        }
+        tasks = append(tasks, task)
+    }
+
+    return tasks, rows.Err()
+}
+
+// StartTask moves a leased task to running on behalf of a worker, renewing
+// its lease and counting the attempt. It returns false if the task no
+// longer holds the lease leaseID, e.g. because the lease expired and was
+// reclaimed, even if the task has been leased again since.
+func (s *PostgreSQLStorage) StartTask(taskID, leaseID, workerID string, leaseFor time.Duration) (bool, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'running', worker_id = $3, attempts = attempts + 1,
+                  lease_expires_at = NOW() + $4 * INTERVAL '1 second'
+              WHERE id = $1 AND status = 'leased' AND lease_id = $2`
+
+    res, err := s.db.Exec(query, taskID, leaseID, workerID, leaseFor.Seconds())
+    if err != nil {
+        return false, err
+    }
+    n, err := res.RowsAffected()
+    return n == 1, err
+}
+
+// CompleteTask records a terminal status (done, failed or skipped).
+func (s *PostgreSQLStorage) CompleteTask(taskID, status, message string) error {
+    query := `UPDATE crawl_tasks
+              SET status = $2, last_error = NULLIF($3, ''), lease_expires_at = NULL, finished_at = NOW()
+              WHERE id = $1 AND status IN ('leased', 'running')`
+    _, err := s.db.Exec(query, taskID, status, message)
+    return err
+}
+
+// ReleaseTasks returns leased tasks to the pending state without counting
+// an attempt.
+func (s *PostgreSQLStorage) ReleaseTasks(taskIDs []string) error {
+    query := `UPDATE crawl_tasks
+              SET status = 'pending', leased_by = NULL, worker_id = NULL, lease_expires_at = NULL
+              WHERE id = ANY($1) AND status IN ('leased', 'running')`
+    _, err := s.db.Exec(query, pq.Array(taskIDs))
+    return err
+}
+
+// RetryTask puts a failed task back in the frontier, due at the given time.
+func (s *PostgreSQLStorage) RetryTask(taskID string, at time.Time, message string) error {
+    query := `UPDATE crawl_tasks
+              SET status = 'pending', scheduled_at = $2, last_error = $3,
+                  leased_by = NULL, worker_id = NULL, lease_expires_at = NULL
+              WHERE id = $1 AND status = 'running'`
+    _, err := s.db.Exec(query, taskID, at, message)
+    return err
+}
+
+// GetDeadLetterTasks lists tasks that exhausted their retries, newest
+// first, optionally restricted to one session.
+func (s *PostgreSQLStorage) GetDeadLetterTasks(sessionID string, limit int) ([]*models.CrawlTask, error) {
+    query := `SELECT ` + taskColumns + `
+              FROM crawl_tasks
+              WHERE status = 'dead' AND ($1 = '' OR session_id = $1)
+              ORDER BY finished_at DESC
+              LIMIT $2`
 
-    rows, err := s.db.Query(query, limit)
+    rows, err := s.db.Query(query, sessionID, limit)
     if err != nil {
         return nil, err
     }
@@ -256,50 +599,271 @@ func (s *PostgreSQLStorage) GetPendingTasks(limit int) ([]*models.CrawlTask, err
 
     var tasks []*models.CrawlTask
     for rows.Next() {
-        task := &models.CrawlTask{}
-        var headersJSON []byte
+        task, err := scanCrawlTask(rows)
+        if err != nil {
+            return nil, err
+        }
+        tasks = append(tasks, task)
+    }
+
+    return tasks, rows.Err()
+}
 
-        err := rows.Scan(&task.ID, &task.SessionID, &task.URL, &task.Method,
-            &headersJSON, &task.Priority, &task.MaxDepth, &task.CreatedAt,
-            &task.ScheduledAt, &task.Status)
+func (s *PostgreSQLStorage) ReclaimExpiredLeases() (int64, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'pending', leased_by = NULL, worker_id = NULL, lease_expires_at = NULL
+              WHERE status IN ('leased', 'running') AND lease_expires_at < NOW()`
+    res, err := s.db.Exec(query)
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
+
+// ReleaseNodeTasks returns the tasks a dead node held to the pending state:
+// those it leased and had not handed to a worker yet, and those its
+// workers, whose IDs are prefixed with the node ID, were running.
+func (s *PostgreSQLStorage) ReleaseNodeTasks(nodeID string) (int64, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'pending', leased_by = NULL, worker_id = NULL, lease_expires_at = NULL
+              WHERE (status = 'leased' AND leased_by = $1)
+                 OR (status = 'running' AND split_part(worker_id, '/', 1) = $1)`
+    res, err := s.db.Exec(query, nodeID)
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
+
+func scanCrawlTask(row rowScanner) (*models.CrawlTask, error) {
+    task := &models.CrawlTask{}
+    var headersJSON []byte
+    var scheduledAt *time.Time
+    var workerID, leasedBy, lastError, parentURL, host, leaseID sql.NullString
+
+    err := row.Scan(&task.ID, &task.SessionID, &task.URL, &task.Method,
+        &headersJSON, &task.Priority, &task.MaxDepth, &task.Depth, &task.CreatedAt,
+        &scheduledAt, &task.Status, &task.Attempts, &workerID, &leasedBy,
+        &task.LeaseExpiresAt, &lastError, &parentURL, &host, &leaseID)
+    if err != nil {
+        return nil, err
+    }
+
+    if scheduledAt != nil {
+        task.ScheduledAt = *scheduledAt
+    }
+    task.WorkerID = workerID.String
+    task.LeasedBy = leasedBy.String
+    task.LastError = lastError.String
+    task.ParentURL = parentURL.String
+    task.Host = host.String
+    task.LeaseID = leaseID.String
+
+    if len(headersJSON) > 0 {
+        json.Unmarshal(headersJSON, &task.Headers)
+    }
+
+    return task, nil
+}
+
+// CreateTasks inserts tasks in a single transaction so that either all of
+// them reach the frontier or none do, and returns those written. Tasks of
+// sessions that have been stopped or completed are discarded, and so are
+// tasks beyond a session's MaxPages budget.
+func (s *PostgreSQLStorage) CreateTasks(tasks []*models.CrawlTask) ([]*models.CrawlTask, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    sessionIDs := make([]string, 0, 1)
+    for _, task := range tasks {
+        if len(sessionIDs) == 0 || sessionIDs[len(sessionIDs)-1] != task.SessionID {
+            sessionIDs = append(sessionIDs, task.SessionID)
+        }
+    }
+
+    budgets, err := querySessionCounts(tx, `SELECT id, COALESCE((rules->>'max_pages')::int, 0)
+              FROM crawl_sessions WHERE id = ANY($1)`, sessionIDs)
+    if err != nil {
+        return nil, err
+    }
+    var budgeted, unbudgeted []string
+    for id, budget := range budgets {
+        if budget > 0 {
+            budgeted = append(budgeted, id)
+        } else {
+            unbudgeted = append(unbudgeted, id)
+        }
+    }
+
+    // Lock the sessions so a concurrent stop cancels these tasks too.
+    // Sessions with a budget are locked exclusively, so that concurrent
+    // inserts count each other's tasks.
+    open := make(map[string]bool)
+    for _, lock := range []struct {
+        ids  []string
+        mode string
+    }{{budgeted, "FOR UPDATE"}, {unbudgeted, "FOR SHARE"}} {
+        if len(lock.ids) == 0 {
+            continue
+        }
+        locked, err := querySessionCounts(tx, `SELECT id, 1 FROM crawl_sessions
+              WHERE id = ANY($1) AND status IN ('pending', 'running', 'paused')
+              ORDER BY id `+lock.mode, lock.ids)
         if err != nil {
             return nil, err
         }
+        for id := range locked {
+            open[id] = true
+        }
+    }
 
-        if len(headersJSON) > 0 {
-            json.Unmarshal(headersJSON, &task.Headers)
+    remaining := make(map[string]int)
+    if len(budgeted) > 0 {
+        counts, err := querySessionCounts(tx, `SELECT session_id, COUNT(*) FROM crawl_tasks
+              WHERE session_id = ANY($1) GROUP BY session_id`, budgeted)
+        if err != nil {
+            return nil, err
+        }
+        for _, id := range budgeted {
+            remaining[id] = budgets[id] - counts[id]
         }
+    }
 
-        tasks = append(tasks, task)
+    stmt, err := tx.Prepare(`INSERT INTO crawl_tasks (id, session_id, url, method, headers, priority,
+              max_depth, depth, created_at, scheduled_at, status, parent_url, host)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''), NULLIF($13, ''))
+              ON CONFLICT (id) DO NOTHING`)
+    if err != nil {
+        return nil, err
     }
+    defer stmt.Close()
 
-    return tasks, nil
+    var created []*models.CrawlTask
+    for _, task := range tasks {
+        if !open[task.SessionID] {
+            continue
+        }
+        left, limited := remaining[task.SessionID]
+        if limited && left <= 0 {
+            continue
+        }
+        headersJSON, _ := json.Marshal(task.Headers)
+        res, err := stmt.Exec(task.ID, task.SessionID, task.URL, task.Method, headersJSON,
+            task.Priority, task.MaxDepth, task.Depth, task.CreatedAt, task.ScheduledAt, task.Status, task.ParentURL, task.Host)
+        if err != nil {
+            return nil, fmt.Errorf("failed to insert task %s: %v", task.ID, err)
+        }
+        if n, _ := res.RowsAffected(); n == 0 {
+            continue
+        }
+        if limited {
+            remaining[task.SessionID] = left - 1
+        }
+        created = append(created, task)
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return created, nil
+}
+
+// querySessionCounts runs a query yielding session IDs and a count each.
+func querySessionCounts(tx *sql.Tx, query string, sessionIDs []string) (map[string]int, error) {
+    rows, err := tx.Query(query, pq.Array(sessionIDs))
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    counts := make(map[string]int)
+    for rows.Next() {
+        var id string
+        var count int
+        if err := rows.Scan(&id, &count); err != nil {
+            return nil, err
+        }
+        counts[id] = count
+    }
+    return counts, rows.Err()
 }
 
 func (s *PostgreSQLStorage) CreateCrawlSession(session *models.CrawlSession) error {
     rulesJSON, _ := json.Marshal(session.Rules)
     statsJSON, _ := json.Marshal(session.Stats)
 
-    query := `INSERT INTO crawl_sessions (id, name, description, start_urls, rules, status, created_at, stats)
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+    schemasJSON, _ := json.Marshal(session.Schemas)
+
+    query := `INSERT INTO crawl_sessions (id, name, description, start_urls, rules, status, created_at, stats, schemas)
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
 
     _, err := s.db.Exec(query, session.ID, session.Name, session.Description,
         fmt.Sprintf("{%s}", join(session.StartURLs, ",")),
-        rulesJSON, session.Status, session.CreatedAt, statsJSON)
+        rulesJSON, session.Status, session.CreatedAt, statsJSON, schemasJSON)
 
     return err
 }
 
-func (s *PostgreSQLStorage) UpdateSessionStats(sessionID string, stats *models.SessionStats) error {
-    statsJSON, _ := json.Marshal(stats)
-    query := `UPDATE crawl_sessions SET stats = $1 WHERE id = $2`
-    _, err := s.db.Exec(query, statsJSON, sessionID)
-    return err
+// sessionStatsColumn reads a session's stats with the rate summed over
+// what its nodes reported within the last minute, the window rates are
+// counted over. A node that stopped reporting, e.g. because it crashed,
+// drops out once that window passed.
+const sessionStatsColumn = `COALESCE(stats, '{}'::jsonb) || jsonb_build_object('pages_per_minute',
+                  (SELECT COALESCE(SUM(r.pages_per_minute), 0) FROM session_rates r
+                   WHERE r.session_id = crawl_sessions.id AND r.updated_at > NOW() - INTERVAL '1 minute'))`
+
+// AddSessionStats adds delta to the session's counters, records rate as
+// the node's current pages per minute, and returns the new totals. Every
+// process flushes only what it counted since its last flush, so flushes
+// from several processes add up instead of overwriting each other; the
+// rate is kept per node and summed on read. Sessions that ended have no
+// pending tasks left.
+func (s *PostgreSQLStorage) AddSessionStats(sessionID, nodeID string, delta *models.SessionStats, rate int) (*models.SessionStats, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return nil, err
+    }
+    defer tx.Rollback()
+
+    _, err = tx.Exec(`INSERT INTO session_rates (session_id, node_id, pages_per_minute, updated_at)
+                      VALUES ($1, $2, $3, NOW())
+                      ON CONFLICT (session_id, node_id)
+                      DO UPDATE SET pages_per_minute = EXCLUDED.pages_per_minute, updated_at = EXCLUDED.updated_at`,
+        sessionID, nodeID, rate)
+    if err != nil {
+        return nil, err
+    }
+
+    deltaJSON, _ := json.Marshal(delta)
+    query := `UPDATE crawl_sessions SET stats = (
+                  SELECT jsonb_object_agg(key, CASE
+                      WHEN key = 'pending_tasks' AND status IN ('stopped', 'completed') THEN 0
+                      ELSE COALESCE((stats->>key)::bigint, 0) + value::bigint END)
+                  FROM jsonb_each_text($1::jsonb)
+                  WHERE key <> 'pages_per_minute')
+              WHERE id = $2
+              RETURNING ` + sessionStatsColumn
+
+    var statsJSON []byte
+    if err := tx.QueryRow(query, deltaJSON, sessionID).Scan(&statsJSON); err != nil {
+        return nil, err
+    }
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    totals := &models.SessionStats{}
+    if err := json.Unmarshal(statsJSON, totals); err != nil {
+        return nil, fmt.Errorf("invalid stats of session %s: %v", sessionID, err)
+    }
+    return totals, nil
 }
 
 func (s *PostgreSQLStorage) GetCrawlSessions() ([]*models.CrawlSession, error) {
     query := `SELECT id, name, description, start_urls, rules, status, 
-              created_at, started_at, completed_at, stats 
+              created_at, started_at, completed_at, ` + sessionStatsColumn + `, schemas
               FROM crawl_sessions ORDER BY created_at DESC`
 
     rows, err := s.db.Query(query)
@@ -310,31 +874,153 @@ func (s *PostgreSQLStorage) GetCrawlSessions() ([]*models.CrawlSession, error) {
 
     var sessions []*models.CrawlSession
     for rows.Next() {
-        session := &models.CrawlSession{}
-        var rulesJSON, statsJSON []byte
-        var startURLs string
-
-        err := rows.Scan(&session.ID, &session.Name, &session.Description,
-            &startURLs, &rulesJSON, &session.Status, &session.CreatedAt,
-            &session.StartedAt, &session.CompletedAt, &statsJSON)
+        session, err := scanCrawlSession(rows)
         if err != nil {
             return nil, err
         }
+        sessions = append(sessions, session)
+    }
 
-        // Parse start URLs (simplified)
-        session.StartURLs = []string{startURLs}
+    return sessions, nil
+}
 
-        if len(rulesJSON) > 0 {
-            json.Unmarshal(rulesJSON, &session.Rules)
-        }
-        if len(statsJSON) > 0 {
-            json.Unmarshal(statsJSON, &session.Stats)
+func (s *PostgreSQLStorage) GetCrawlSession(sessionID string) (*models.CrawlSession, error) {
+    query := `SELECT id, name, description, start_urls, rules, status, 
+              created_at, started_at, completed_at, ` + sessionStatsColumn + `, schemas
+              FROM crawl_sessions WHERE id = $1`
+
+    return scanCrawlSession(s.db.QueryRow(query, sessionID))
+}
+
+// GetURLValidators returns the validators stored for a canonical URL, or
+// nil when it was never fetched with any.
+func (s *PostgreSQLStorage) GetURLValidators(url string) (*models.URLValidators, error) {
+    query := `SELECT url, etag, last_modified, task_id, session_id, content_length, fetched_at
+              FROM url_validators WHERE url = $1`
+
+    var v models.URLValidators
+    var etag, lastModified, taskID, sessionID sql.NullString
+    var fetchedAt sql.NullTime
+    err := s.db.QueryRow(query, url).Scan(&v.URL, &etag, &lastModified, &taskID, &sessionID, &v.ContentLength, &fetchedAt)
+    if err == sql.ErrNoRows {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    v.ETag, v.LastModified = etag.String, lastModified.String
+    v.TaskID, v.SessionID = taskID.String, sessionID.String
+    v.FetchedAt = fetchedAt.Time
+    return &v, nil
+}
+
+// SaveURLValidators replaces the validators of a URL.
+func (s *PostgreSQLStorage) SaveURLValidators(v *models.URLValidators) error {
+    query := `INSERT INTO url_validators (url, etag, last_modified, task_id, session_id, content_length, fetched_at)
+              VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7)
+              ON CONFLICT (url) DO UPDATE SET
+                  etag = EXCLUDED.etag, last_modified = EXCLUDED.last_modified,
+                  task_id = EXCLUDED.task_id, session_id = EXCLUDED.session_id,
+                  content_length = EXCLUDED.content_length, fetched_at = EXCLUDED.fetched_at`
+    _, err := s.db.Exec(query, v.URL, v.ETag, v.LastModified, v.TaskID, v.SessionID, v.ContentLength, v.FetchedAt)
+    return err
+}
+
+// UpdateSessionStatus sets a session's status. Nil timestamps leave the
+// stored values untouched.
+func (s *PostgreSQLStorage) UpdateSessionStatus(sessionID, status string, startedAt, completedAt *time.Time) error {
+    query := `UPDATE crawl_sessions
+              SET status = $2, started_at = COALESCE($3, started_at), completed_at = COALESCE($4, completed_at)
+              WHERE id = $1`
+    _, err := s.db.Exec(query, sessionID, status, startedAt, completedAt)
+    return err
+}
+
+// CancelSessionTasks cancels every task of the session that has not
+// reached a terminal state, including leased and running ones.
+func (s *PostgreSQLStorage) CancelSessionTasks(sessionID string) (int64, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'cancelled', lease_expires_at = NULL, finished_at = NOW()
+              WHERE session_id = $1 AND status IN ('pending', 'leased', 'running')`
+    res, err := s.db.Exec(query, sessionID)
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
+
+// ReleaseSessionLeases returns the session's leased but not yet running
+// tasks to the pending state.
+func (s *PostgreSQLStorage) ReleaseSessionLeases(sessionID string) (int64, error) {
+    query := `UPDATE crawl_tasks
+              SET status = 'pending', leased_by = NULL, lease_expires_at = NULL
+              WHERE session_id = $1 AND status = 'leased'`
+    res, err := s.db.Exec(query, sessionID)
+    if err != nil {
+        return 0, err
+    }
+    return res.RowsAffected()
+}
+
+// CompleteFinishedSessions marks running sessions whose frontier is empty
+// as completed and returns their IDs.
+func (s *PostgreSQLStorage) CompleteFinishedSessions() ([]string, error) {
+    query := `UPDATE crawl_sessions SET status = 'completed', completed_at = NOW()
+              WHERE status = 'running' AND NOT EXISTS (
+                  SELECT 1 FROM crawl_tasks
+                  WHERE crawl_tasks.session_id = crawl_sessions.id
+                    AND crawl_tasks.status IN ('pending', 'leased', 'running')
+              )
+              RETURNING id`
+
+    rows, err := s.db.Query(query)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    var ids []string
+    for rows.Next() {
+        var id string
+        if err := rows.Scan(&id); err != nil {
+            return nil, err
         }
+        ids = append(ids, id)
+    }
 
-        sessions = append(sessions, session)
+    return ids, rows.Err()
+}
+
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanCrawlSession(row rowScanner) (*models.CrawlSession, error) {
+    session := &models.CrawlSession{}
+    var rulesJSON, statsJSON, schemasJSON []byte
+    var startURLs string
+
+    err := row.Scan(&session.ID, &session.Name, &session.Description,
+        &startURLs, &rulesJSON, &session.Status, &session.CreatedAt,
+        &session.StartedAt, &session.CompletedAt, &statsJSON, &schemasJSON)
+    if err != nil {
+        return nil, err
     }
 
-    return sessions, nil
+    // Parse start URLs (simplified)
+    session.StartURLs = []string{startURLs}
+
+    if len(rulesJSON) > 0 {
+        json.Unmarshal(rulesJSON, &session.Rules)
+    }
+    if len(statsJSON) > 0 {
+        json.Unmarshal(statsJSON, &session.Stats)
+    }
+    if len(schemasJSON) > 0 {
+        json.Unmarshal(schemasJSON, &session.Schemas)
+    }
+
+    return session, nil
 }
 
 func (m *MongoDBStorage) StoreCrawlResult(result *models.CrawlResult) error {
@@ -370,12 +1056,174 @@ func (m *MongoDBStorage) GetCrawlResults(sessionID string, limit int) ([]*models
     return results, nil
 }
 
+// GetCrawlResult returns the latest successful result of a task, or nil
+// when there is none.
+func (m *MongoDBStorage) GetCrawlResult(taskID string) (*models.CrawlResult, error) {
+    collection := m.database.Collection("crawl_results")
+
+    opts := options.FindOne().SetSort(bson.D{{Key: "attempt", Value: -1}})
+    var result models.CrawlResult
+    err := collection.FindOne(context.Background(), bson.M{"task_id": taskID, "success": true}, opts).Decode(&result)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// GetLatestSnapshot returns the most recent successful result for a URL
+// outside the given session, or nil when no other session fetched it.
+func (m *MongoDBStorage) GetLatestSnapshot(url, excludeSessionID string) (*models.CrawlResult, error) {
+    collection := m.database.Collection("crawl_results")
+
+    filter := bson.M{"url": url, "success": true, "session_id": bson.M{"$ne": excludeSessionID}}
+    opts := options.FindOne().SetSort(bson.D{{Key: "start_time", Value: -1}})
+    var result models.CrawlResult
+    err := collection.FindOne(context.Background(), filter, opts).Decode(&result)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &result, nil
+}
+
+// StoreBlob saves a binary body to GridFS and returns its file id.
+func (m *MongoDBStorage) StoreBlob(name, contentType string, data []byte) (string, error) {
+    bucket, err := gridfs.NewBucket(m.database, options.GridFSBucket().SetName("blobs"))
+    if err != nil {
+        return "", err
+    }
+
+    opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+    id, err := bucket.UploadFromStream(name, bytes.NewReader(data), opts)
+    if err != nil {
+        return "", err
+    }
+    return id.Hex(), nil
+}
+
+// StreamCrawlResults calls fn for each of a session's results in insertion
+// order, starting after the cursor after, and returns the cursor of the
+// last result visited. A limit of 0 streams every remaining result.
+func (m *MongoDBStorage) StreamCrawlResults(ctx context.Context, sessionID, after string, limit int, fn func(cursor string, result *models.CrawlResult) error) (string, error) {
+    collection := m.database.Collection("crawl_results")
+
+    filter := bson.M{"session_id": sessionID}
+    if after != "" {
+        id, err := primitive.ObjectIDFromHex(after)
+        if err != nil {
+            return "", fmt.Errorf("%w %q", ErrInvalidCursor, after)
+        }
+        filter["_id"] = bson.M{"$gt": id}
+    }
+
+    opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetBatchSize(500)
+    if limit > 0 {
+        opts.SetLimit(int64(limit))
+    }
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return "", err
+    }
+    defer cursor.Close(ctx)
+
+    last := after
+    for cursor.Next(ctx) {
+        var result models.CrawlResult
+        if err := cursor.Decode(&result); err != nil {
+            return last, err
+        }
+        id, ok := cursor.Current.Lookup("_id").ObjectIDOK()
+        if !ok {
+            return last, fmt.Errorf("result %s has no object id", result.TaskID)
+        }
+        if err := fn(id.Hex(), &result); err != nil {
+            return last, err
+        }
+        last = id.Hex()
+    }
+
+    return last, cursor.Err()
+}
+
 func (r *RedisStorage) CacheCrawlResult(result *models.CrawlResult) error {
     key := fmt.Sprintf("result:%s", result.TaskID)
     data, _ := json.Marshal(result)
     return r.client.Set(context.Background(), key, data, time.Hour).Err()
 }
 
+// GetRobotsTxt returns a cached robots.txt body and when it expires.
+func (r *RedisStorage) GetRobotsTxt(host string) ([]byte, time.Time, bool, error) {
+    fields, err := r.client.HGetAll(context.Background(), "robots:"+host).Result()
+    if err != nil {
+        return nil, time.Time{}, false, err
+    }
+    body, found := fields["body"]
+    if !found {
+        return nil, time.Time{}, false, nil
+    }
+    expires, err := strconv.ParseInt(fields["expires"], 10, 64)
+    if err != nil {
+        return nil, time.Time{}, false, fmt.Errorf("invalid robots.txt expiry: %v", err)
+    }
+    return []byte(body), time.UnixMilli(expires), true, nil
+}
+
+// CacheRobotsTxt stores a robots.txt body with its expiry, so that other
+// processes keep it only for the remaining time.
+func (r *RedisStorage) CacheRobotsTxt(host string, body []byte, expires time.Time) error {
+    ctx := context.Background()
+    key := "robots:" + host
+    _, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+        pipe.Del(ctx, key)
+        pipe.HSet(ctx, key, "body", body, "expires", expires.UnixMilli())
+        pipe.PExpireAt(ctx, key, expires)
+        return nil
+    })
+    return err
+}
+
+func (r *RedisStorage) AddSeenURL(sessionID, url string) (bool, error) {
+    added, err := r.client.SAdd(context.Background(), "seen:"+sessionID, url).Result()
+    if err != nil {
+        return false, err
+    }
+    return added == 1, nil
+}
+
+func (r *RedisStorage) RemoveSeenURL(sessionID, url string) (bool, error) {
+    removed, err := r.client.SRem(context.Background(), "seen:"+sessionID, url).Result()
+    if err != nil {
+        return false, err
+    }
+    return removed == 1, nil
+}
+
+func (r *RedisStorage) SetSeenBits(sessionID string, offsets []uint64) (bool, error) {
+    ctx := context.Background()
+    key := "seenbloom:" + sessionID
+
+    pipe := r.client.Pipeline()
+    cmds := make([]*redis.IntCmd, len(offsets))
+    for i, offset := range offsets {
+        cmds[i] = pipe.SetBit(ctx, key, int64(offset), 1)
+    }
+    if _, err := pipe.Exec(ctx); err != nil {
+        return false, err
+    }
+
+    for _, cmd := range cmds {
+        if cmd.Val() == 0 {
+            return true, nil
+        }
+    }
+    return false, nil
+}
+
 func (m *MultiStorage) Close() error {
     if m.postgres != nil {
         m.postgres.db.Close()
@@ -386,6 +1234,9 @@ func (m *MultiStorage) Close() error {
     if m.redis != nil {
         m.redis.client.Close()
     }
+    if m.archive != nil {
+        m.archive.Close()
+    }
     return nil
 }
 
@@ -0,0 +1,403 @@
+// pkg/storage/storage_test.go
+package storage
+
+import (
+    "database/sql"
+    "os"
+    "reflect"
+    "testing"
+    "time"
+
+    "crawler666/internal/models"
+
+    "github.com/google/uuid"
+    "github.com/lib/pq"
+)
+
+// testPostgres connects to the database named by the lib/pq DSN in
+// CRAWLER666_TEST_POSTGRES and skips the test without one. LeaseTasks
+// claims tasks of every running session, so the database must be one
+// kept for tests.
+func testPostgres(t *testing.T) *PostgreSQLStorage {
+    t.Helper()
+    dsn := os.Getenv("CRAWLER666_TEST_POSTGRES")
+    if dsn == "" {
+        t.Skip("CRAWLER666_TEST_POSTGRES is not set")
+    }
+
+    db, err := sql.Open("postgres", dsn)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { db.Close() })
+    if err := db.Ping(); err != nil {
+        t.Fatalf("failed to connect to PostgreSQL: %v", err)
+    }
+
+    s := &PostgreSQLStorage{db: db}
+    if err := s.createTables(); err != nil {
+        t.Fatal(err)
+    }
+    return s
+}
+
+// testTasks creates a running session with a task per host and removes
+// both once the test is done.
+func testTasks(t *testing.T, s *PostgreSQLStorage, hosts ...string) []*models.CrawlTask {
+    t.Helper()
+    sessionID := "test-" + uuid.New().String()
+    err := s.CreateCrawlSession(&models.CrawlSession{ID: sessionID, Name: t.Name(), Status: "running", CreatedAt: time.Now()})
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() {
+        s.db.Exec(`DELETE FROM crawl_tasks WHERE session_id = $1`, sessionID)
+        s.db.Exec(`DELETE FROM crawl_sessions WHERE id = $1`, sessionID)
+    })
+
+    var tasks []*models.CrawlTask
+    for i, host := range hosts {
+        tasks = append(tasks, &models.CrawlTask{
+            ID:        uuid.New().String(),
+            SessionID: sessionID,
+            URL:       "https://" + host + "/" + string(rune('a'+i)),
+            Host:      host,
+            Method:    "GET",
+            Priority:  5,
+            Status:    "pending",
+            CreatedAt: time.Now().Add(time.Duration(i) * time.Millisecond),
+        })
+    }
+    created, err := s.CreateTasks(tasks)
+    if err != nil || len(created) != len(tasks) {
+        t.Fatalf("CreateTasks created %d of %d tasks: %v", len(created), len(tasks), err)
+    }
+    return tasks
+}
+
+// lease claims tasks for owner and keeps those of the given tasks' session.
+func lease(t *testing.T, s *PostgreSQLStorage, owner string, perHost int, skipHosts []string, of []*models.CrawlTask) map[string]*models.CrawlTask {
+    t.Helper()
+    leased, err := s.LeaseTasks(owner, 1000, perHost, skipHosts, time.Minute)
+    if err != nil {
+        t.Fatalf("LeaseTasks: %v", err)
+    }
+    ours := make(map[string]*models.CrawlTask)
+    for _, task := range leased {
+        if task.SessionID == of[0].SessionID {
+            ours[task.ID] = task
+        }
+    }
+    return ours
+}
+
+func taskStatus(t *testing.T, s *PostgreSQLStorage, id string) (string, int) {
+    t.Helper()
+    var status string
+    var attempts int
+    if err := s.db.QueryRow(`SELECT status, attempts FROM crawl_tasks WHERE id = $1`, id).Scan(&status, &attempts); err != nil {
+        t.Fatal(err)
+    }
+    return status, attempts
+}
+
+func TestLeaseLifecycle(t *testing.T) {
+    s := testPostgres(t)
+    tasks := testTasks(t, s, uuid.New().String()+".example")
+    id := tasks[0].ID
+
+    first := lease(t, s, "node-a", 10, nil, tasks)[id]
+    if first == nil || first.Status != "leased" || first.LeasedBy != "node-a" || first.LeaseID == "" {
+        t.Fatalf("first lease = %+v", first)
+    }
+    if again := lease(t, s, "node-b", 10, nil, tasks); len(again) != 0 {
+        t.Fatalf("a leased task was leased again: %v", again)
+    }
+
+    // The lease runs out while the task waits in a queue
+    if _, err := s.db.Exec(`UPDATE crawl_tasks SET lease_expires_at = NOW() - INTERVAL '1 minute' WHERE id = $1`, id); err != nil {
+        t.Fatal(err)
+    }
+    if n, err := s.ReclaimExpiredLeases(); err != nil || n < 1 {
+        t.Fatalf("ReclaimExpiredLeases = %d, %v", n, err)
+    }
+    if status, _ := taskStatus(t, s, id); status != "pending" {
+        t.Fatalf("reclaimed task is %s, want pending", status)
+    }
+
+    second := lease(t, s, "node-b", 10, nil, tasks)[id]
+    if second == nil || second.LeaseID == first.LeaseID {
+        t.Fatalf("second lease = %+v, want a new lease", second)
+    }
+
+    steps := []struct {
+        name    string
+        leaseID string
+        worker  string
+        started bool
+        status  string
+    }{
+        {"stale delivery of the first lease", first.LeaseID, "node-a/worker-0", false, "leased"},
+        {"delivery of the current lease", second.LeaseID, "node-b/worker-0", true, "running"},
+        {"redelivery of a running task", second.LeaseID, "node-b/worker-1", false, "running"},
+    }
+    for _, step := range steps {
+        started, err := s.StartTask(id, step.leaseID, step.worker, time.Minute)
+        if err != nil {
+            t.Fatalf("%s: StartTask: %v", step.name, err)
+        }
+        if started != step.started {
+            t.Errorf("%s: StartTask = %v, want %v", step.name, started, step.started)
+        }
+        if status, _ := taskStatus(t, s, id); status != step.status {
+            t.Errorf("%s: task is %s, want %s", step.name, status, step.status)
+        }
+    }
+
+    if err := s.CompleteTask(id, "done", ""); err != nil {
+        t.Fatal(err)
+    }
+    // Late completions and reclaims leave a finished task alone
+    if err := s.CompleteTask(id, "failed", "late"); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.ReclaimExpiredLeases(); err != nil {
+        t.Fatal(err)
+    }
+    if status, attempts := taskStatus(t, s, id); status != "done" || attempts != 1 {
+        t.Errorf("finished task is %s after %d attempts, want done after 1", status, attempts)
+    }
+}
+
+func TestLeaseTasksSpreadsHosts(t *testing.T) {
+    s := testPostgres(t)
+    busy, quiet := uuid.New().String()+".example", uuid.New().String()+".example"
+
+    tests := []struct {
+        name      string
+        perHost   int
+        skipHosts []string
+        want      map[string]int
+    }{
+        {"per host limit", 2, nil, map[string]int{busy: 2, quiet: 1}},
+        {"skipped host", 10, []string{quiet}, map[string]int{busy: 3}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tasks := testTasks(t, s, busy, busy, busy, quiet)
+            got := make(map[string]int)
+            for _, task := range lease(t, s, "node-a", tt.perHost, tt.skipHosts, tasks) {
+                got[task.Host]++
+            }
+            for host, want := range tt.want {
+                if got[host] != want {
+                    t.Errorf("leased %d tasks of %s, want %d", got[host], host, want)
+                }
+            }
+            if len(got) != len(tt.want) {
+                t.Errorf("leased tasks of %v", got)
+            }
+        })
+    }
+}
+
+func TestReleaseNodeTasks(t *testing.T) {
+    s := testPostgres(t)
+    host := uuid.New().String() + ".example"
+    tasks := testTasks(t, s, host, host, host)
+    node := "node-" + uuid.New().String()
+
+    leased := lease(t, s, node, 10, nil, tasks)
+    if len(leased) != 3 {
+        t.Fatalf("leased %d tasks, want 3", len(leased))
+    }
+    // One task runs on the node, one on a worker of another node
+    if ok, _ := s.StartTask(tasks[0].ID, leased[tasks[0].ID].LeaseID, node+"/worker-0", time.Minute); !ok {
+        t.Fatal("StartTask failed")
+    }
+    if ok, _ := s.StartTask(tasks[1].ID, leased[tasks[1].ID].LeaseID, "other/worker-0", time.Minute); !ok {
+        t.Fatal("StartTask failed")
+    }
+
+    n, err := s.ReleaseNodeTasks(node)
+    if err != nil || n != 2 {
+        t.Fatalf("ReleaseNodeTasks = %d, %v; want 2", n, err)
+    }
+    want := []string{"pending", "running", "pending"}
+    for i, task := range tasks {
+        if status, _ := taskStatus(t, s, task.ID); status != want[i] {
+            t.Errorf("task %d is %s, want %s", i, status, want[i])
+        }
+    }
+}
+
+func TestAddSessionStats(t *testing.T) {
+    s := testPostgres(t)
+    sessionID := testTasks(t, s, uuid.New().String()+".example")[0].SessionID
+
+    steps := []struct {
+        name      string
+        node      string
+        delta     models.SessionStats
+        rate      int
+        completed int
+        perMinute int
+    }{
+        {"first node", "node-a", models.SessionStats{CompletedTasks: 3, PendingTasks: 5}, 30, 3, 30},
+        {"second node adds up", "node-b", models.SessionStats{CompletedTasks: 2}, 20, 5, 50},
+        {"a node's rate is replaced", "node-a", models.SessionStats{CompletedTasks: 1}, 10, 6, 30},
+        {"a leaving node withdraws its rate", "node-b", models.SessionStats{}, 0, 6, 10},
+    }
+    for _, step := range steps {
+        totals, err := s.AddSessionStats(sessionID, step.node, &step.delta, step.rate)
+        if err != nil {
+            t.Fatalf("%s: AddSessionStats: %v", step.name, err)
+        }
+        if totals.CompletedTasks != step.completed || totals.PagesPerMinute != step.perMinute {
+            t.Errorf("%s: completed %d at %d/min, want %d at %d/min",
+                step.name, totals.CompletedTasks, totals.PagesPerMinute, step.completed, step.perMinute)
+        }
+    }
+
+    // A crashed node's rate lapses once it is older than the rate window
+    if _, err := s.db.Exec(`UPDATE session_rates SET updated_at = NOW() - INTERVAL '2 minutes'
+                            WHERE session_id = $1 AND node_id = 'node-a'`, sessionID); err != nil {
+        t.Fatal(err)
+    }
+    session, err := s.GetCrawlSession(sessionID)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if session.Stats.PagesPerMinute != 0 || session.Stats.CompletedTasks != 6 || session.Stats.PendingTasks != 5 {
+        t.Errorf("stats = %+v, want 6 completed, 5 pending and no rate", session.Stats)
+    }
+}
+
+func TestCreateTasks(t *testing.T) {
+    s := testPostgres(t)
+
+    session := func(status string, maxPages int) string {
+        id := "test-" + uuid.New().String()
+        err := s.CreateCrawlSession(&models.CrawlSession{ID: id, Name: t.Name(), Status: status,
+            Rules: models.CrawlRules{MaxPages: maxPages}, CreatedAt: time.Now()})
+        if err != nil {
+            t.Fatal(err)
+        }
+        t.Cleanup(func() {
+            s.db.Exec(`DELETE FROM crawl_tasks WHERE session_id = $1`, id)
+            s.db.Exec(`DELETE FROM crawl_sessions WHERE id = $1`, id)
+        })
+        return id
+    }
+    open, stopped, budgeted := session("running", 0), session("stopped", 0), session("running", 3)
+
+    task := func(sessionID, id string) *models.CrawlTask {
+        return &models.CrawlTask{ID: id, SessionID: sessionID, URL: "https://example.com/" + id,
+            Method: "GET", Status: "pending", CreatedAt: time.Now()}
+    }
+    ids := func(tasks []*models.CrawlTask) []string {
+        var out []string
+        for _, task := range tasks {
+            out = append(out, task.ID)
+        }
+        return out
+    }
+    prefix := uuid.New().String()[:8] + "-"
+
+    batches := []struct {
+        name    string
+        tasks   []*models.CrawlTask
+        created []string
+    }{
+        {"new tasks", []*models.CrawlTask{task(open, prefix+"a"), task(open, prefix+"b")}, []string{prefix + "a", prefix + "b"}},
+        {"known IDs are skipped", []*models.CrawlTask{task(open, prefix+"a"), task(open, prefix+"c")}, []string{prefix + "c"}},
+        {"stopped sessions take none", []*models.CrawlTask{task(stopped, prefix+"d")}, nil},
+        {"within the budget", []*models.CrawlTask{task(budgeted, prefix+"e"), task(budgeted, prefix+"f")}, []string{prefix + "e", prefix + "f"}},
+        {"budget caps the next batch", []*models.CrawlTask{task(budgeted, prefix+"g"), task(budgeted, prefix+"h"), task(open, prefix+"i")}, []string{prefix + "g", prefix + "i"}},
+    }
+    for _, batch := range batches {
+        created, err := s.CreateTasks(batch.tasks)
+        if err != nil {
+            t.Fatalf("%s: CreateTasks: %v", batch.name, err)
+        }
+        if got := ids(created); !reflect.DeepEqual(got, batch.created) {
+            t.Errorf("%s: created %v, want %v", batch.name, got, batch.created)
+        }
+    }
+
+    var count int
+    if err := s.db.QueryRow(`SELECT COUNT(*) FROM crawl_tasks WHERE session_id = ANY($1)`,
+        pq.Array([]string{open, stopped, budgeted})).Scan(&count); err != nil {
+        t.Fatal(err)
+    }
+    if count != 7 {
+        t.Errorf("stored %d tasks, want 7", count)
+    }
+}
+
+func TestTaskLineage(t *testing.T) {
+    s := testPostgres(t)
+    host := uuid.New().String() + ".example"
+    root := testTasks(t, s, host)[0]
+
+    child := &models.CrawlTask{ID: uuid.New().String(), SessionID: root.SessionID, URL: "https://" + host + "/child",
+        Host: host, Method: "GET", Status: "pending", MaxDepth: 3, Depth: 1, ParentURL: root.URL, CreatedAt: time.Now()}
+    if created, err := s.CreateTasks([]*models.CrawlTask{child}); err != nil || len(created) != 1 {
+        t.Fatalf("CreateTasks created %d tasks: %v", len(created), err)
+    }
+
+    leased := lease(t, s, "node-a", 10, nil, []*models.CrawlTask{root})
+    tests := []struct {
+        task      *models.CrawlTask
+        depth     int
+        parentURL string
+    }{
+        {root, 0, ""},
+        {child, 1, root.URL},
+    }
+    for _, tt := range tests {
+        got := leased[tt.task.ID]
+        if got == nil {
+            t.Errorf("task %s was not leased", tt.task.URL)
+            continue
+        }
+        if got.Depth != tt.depth || got.ParentURL != tt.parentURL {
+            t.Errorf("task %s has depth %d and parent %q, want %d and %q", tt.task.URL, got.Depth, got.ParentURL, tt.depth, tt.parentURL)
+        }
+    }
+}
+
+func TestURLValidators(t *testing.T) {
+    s := testPostgres(t)
+    url := "https://" + uuid.New().String() + ".example/page"
+    t.Cleanup(func() { s.db.Exec(`DELETE FROM url_validators WHERE url = $1`, url) })
+
+    if v, err := s.GetURLValidators(url); err != nil || v != nil {
+        t.Fatalf("GetURLValidators of an unknown URL = %+v, %v, want nil", v, err)
+    }
+
+    fetchedAt := time.Now().UTC().Truncate(time.Second)
+    saves := []models.URLValidators{
+        {URL: url, ETag: `"v1"`, LastModified: "Mon, 04 Mar 2024 10:00:00 GMT", TaskID: "t1", SessionID: "s1", ContentLength: 512, FetchedAt: fetchedAt},
+        {URL: url, ETag: `W/"v2"`, TaskID: "t2", SessionID: "s2", ContentLength: 640, FetchedAt: fetchedAt.Add(time.Hour)},
+        // A URL that stopped sending validators keeps its snapshot only
+        {URL: url, TaskID: "t3", SessionID: "s2", ContentLength: 700, FetchedAt: fetchedAt.Add(2 * time.Hour)},
+    }
+    for i, want := range saves {
+        if err := s.SaveURLValidators(&want); err != nil {
+            t.Fatalf("save %d: %v", i, err)
+        }
+        got, err := s.GetURLValidators(url)
+        if err != nil || got == nil {
+            t.Fatalf("save %d: GetURLValidators = %+v, %v", i, got, err)
+        }
+        if !got.FetchedAt.Equal(want.FetchedAt) {
+            t.Errorf("save %d: fetched at %v, want %v", i, got.FetchedAt, want.FetchedAt)
+        }
+        got.FetchedAt = want.FetchedAt
+        if *got != want {
+            t.Errorf("save %d: read back %+v, want %+v", i, *got, want)
+        }
+    }
+}
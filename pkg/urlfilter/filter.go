@@ -0,0 +1,141 @@
+// pkg/urlfilter/filter.go
+package urlfilter
+
+import (
+    "fmt"
+    "net/url"
+    "regexp"
+    "strings"
+)
+
+type Config struct {
+    AllowedDomains  []string
+    BlockedDomains  []string
+    IncludePatterns []string
+    ExcludePatterns []string
+}
+
+// Filter decides whether a URL is in scope for a crawl session. Domain
+// entries are either exact hosts ("example.com") or wildcards matching any
+// subdomain ("*.example.com"); patterns are regular expressions matched
+// against the full URL.
+type Filter struct {
+    allowed []hostPattern
+    blocked []hostPattern
+    include []*regexp.Regexp
+    exclude []*regexp.Regexp
+}
+
+type hostPattern struct {
+    host     string
+    wildcard bool
+}
+
+func New(config Config) (*Filter, error) {
+    filter := &Filter{}
+
+    var err error
+    if filter.allowed, err = compileHosts(config.AllowedDomains); err != nil {
+        return nil, fmt.Errorf("invalid allowed domain: %v", err)
+    }
+    if filter.blocked, err = compileHosts(config.BlockedDomains); err != nil {
+        return nil, fmt.Errorf("invalid blocked domain: %v", err)
+    }
+    if filter.include, err = compilePatterns(config.IncludePatterns); err != nil {
+        return nil, fmt.Errorf("invalid URL pattern: %v", err)
+    }
+    if filter.exclude, err = compilePatterns(config.ExcludePatterns); err != nil {
+        return nil, fmt.Errorf("invalid exclude pattern: %v", err)
+    }
+
+    return filter, nil
+}
+
+// Allow reports whether rawURL may be crawled. When it may not, the
+// returned string explains which rule rejected it.
+func (f *Filter) Allow(rawURL string) (bool, string) {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return false, "unparseable URL"
+    }
+    if u.Scheme != "http" && u.Scheme != "https" {
+        return false, fmt.Sprintf("unsupported scheme %q", u.Scheme)
+    }
+
+    host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+    if host == "" {
+        return false, "missing host"
+    }
+
+    if matchHost(f.blocked, host) {
+        return false, fmt.Sprintf("host %s is blocked", host)
+    }
+    if len(f.allowed) > 0 && !matchHost(f.allowed, host) {
+        return false, fmt.Sprintf("host %s is not in allowed domains", host)
+    }
+
+    for _, re := range f.exclude {
+        if re.MatchString(rawURL) {
+            return false, fmt.Sprintf("matches exclude pattern %s", re)
+        }
+    }
+    if len(f.include) > 0 {
+        for _, re := range f.include {
+            if re.MatchString(rawURL) {
+                return true, ""
+            }
+        }
+        return false, "matches no URL pattern"
+    }
+
+    return true, ""
+}
+
+func compileHosts(entries []string) ([]hostPattern, error) {
+    patterns := make([]hostPattern, 0, len(entries))
+    for _, entry := range entries {
+        host := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".")
+        if host == "" {
+            continue
+        }
+
+        pattern := hostPattern{host: host}
+        if strings.HasPrefix(host, "*.") {
+            pattern.host = host[2:]
+            pattern.wildcard = true
+        }
+        if pattern.host == "" || strings.ContainsAny(pattern.host, "*/:") {
+            return nil, fmt.Errorf("%q", entry)
+        }
+        patterns = append(patterns, pattern)
+    }
+    return patterns, nil
+}
+
+func compilePatterns(exprs []string) ([]*regexp.Regexp, error) {
+    patterns := make([]*regexp.Regexp, 0, len(exprs))
+    for _, expr := range exprs {
+        if strings.TrimSpace(expr) == "" {
+            continue
+        }
+        re, err := regexp.Compile(expr)
+        if err != nil {
+            return nil, err
+        }
+        patterns = append(patterns, re)
+    }
+    return patterns, nil
+}
+
+func matchHost(patterns []hostPattern, host string) bool {
+    for _, p := range patterns {
+        if p.wildcard {
+            if strings.HasSuffix(host, "."+p.host) {
+                return true
+            }
+        } else if host == p.host {
+            return true
+        }
+    }
+    return false
+}
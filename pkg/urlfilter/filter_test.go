@@ -0,0 +1,78 @@
+// pkg/urlfilter/filter_test.go
+package urlfilter
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestAllow(t *testing.T) {
+    tests := []struct {
+        name   string
+        config Config
+        url    string
+        want   bool
+        reason string
+    }{
+        {"no rules", Config{}, "https://example.com/", true, ""},
+        {"unsupported scheme", Config{}, "ftp://example.com/file", false, "unsupported scheme"},
+        {"missing host", Config{}, "http:///path", false, "missing host"},
+        {"unparseable", Config{}, "http://exa mple.com/%zz", false, "unparseable"},
+
+        {"exact host", Config{AllowedDomains: []string{"example.com"}}, "https://example.com/a", true, ""},
+        {"exact host skips subdomain", Config{AllowedDomains: []string{"example.com"}}, "https://www.example.com/a", false, "not in allowed domains"},
+        {"wildcard matches subdomain", Config{AllowedDomains: []string{"*.example.com"}}, "https://a.b.example.com/", true, ""},
+        {"wildcard skips apex", Config{AllowedDomains: []string{"*.example.com"}}, "https://example.com/", false, "not in allowed domains"},
+        {"wildcard skips lookalike", Config{AllowedDomains: []string{"*.example.com"}}, "https://badexample.com/", false, "not in allowed domains"},
+        {"host case and trailing dot", Config{AllowedDomains: []string{"Example.COM."}}, "https://EXAMPLE.com./", true, ""},
+        {"port ignored", Config{AllowedDomains: []string{"example.com"}}, "https://example.com:8443/", true, ""},
+
+        {"blocked beats allowed", Config{AllowedDomains: []string{"*.example.com"}, BlockedDomains: []string{"ads.example.com"}},
+            "https://ads.example.com/", false, "is blocked"},
+        {"blocked wildcard", Config{BlockedDomains: []string{"*.tracker.net"}}, "http://cdn.tracker.net/x", false, "is blocked"},
+
+        {"include matches", Config{IncludePatterns: []string{`/blog/`}}, "https://example.com/blog/post", true, ""},
+        {"include misses", Config{IncludePatterns: []string{`/blog/`}}, "https://example.com/shop", false, "matches no URL pattern"},
+        {"exclude beats include", Config{IncludePatterns: []string{`/blog/`}, ExcludePatterns: []string{`\?page=`}},
+            "https://example.com/blog/?page=2", false, "matches exclude pattern"},
+        {"blank patterns ignored", Config{IncludePatterns: []string{" "}}, "https://example.com/", true, ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            filter, err := New(tt.config)
+            if err != nil {
+                t.Fatalf("New: %v", err)
+            }
+            got, reason := filter.Allow(tt.url)
+            if got != tt.want {
+                t.Errorf("Allow(%q) = %v (%s), want %v", tt.url, got, reason, tt.want)
+            }
+            if !strings.Contains(reason, tt.reason) {
+                t.Errorf("Allow(%q) reason = %q, want it to mention %q", tt.url, reason, tt.reason)
+            }
+        })
+    }
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+    tests := []struct {
+        name   string
+        config Config
+    }{
+        {"bare wildcard", Config{AllowedDomains: []string{"*."}}},
+        {"inner wildcard", Config{AllowedDomains: []string{"a.*.example.com"}}},
+        {"URL instead of host", Config{BlockedDomains: []string{"https://example.com"}}},
+        {"host with port", Config{AllowedDomains: []string{"example.com:80"}}},
+        {"bad include", Config{IncludePatterns: []string{"("}}},
+        {"bad exclude", Config{ExcludePatterns: []string{"[a-"}}},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if _, err := New(tt.config); err == nil {
+                t.Errorf("New(%+v) succeeded, want an error", tt.config)
+            }
+        })
+    }
+}
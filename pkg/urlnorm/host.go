@@ -0,0 +1,37 @@
+// pkg/urlnorm/host.go
+package urlnorm
+
+import (
+    "net"
+    "net/url"
+    "strings"
+
+    "golang.org/x/net/publicsuffix"
+)
+
+// Host returns the lowercased hostname of rawURL without port or trailing
+// dot, or an empty string when the URL cannot be parsed.
+func Host(rawURL string) string {
+    u, err := url.Parse(rawURL)
+    if err != nil {
+        return ""
+    }
+    return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// RegistrableDomain returns the public-suffix-aware registrable domain
+// (eTLD+1) of rawURL, e.g. "example.co.uk" for "https://a.b.example.co.uk/".
+// IP addresses and hosts that are themselves public suffixes are returned
+// unchanged.
+func RegistrableDomain(rawURL string) string {
+    host := Host(rawURL)
+    if host == "" || net.ParseIP(host) != nil {
+        return host
+    }
+
+    domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+    if err != nil {
+        return host
+    }
+    return domain
+}
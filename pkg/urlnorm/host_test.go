@@ -0,0 +1,37 @@
+// pkg/urlnorm/host_test.go
+package urlnorm
+
+import "testing"
+
+func TestHost(t *testing.T) {
+    tests := map[string]string{
+        "https://WWW.Example.com:8443/a": "www.example.com",
+        "http://example.com./":           "example.com",
+        "http://[::1]:8080/":             "::1",
+        "/relative":                      "",
+        "http://%zz/":                    "",
+    }
+    for in, want := range tests {
+        if got := Host(in); got != want {
+            t.Errorf("Host(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
+
+func TestRegistrableDomain(t *testing.T) {
+    tests := map[string]string{
+        "https://a.b.example.co.uk/": "example.co.uk",
+        "https://www.example.com/":   "example.com",
+        "https://example.com/":       "example.com",
+        "http://192.168.1.10/":       "192.168.1.10",
+        "http://[2001:db8::1]/":      "2001:db8::1",
+        "http://co.uk/":              "co.uk",
+        "http://localhost:8080/":     "localhost",
+        "/relative":                  "",
+    }
+    for in, want := range tests {
+        if got := RegistrableDomain(in); got != want {
+            t.Errorf("RegistrableDomain(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
@@ -0,0 +1,162 @@
+// pkg/urlnorm/normalize.go
+package urlnorm
+
+import (
+    "errors"
+    "net/url"
+    "sort"
+    "strings"
+)
+
+type Config struct {
+    // TrackingParams lists query parameters to drop. A trailing '*'
+    // matches any parameter with that prefix, e.g. "utm_*".
+    TrackingParams []string
+}
+
+var DefaultTrackingParams = []string{
+    "utm_*", "gclid", "fbclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga", "_hsenc", "_hsmi",
+}
+
+// Normalizer rewrites URLs into a canonical form so that trivially
+// different spellings of the same resource compare equal.
+type Normalizer struct {
+    exact    map[string]bool
+    prefixes []string
+}
+
+var defaultPorts = map[string]string{
+    "http":  "80",
+    "https": "443",
+}
+
+func NewNormalizer(config *Config) *Normalizer {
+    n := &Normalizer{exact: make(map[string]bool)}
+    for _, param := range config.TrackingParams {
+        param = strings.ToLower(strings.TrimSpace(param))
+        if strings.HasSuffix(param, "*") {
+            n.prefixes = append(n.prefixes, strings.TrimSuffix(param, "*"))
+        } else if param != "" {
+            n.exact[param] = true
+        }
+    }
+    return n
+}
+
+// Normalize lowercases the scheme and host, strips default ports and the
+// fragment, resolves dot segments, drops tracking parameters and sorts
+// the remaining query parameters.
+func (n *Normalizer) Normalize(rawURL string) (string, error) {
+    u, err := url.Parse(strings.TrimSpace(rawURL))
+    if err != nil {
+        return "", err
+    }
+    if !u.IsAbs() || u.Host == "" {
+        return "", errors.New("URL is not absolute")
+    }
+
+    scheme := strings.ToLower(u.Scheme)
+    host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+    if strings.Contains(host, ":") {
+        // IPv6 literal
+        host = "[" + host + "]"
+    }
+    if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+        host += ":" + port
+    }
+
+    var b strings.Builder
+    b.WriteString(scheme)
+    b.WriteString("://")
+    if u.User != nil {
+        b.WriteString(u.User.String())
+        b.WriteByte('@')
+    }
+    b.WriteString(host)
+
+    path := removeDotSegments(u.EscapedPath())
+    if path == "" {
+        path = "/"
+    }
+    b.WriteString(path)
+
+    if query := n.normalizeQuery(u.RawQuery); query != "" {
+        b.WriteByte('?')
+        b.WriteString(query)
+    }
+
+    return b.String(), nil
+}
+
+func (n *Normalizer) normalizeQuery(rawQuery string) string {
+    if rawQuery == "" {
+        return ""
+    }
+
+    params := make([]string, 0, strings.Count(rawQuery, "&")+1)
+    for _, param := range strings.Split(rawQuery, "&") {
+        if param == "" {
+            continue
+        }
+        key := param
+        if i := strings.IndexByte(param, '='); i >= 0 {
+            key = param[:i]
+        }
+        if decoded, err := url.QueryUnescape(key); err == nil {
+            key = decoded
+        }
+        if n.isTracking(strings.ToLower(key)) {
+            continue
+        }
+        params = append(params, param)
+    }
+
+    sort.Strings(params)
+    return strings.Join(params, "&")
+}
+
+func (n *Normalizer) isTracking(key string) bool {
+    if n.exact[key] {
+        return true
+    }
+    for _, prefix := range n.prefixes {
+        if strings.HasPrefix(key, prefix) {
+            return true
+        }
+    }
+    return false
+}
+
+// removeDotSegments implements RFC 3986 section 5.2.4 on an absolute path.
+func removeDotSegments(path string) string {
+    if !strings.Contains(path, ".") {
+        return path
+    }
+
+    segments := strings.Split(path, "/")
+    out := make([]string, 0, len(segments))
+    for i, segment := range segments {
+        last := i == len(segments)-1
+        switch segment {
+        case ".":
+            if last {
+                out = append(out, "")
+            }
+        case "..":
+            if len(out) > 1 {
+                out = out[:len(out)-1]
+            }
+            if last {
+                out = append(out, "")
+            }
+        default:
+            out = append(out, segment)
+        }
+    }
+
+    result := strings.Join(out, "/")
+    if strings.HasPrefix(path, "/") && !strings.HasPrefix(result, "/") {
+        result = "/" + result
+    }
+    return result
+}
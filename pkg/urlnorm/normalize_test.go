@@ -0,0 +1,80 @@
+// pkg/urlnorm/normalize_test.go
+package urlnorm
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+    n := NewNormalizer(&Config{TrackingParams: DefaultTrackingParams})
+
+    tests := []struct {
+        name string
+        in   string
+        want string
+    }{
+        {"lowercases scheme and host", "HTTP://Example.COM/Path", "http://example.com/Path"},
+        {"drops default http port", "http://example.com:80/", "http://example.com/"},
+        {"drops default https port", "https://example.com:443/", "https://example.com/"},
+        {"keeps other ports", "https://example.com:8443/", "https://example.com:8443/"},
+        {"keeps port of other scheme", "https://example.com:80/", "https://example.com:80/"},
+        {"adds root path", "http://example.com", "http://example.com/"},
+        {"drops fragment", "http://example.com/a#top", "http://example.com/a"},
+        {"drops trailing host dot", "http://example.com./", "http://example.com/"},
+        {"trims whitespace", "  http://example.com/a \n", "http://example.com/a"},
+        {"keeps user info", "http://user:pw@example.com/", "http://user:pw@example.com/"},
+        {"keeps IPv6 literal", "http://[::1]:8080/x", "http://[::1]:8080/x"},
+        {"keeps escaped path", "http://example.com/a%2Fb", "http://example.com/a%2Fb"},
+
+        {"removes dot segment", "http://example.com/a/./b", "http://example.com/a/b"},
+        {"removes parent segment", "http://example.com/a/b/../c", "http://example.com/a/c"},
+        {"trailing parent keeps slash", "http://example.com/a/b/..", "http://example.com/a/"},
+        {"trailing dot keeps slash", "http://example.com/a/.", "http://example.com/a/"},
+        {"parent above root", "http://example.com/../a", "http://example.com/a"},
+        {"dots inside names stay", "http://example.com/v1.2/file.tar.gz", "http://example.com/v1.2/file.tar.gz"},
+
+        {"sorts query", "http://example.com/?b=2&a=1", "http://example.com/?a=1&b=2"},
+        {"drops tracking params", "http://example.com/?utm_source=x&id=7&gclid=y", "http://example.com/?id=7"},
+        {"tracking match ignores case", "http://example.com/?UTM_Medium=x&id=7", "http://example.com/?id=7"},
+        {"tracking match decodes key", "http://example.com/?utm%5Fcampaign=x&id=7", "http://example.com/?id=7"},
+        {"drops empty query", "http://example.com/?utm_source=x", "http://example.com/"},
+        {"drops empty params", "http://example.com/?&a=1&&", "http://example.com/?a=1"},
+        {"keeps repeated params", "http://example.com/?a=2&a=1", "http://example.com/?a=1&a=2"},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := n.Normalize(tt.in)
+            if err != nil {
+                t.Fatalf("Normalize(%q): %v", tt.in, err)
+            }
+            if got != tt.want {
+                t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+            }
+        })
+    }
+}
+
+func TestNormalizeRejectsRelativeURLs(t *testing.T) {
+    n := NewNormalizer(&Config{})
+    for _, in := range []string{"/a/b", "example.com/a", "mailto:someone@example.com", "http://%zz/"} {
+        if got, err := n.Normalize(in); err == nil {
+            t.Errorf("Normalize(%q) = %q, want an error", in, got)
+        }
+    }
+}
+
+func TestTrackingParams(t *testing.T) {
+    n := NewNormalizer(&Config{TrackingParams: []string{" Ref ", "pk_*", ""}})
+
+    tests := map[string]bool{
+        "ref":       true,
+        "pk_source": true,
+        "pk":        false,
+        "referrer":  false,
+        "":          false,
+    }
+    for key, want := range tests {
+        if got := n.isTracking(key); got != want {
+            t.Errorf("isTracking(%q) = %v, want %v", key, got, want)
+        }
+    }
+}
@@ -0,0 +1,259 @@
+// pkg/warc/archive.go
+package warc
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+    "sync"
+    "time"
+)
+
+type Config struct {
+    // Dir holds one subdirectory of WARC files per session
+    Dir         string
+    Prefix      string
+    MaxFileSize int64
+    Software    string
+}
+
+// File is a finished or in-progress WARC file of a session. Size covers
+// only complete records, so reading up to it is safe while the file is
+// still being written.
+type File struct {
+    Path string
+    Size int64
+}
+
+// Archive writes gzipped WARC 1.1 files with a CDXJ index next to each,
+// rotating to a new file once MaxFileSize is reached.
+type Archive struct {
+    config   *Config
+    sessions map[string]*sessionFiles
+    mu       sync.Mutex
+}
+
+type sessionFiles struct {
+    dir     string
+    current *warcFile
+    files   []File
+    seq     int
+}
+
+type warcFile struct {
+    path  string
+    file  *os.File
+    size  int64
+    index []string
+}
+
+func NewArchive(config *Config) (*Archive, error) {
+    if config.Dir == "" {
+        return nil, fmt.Errorf("WARC directory is required")
+    }
+    if err := os.MkdirAll(config.Dir, 0755); err != nil {
+        return nil, err
+    }
+    return &Archive{
+        config:   config,
+        sessions: make(map[string]*sessionFiles),
+    }, nil
+}
+
+// Write appends the records of an exchange to the session's current file.
+func (a *Archive) Write(sessionID string, ex *Exchange) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    s, err := a.session(sessionID)
+    if err != nil {
+        return err
+    }
+    if s.current == nil || (a.config.MaxFileSize > 0 && s.current.size >= a.config.MaxFileSize) {
+        if err := a.rotate(s); err != nil {
+            return err
+        }
+    }
+
+    f := s.current
+    for _, r := range exchangeRecords(ex) {
+        data, err := r.gzipped()
+        if err != nil {
+            return err
+        }
+        offset := f.size
+        if _, err := f.file.Write(data); err != nil {
+            return fmt.Errorf("failed to write WARC record: %v", err)
+        }
+        f.size += int64(len(data))
+
+        if r.warcType == "response" {
+            f.index = append(f.index, cdxjLine(ex, filepath.Base(f.path), offset, int64(len(data))))
+        }
+    }
+    s.files[len(s.files)-1].Size = f.size
+    return nil
+}
+
+// Files lists the session's WARC files in the order they were written.
+func (a *Archive) Files(sessionID string) ([]File, error) {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    s, err := a.session(sessionID)
+    if err != nil {
+        return nil, err
+    }
+    return append([]File(nil), s.files...), nil
+}
+
+// CloseSession finishes the session's current file once it stops
+// producing records.
+func (a *Archive) CloseSession(sessionID string) error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    s, exists := a.sessions[sessionID]
+    if !exists {
+        return nil
+    }
+    delete(a.sessions, sessionID)
+    return s.closeCurrent()
+}
+
+func (a *Archive) Close() error {
+    a.mu.Lock()
+    defer a.mu.Unlock()
+
+    var firstErr error
+    for _, s := range a.sessions {
+        if err := s.closeCurrent(); err != nil && firstErr == nil {
+            firstErr = err
+        }
+    }
+    return firstErr
+}
+
+// session loads the files a session already has on disk, so that restarts
+// keep appending new files rather than overwriting old ones.
+func (a *Archive) session(sessionID string) (*sessionFiles, error) {
+    if s, exists := a.sessions[sessionID]; exists {
+        return s, nil
+    }
+    if strings.ContainsAny(sessionID, `/\`) || sessionID == "" || sessionID == "." || sessionID == ".." {
+        return nil, fmt.Errorf("invalid session id %q", sessionID)
+    }
+
+    s := &sessionFiles{dir: filepath.Join(a.config.Dir, sessionID)}
+    paths, err := filepath.Glob(filepath.Join(s.dir, "*.warc.gz"))
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(paths)
+    for _, path := range paths {
+        info, err := os.Stat(path)
+        if err != nil {
+            return nil, err
+        }
+        s.files = append(s.files, File{Path: path, Size: info.Size()})
+    }
+    s.seq = len(paths)
+
+    a.sessions[sessionID] = s
+    return s, nil
+}
+
+func (a *Archive) rotate(s *sessionFiles) error {
+    if err := s.closeCurrent(); err != nil {
+        return err
+    }
+    if err := os.MkdirAll(s.dir, 0755); err != nil {
+        return err
+    }
+
+    prefix := a.config.Prefix
+    if prefix == "" {
+        prefix = "crawl"
+    }
+    now := time.Now()
+    name := fmt.Sprintf("%s-%s-%05d.warc.gz", prefix, now.UTC().Format("20060102150405"), s.seq)
+    s.seq++
+
+    path := filepath.Join(s.dir, name)
+    file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+    if err != nil {
+        return fmt.Errorf("failed to create WARC file: %v", err)
+    }
+
+    software := a.config.Software
+    if software == "" {
+        software = "Crawler666"
+    }
+    data, err := warcinfo(name, software, now).gzipped()
+    if err != nil {
+        file.Close()
+        return err
+    }
+    if _, err := file.Write(data); err != nil {
+        file.Close()
+        return fmt.Errorf("failed to write warcinfo record: %v", err)
+    }
+
+    s.current = &warcFile{path: path, file: file, size: int64(len(data))}
+    s.files = append(s.files, File{Path: path, Size: s.current.size})
+    return nil
+}
+
+// closeCurrent closes the open file and writes its index, sorted as CDXJ
+// readers expect.
+func (s *sessionFiles) closeCurrent() error {
+    f := s.current
+    if f == nil {
+        return nil
+    }
+    s.current = nil
+
+    if err := f.file.Close(); err != nil {
+        return err
+    }
+
+    sort.Strings(f.index)
+    index := strings.TrimSuffix(f.path, ".warc.gz") + ".cdxj"
+    var b strings.Builder
+    for _, line := range f.index {
+        b.WriteString(line)
+        b.WriteByte('\n')
+    }
+    return os.WriteFile(index, []byte(b.String()), 0644)
+}
+
+func cdxjLine(ex *Exchange, filename string, offset, length int64) string {
+    fields := map[string]string{
+        "url":      ex.URL,
+        "digest":   digest(ex.Body),
+        "length":   strconv.FormatInt(length, 10),
+        "offset":   strconv.FormatInt(offset, 10),
+        "filename": filename,
+    }
+    if ex.ContentType != "" {
+        fields["mime"] = strings.TrimSpace(strings.SplitN(ex.ContentType, ";", 2)[0])
+    }
+    if ex.StatusCode != 0 {
+        fields["status"] = strconv.Itoa(ex.StatusCode)
+    }
+    data, _ := json.Marshal(fields)
+    return surt(ex.URL) + " " + ex.Date.UTC().Format("20060102150405") + " " + string(data)
+}
+
+func sortedKeys(m map[string]string) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,170 @@
+// pkg/warc/record.go
+package warc
+
+import (
+    "bytes"
+    "compress/gzip"
+    "crypto/sha1"
+    "encoding/base32"
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/google/uuid"
+)
+
+const version = "WARC/1.1"
+
+// Exchange is one HTTP fetch as it went over the wire.
+type Exchange struct {
+    URL          string
+    IP           string
+    Date         time.Time
+    RequestHead  []byte
+    ResponseHead []byte
+    Body         []byte
+    ContentType  string
+    StatusCode   int
+    // Truncated marks a body cut off at the size limit
+    Truncated    bool
+    // Metadata is written as a metadata record concurrent to the response
+    Metadata map[string]string
+}
+
+type header struct {
+    name  string
+    value string
+}
+
+type record struct {
+    warcType    string
+    id          string
+    headers     []header
+    contentType string
+    block       []byte
+}
+
+func newRecord(warcType string, date time.Time, headers ...header) *record {
+    r := &record{
+        warcType: warcType,
+        id:       "<urn:uuid:" + uuid.New().String() + ">",
+    }
+    r.headers = append([]header{
+        {"WARC-Type", warcType},
+        {"WARC-Record-ID", r.id},
+        {"WARC-Date", date.UTC().Format("2006-01-02T15:04:05.000000Z")},
+    }, headers...)
+    return r
+}
+
+// gzipped serialises the record as its own gzip member, so that every
+// record can be read on its own from the offset recorded in the index.
+func (r *record) gzipped() ([]byte, error) {
+    var buf bytes.Buffer
+    gz := gzip.NewWriter(&buf)
+
+    var head strings.Builder
+    head.WriteString(version + "\r\n")
+    for _, h := range r.headers {
+        if h.value == "" {
+            continue
+        }
+        head.WriteString(h.name + ": " + h.value + "\r\n")
+    }
+    if r.contentType != "" {
+        head.WriteString("Content-Type: " + r.contentType + "\r\n")
+    }
+    head.WriteString("WARC-Block-Digest: " + digest(r.block) + "\r\n")
+    head.WriteString("Content-Length: " + strconv.Itoa(len(r.block)) + "\r\n\r\n")
+
+    gz.Write([]byte(head.String()))
+    gz.Write(r.block)
+    gz.Write([]byte("\r\n\r\n"))
+    if err := gz.Close(); err != nil {
+        return nil, err
+    }
+    return buf.Bytes(), nil
+}
+
+func digest(data []byte) string {
+    sum := sha1.Sum(data)
+    return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func warcinfo(filename, software string, date time.Time) *record {
+    r := newRecord("warcinfo", date, header{"WARC-Filename", filename})
+    r.contentType = "application/warc-fields"
+    r.block = []byte(fmt.Sprintf("software: %s\r\nformat: WARC File Format 1.1\r\n"+
+        "conformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\n", software))
+    return r
+}
+
+// exchangeRecords builds the response, request and metadata records of an
+// exchange. The response comes first so the others can refer to it.
+func exchangeRecords(ex *Exchange) []*record {
+    target := header{"WARC-Target-URI", ex.URL}
+    ip := header{"WARC-IP-Address", ex.IP}
+
+    truncated := header{"WARC-Truncated", ""}
+    if ex.Truncated {
+        truncated.value = "length"
+    }
+
+    response := newRecord("response", ex.Date, target, ip, header{"WARC-Payload-Digest", digest(ex.Body)}, truncated)
+    response.contentType = "application/http;msgtype=response"
+    response.block = append(append([]byte{}, ex.ResponseHead...), ex.Body...)
+
+    concurrent := header{"WARC-Concurrent-To", response.id}
+    records := []*record{response}
+
+    if len(ex.RequestHead) > 0 {
+        request := newRecord("request", ex.Date, target, ip, concurrent)
+        request.contentType = "application/http;msgtype=request"
+        request.block = ex.RequestHead
+        records = append(records, request)
+    }
+
+    if len(ex.Metadata) > 0 {
+        metadata := newRecord("metadata", ex.Date, target, concurrent)
+        metadata.contentType = "application/warc-fields"
+        var block strings.Builder
+        for _, key := range sortedKeys(ex.Metadata) {
+            block.WriteString(key + ": " + ex.Metadata[key] + "\r\n")
+        }
+        metadata.block = []byte(block.String())
+        records = append(records, metadata)
+    }
+
+    return records
+}
+
+// surt returns the Sort-friendly URI Reordering Transform key used by
+// CDXJ indexes, e.g. "com,example)/path?q=1".
+func surt(rawURL string) string {
+    u, err := url.Parse(rawURL)
+    if err != nil || u.Host == "" {
+        return rawURL
+    }
+
+    host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+    parts := strings.Split(host, ".")
+    for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+        parts[i], parts[j] = parts[j], parts[i]
+    }
+    key := strings.Join(parts, ",")
+    if port := u.Port(); port != "" && port != "80" && port != "443" {
+        key += ":" + port
+    }
+
+    path := u.EscapedPath()
+    if path == "" {
+        path = "/"
+    }
+    key += ")" + strings.ToLower(path)
+    if u.RawQuery != "" {
+        key += "?" + u.RawQuery
+    }
+    return key
+}
@@ -0,0 +1,270 @@
+// pkg/warc/warc_test.go
+package warc
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/json"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+var fetchedAt = time.Date(2024, 5, 1, 12, 30, 45, 0, time.UTC)
+
+func exchange(url, body string) *Exchange {
+    return &Exchange{
+        URL:          url,
+        IP:           "192.0.2.7",
+        Date:         fetchedAt,
+        RequestHead:  []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+        ResponseHead: []byte("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\n\r\n"),
+        Body:         []byte(body),
+        ContentType:  "text/html; charset=utf-8",
+        StatusCode:   200,
+    }
+}
+
+// gunzip reads a single gzip member holding one WARC record and splits the
+// record into its headers and block.
+func gunzip(t *testing.T, member io.Reader) (map[string]string, string) {
+    t.Helper()
+    gz, err := gzip.NewReader(member)
+    if err != nil {
+        t.Fatalf("not a gzip member: %v", err)
+    }
+    gz.Multistream(false)
+    data, err := io.ReadAll(gz)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    head, block, ok := strings.Cut(string(data), "\r\n\r\n")
+    if !ok || !strings.HasSuffix(block, "\r\n\r\n") {
+        t.Fatalf("record is not framed: %q", data)
+    }
+    lines := strings.Split(head, "\r\n")
+    if lines[0] != version {
+        t.Fatalf("record starts with %q", lines[0])
+    }
+    headers := make(map[string]string)
+    for _, line := range lines[1:] {
+        name, value, _ := strings.Cut(line, ": ")
+        headers[name] = value
+    }
+    block = strings.TrimSuffix(block, "\r\n\r\n")
+    if headers["Content-Length"] != strconv.Itoa(len(block)) {
+        t.Errorf("Content-Length %s, block of %d bytes", headers["Content-Length"], len(block))
+    }
+    if headers["WARC-Block-Digest"] != digest([]byte(block)) {
+        t.Errorf("WARC-Block-Digest does not match the block")
+    }
+    return headers, block
+}
+
+func TestExchangeRecords(t *testing.T) {
+    truncated := exchange("https://example.com/big", "<p>cut")
+    truncated.Truncated = true
+
+    withMetadata := exchange("https://example.com/", "<p>hi</p>")
+    withMetadata.Metadata = map[string]string{"workerID": "w-1", "finalURL": "https://example.com/", "fetchTimeMs": "12"}
+
+    rendered := exchange("https://example.com/app", "<html></html>")
+    rendered.RequestHead, rendered.IP = nil, ""
+
+    tests := []struct {
+        name      string
+        exchange  *Exchange
+        types     string
+        truncated string
+        ip        string
+        metadata  string
+    }{
+        {"response and request", exchange("https://example.com/", "<p>hi</p>"), "response,request", "", "192.0.2.7", ""},
+        {"truncated body", truncated, "response,request", "length", "192.0.2.7", ""},
+        {"metadata", withMetadata, "response,request,metadata", "", "192.0.2.7",
+            "fetchTimeMs: 12\r\nfinalURL: https://example.com/\r\nworkerID: w-1\r\n"},
+        {"no request or address", rendered, "response", "", "", ""},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            var types []string
+            var response map[string]string
+            for _, r := range exchangeRecords(tt.exchange) {
+                data, err := r.gzipped()
+                if err != nil {
+                    t.Fatal(err)
+                }
+                headers, block := gunzip(t, bytes.NewReader(data))
+                types = append(types, headers["WARC-Type"])
+
+                switch headers["WARC-Type"] {
+                case "response":
+                    response = headers
+                    if want := string(tt.exchange.ResponseHead) + string(tt.exchange.Body); block != want {
+                        t.Errorf("response block = %q, want %q", block, want)
+                    }
+                case "metadata":
+                    if block != tt.metadata {
+                        t.Errorf("metadata block = %q, want %q", block, tt.metadata)
+                    }
+                }
+                if headers["WARC-Type"] != "response" && headers["WARC-Concurrent-To"] != response["WARC-Record-ID"] {
+                    t.Errorf("%s record is concurrent to %q, want the response", headers["WARC-Type"], headers["WARC-Concurrent-To"])
+                }
+            }
+
+            if got := strings.Join(types, ","); got != tt.types {
+                t.Fatalf("records = %s, want %s", got, tt.types)
+            }
+            checks := map[string]string{
+                "WARC-Target-URI":     tt.exchange.URL,
+                "WARC-Date":           "2024-05-01T12:30:45.000000Z",
+                "WARC-Payload-Digest": digest(tt.exchange.Body),
+                "WARC-Truncated":      tt.truncated,
+                "WARC-IP-Address":     tt.ip,
+            }
+            for name, want := range checks {
+                if response[name] != want {
+                    t.Errorf("%s = %q, want %q", name, response[name], want)
+                }
+            }
+        })
+    }
+}
+
+func TestArchiveIndex(t *testing.T) {
+    dir := t.TempDir()
+    // Every exchange fills a file, so each lands in a file of its own
+    archive, err := NewArchive(&Config{Dir: dir, Prefix: "test", MaxFileSize: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    exchanges := []*Exchange{
+        exchange("https://www.example.com/b?x=1", "second"),
+        exchange("https://example.com:8080/A", "first"),
+    }
+    exchanges[1].StatusCode = 404
+    exchanges[1].ContentType = ""
+    for _, ex := range exchanges {
+        if err := archive.Write("s1", ex); err != nil {
+            t.Fatalf("Write: %v", err)
+        }
+    }
+    files, err := archive.Files("s1")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := archive.Close(); err != nil {
+        t.Fatal(err)
+    }
+    if len(files) != 2 {
+        t.Fatalf("wrote %d files, want 2", len(files))
+    }
+
+    want := []struct {
+        key    string
+        fields map[string]string
+    }{
+        {"com,example)/b?x=1", map[string]string{"url": exchanges[0].URL, "mime": "text/html", "status": "200"}},
+        {"com,example:8080)/a", map[string]string{"url": exchanges[1].URL, "status": "404"}},
+    }
+    for i, file := range files {
+        info, err := os.Stat(file.Path)
+        if err != nil || info.Size() != file.Size {
+            t.Errorf("%s: Files reports %d bytes, found %v (%v)", file.Path, file.Size, info.Size(), err)
+        }
+
+        index, err := os.ReadFile(strings.TrimSuffix(file.Path, ".warc.gz") + ".cdxj")
+        if err != nil {
+            t.Fatal(err)
+        }
+        lines := strings.Split(strings.TrimSuffix(string(index), "\n"), "\n")
+        if len(lines) != 1 {
+            t.Fatalf("%s indexes %d records, want 1", file.Path, len(lines))
+        }
+
+        parts := strings.SplitN(lines[0], " ", 3)
+        if parts[0] != want[i].key || parts[1] != "20240501123045" {
+            t.Errorf("index key = %s %s, want %s 20240501123045", parts[0], parts[1], want[i].key)
+        }
+        var fields map[string]string
+        if err := json.Unmarshal([]byte(parts[2]), &fields); err != nil {
+            t.Fatalf("index fields %q: %v", parts[2], err)
+        }
+        if fields["filename"] != filepath.Base(file.Path) {
+            t.Errorf("filename = %q, want %q", fields["filename"], filepath.Base(file.Path))
+        }
+        if fields["digest"] != digest(exchanges[i].Body) {
+            t.Errorf("digest = %q", fields["digest"])
+        }
+        for name, value := range want[i].fields {
+            if fields[name] != value {
+                t.Errorf("%s = %q, want %q", name, fields[name], value)
+            }
+        }
+        if _, ok := fields["mime"]; ok != (want[i].fields["mime"] != "") {
+            t.Errorf("mime = %q, want none", fields["mime"])
+        }
+
+        // The offset and length locate the response record on its own
+        offset, _ := strconv.ParseInt(fields["offset"], 10, 64)
+        length, _ := strconv.ParseInt(fields["length"], 10, 64)
+        f, err := os.Open(file.Path)
+        if err != nil {
+            t.Fatal(err)
+        }
+        headers, _ := gunzip(t, io.NewSectionReader(f, offset, length))
+        f.Close()
+        if headers["WARC-Type"] != "response" || headers["WARC-Target-URI"] != exchanges[i].URL {
+            t.Errorf("index points at a %s record of %s", headers["WARC-Type"], headers["WARC-Target-URI"])
+        }
+    }
+
+    // A restarted archive appends files instead of overwriting them
+    reopened, err := NewArchive(&Config{Dir: dir, Prefix: "test"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if err := reopened.Write("s1", exchange("https://example.com/", "third")); err != nil {
+        t.Fatal(err)
+    }
+    again, _ := reopened.Files("s1")
+    reopened.Close()
+    if len(again) != 3 || !strings.HasSuffix(again[2].Path, "-00002.warc.gz") {
+        t.Errorf("files after restart = %v", again)
+    }
+}
+
+func TestArchiveRejectsSessionPaths(t *testing.T) {
+    archive, err := NewArchive(&Config{Dir: t.TempDir()})
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, id := range []string{"", ".", "..", "a/b", `a\b`} {
+        if err := archive.Write(id, exchange("https://example.com/", "x")); err == nil {
+            t.Errorf("Write accepted session id %q", id)
+        }
+    }
+}
+
+func TestSurt(t *testing.T) {
+    tests := map[string]string{
+        "https://example.com/":             "com,example)/",
+        "https://www.Example.com/Path?q=1": "com,example)/path?q=1",
+        "http://example.com":               "com,example)/",
+        "http://a.b.example.co.uk:81/x":    "uk,co,example,b,a:81)/x",
+        "https://example.com:443/x":        "com,example)/x",
+        "not a url":                        "not a url",
+    }
+    for in, want := range tests {
+        if got := surt(in); got != want {
+            t.Errorf("surt(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
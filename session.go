@@ -0,0 +1,240 @@
+// session.go
+package main
+
+import (
+    "database/sql"
+    "errors"
+    "fmt"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+// sessionStatusTTL bounds how long a node keeps acting on a cached session
+// status that another node may have changed since.
+const sessionStatusTTL = 5 * time.Second
+
+var (
+    ErrSessionNotFound     = errors.New("session not found")
+    ErrInvalidSessionState = errors.New("invalid session state")
+)
+
+// StopSession ends a session for good: its pending, leased and running
+// tasks are cancelled and no new tasks are accepted for it.
+func (e *CrawlerEngine) StopSession(sessionID string) (*models.CrawlSession, error) {
+    state, err := e.transitionSession(sessionID, "stopped", "pending", "running", "paused")
+    if err != nil {
+        return nil, err
+    }
+
+    e.scheduler.dropSession(sessionID)
+    cancelled, err := e.storage.CancelSessionTasks(sessionID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to cancel tasks: %v", err)
+    }
+    e.logger.Infof("Stopped session %s, cancelled %d tasks", sessionID, cancelled)
+
+    // Storage clears the pending count of ended sessions on flush
+    state.mu.Lock()
+    state.dirty = true
+    state.mu.Unlock()
+    e.flushSession(state, false)
+    e.closeArchive(sessionID)
+
+    return state.snapshot(), nil
+}
+
+// PauseSession freezes scheduling for a session. Its frontier is kept and
+// tasks already being fetched are allowed to finish.
+func (e *CrawlerEngine) PauseSession(sessionID string) (*models.CrawlSession, error) {
+    state, err := e.transitionSession(sessionID, "paused", "running")
+    if err != nil {
+        return nil, err
+    }
+
+    e.scheduler.dropSession(sessionID)
+    released, err := e.storage.ReleaseSessionLeases(sessionID)
+    if err != nil {
+        return nil, fmt.Errorf("failed to release leased tasks: %v", err)
+    }
+    e.logger.Infof("Paused session %s, returned %d leased tasks to the frontier", sessionID, released)
+
+    return state.snapshot(), nil
+}
+
+// ResumeSession lets the scheduler lease a paused session's tasks again.
+func (e *CrawlerEngine) ResumeSession(sessionID string) (*models.CrawlSession, error) {
+    state, err := e.transitionSession(sessionID, "running", "paused")
+    if err != nil {
+        return nil, err
+    }
+
+    e.logger.Infof("Resumed session %s", sessionID)
+    return state.snapshot(), nil
+}
+
+// transitionSession moves a session to status if its stored status is one
+// of from, persisting the change and the matching timestamps.
+func (e *CrawlerEngine) transitionSession(sessionID, status string, from ...string) (*sessionState, error) {
+    // Another node may have changed the session, so trust storage over cache
+    session, err := e.storage.GetCrawlSession(sessionID)
+    if err == sql.ErrNoRows {
+        return nil, ErrSessionNotFound
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    allowed := false
+    for _, s := range from {
+        if session.Status == s {
+            allowed = true
+            break
+        }
+    }
+    if !allowed {
+        return nil, fmt.Errorf("%w: cannot move session from %s to %s", ErrInvalidSessionState, session.Status, status)
+    }
+
+    now := time.Now()
+    var startedAt, completedAt *time.Time
+    switch status {
+    case "running":
+        if session.StartedAt == nil {
+            startedAt = &now
+        }
+    case "stopped", "completed":
+        completedAt = &now
+    }
+
+    if err := e.storage.UpdateSessionStatus(sessionID, status, startedAt, completedAt); err != nil {
+        return nil, fmt.Errorf("failed to update session status: %v", err)
+    }
+
+    state := e.getSession(sessionID)
+    if state == nil {
+        return nil, ErrSessionNotFound
+    }
+    state.setStatus(status, startedAt, completedAt)
+    return state, nil
+}
+
+// completeFinishedSessions marks sessions whose frontier has drained as
+// completed.
+func (e *CrawlerEngine) completeFinishedSessions() {
+    ids, err := e.storage.CompleteFinishedSessions()
+    if err != nil {
+        e.logger.Errorf("Failed to complete finished sessions: %v", err)
+        return
+    }
+
+    now := time.Now()
+    for _, id := range ids {
+        e.mu.RLock()
+        state, exists := e.sessions[id]
+        e.mu.RUnlock()
+        if exists {
+            state.setStatus("completed", nil, &now)
+            state.mu.Lock()
+            state.dirty = true
+            state.mu.Unlock()
+            e.flushSession(state, false)
+        }
+        e.closeArchive(id)
+        e.logger.Infof("Session %s completed", id)
+    }
+}
+
+// closeArchive finishes the session's WARC file and index. Fetches still in
+// flight reopen a new file.
+func (e *CrawlerEngine) closeArchive(sessionID string) {
+    if err := e.storage.CloseSessionArchive(sessionID); err != nil {
+        e.logger.Errorf("Failed to close WARC archive of session %s: %v", sessionID, err)
+    }
+}
+
+// accepting reports whether new tasks may still be added to the session,
+// rereading its status from storage once the cached one is stale.
+func (e *CrawlerEngine) accepting(state *sessionState) bool {
+    e.refreshStatus(state)
+    return state.accepting()
+}
+
+// refreshStatus picks up status changes made on other nodes. A session
+// paused, stopped or completed elsewhere leaves this node's frontier too.
+func (e *CrawlerEngine) refreshStatus(state *sessionState) {
+    state.mu.Lock()
+    if time.Since(state.statusAt) < sessionStatusTTL {
+        state.mu.Unlock()
+        return
+    }
+    // Claim the refresh so concurrent callers keep the cached status
+    state.statusAt = time.Now()
+    previous := state.session.Status
+    state.mu.Unlock()
+
+    sessionID := state.session.ID
+    session, err := e.storage.GetCrawlSession(sessionID)
+    if err != nil {
+        e.logger.Errorf("Failed to refresh status of session %s: %v", sessionID, err)
+        return
+    }
+    if session.Status == previous {
+        return
+    }
+
+    state.setStatus(session.Status, session.StartedAt, session.CompletedAt)
+    e.logger.Infof("Session %s is now %s", sessionID, session.Status)
+
+    switch session.Status {
+    case "paused":
+        e.scheduler.dropSession(sessionID)
+    case "stopped", "completed":
+        e.scheduler.dropSession(sessionID)
+        e.closeArchive(sessionID)
+    }
+}
+
+func (s *sessionState) setStatus(status string, startedAt, completedAt *time.Time) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    s.statusAt = time.Now()
+    s.session.Status = status
+    if startedAt != nil {
+        s.session.StartedAt = startedAt
+    }
+    if completedAt != nil {
+        s.session.CompletedAt = completedAt
+    }
+}
+
+// accepting reports whether new tasks may still be added to the session.
+func (s *sessionState) accepting() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    switch s.session.Status {
+    case "stopped", "completed":
+        return false
+    }
+    return true
+}
+
+// budgetSpent reports whether the session already has MaxPages tasks.
+func (s *sessionState) budgetSpent() bool {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    maxPages := s.session.Rules.MaxPages
+    return maxPages > 0 && s.base.TotalTasks+s.stats.TotalTasks >= maxPages
+}
+
+func (s *sessionState) snapshot() *models.CrawlSession {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+
+    session := *s.session
+    session.Stats = s.currentStats(time.Now())
+    return &session
+}
@@ -0,0 +1,123 @@
+// sitemaps.go
+package main
+
+import (
+    "context"
+    "math"
+    "net/url"
+    "time"
+
+    "crawler666/internal/models"
+    "crawler666/pkg/sitemap"
+
+    "github.com/google/uuid"
+)
+
+const sitemapBatchSize = 500
+
+// seedFromSitemaps queues the URLs listed in the sitemaps of the session's
+// sites, then starts the session. Until then it stays pending, so its
+// frontier is neither leased nor mistaken for drained.
+func (e *CrawlerEngine) seedFromSitemaps(state *sessionState) {
+    session := state.session
+    rules := session.Rules.Sitemaps
+    ctx := context.Background()
+
+    sitemaps := append([]string(nil), rules.URLs...)
+    origins := make(map[string]bool)
+    for _, rawURL := range session.StartURLs {
+        u, err := url.Parse(rawURL)
+        if err != nil || u.Host == "" {
+            continue
+        }
+        origin := u.Scheme + "://" + u.Host
+        if origins[origin] {
+            continue
+        }
+        origins[origin] = true
+
+        var declared []string
+        if robotsRules, err := e.robots.Rules(origin + "/"); err == nil {
+            declared = robotsRules.Sitemaps
+        }
+        sitemaps = append(sitemaps, sitemap.Candidates(origin, declared)...)
+    }
+
+    queued, skipped := 0, 0
+    var batch []*models.CrawlTask
+    flush := func() error {
+        added, err := e.enqueue(state, batch)
+        if err != nil {
+            return err
+        }
+        batch = batch[:0]
+
+        state.mu.Lock()
+        state.stats.SitemapURLs += added
+        state.mu.Unlock()
+        queued += added
+        return nil
+    }
+
+    err := e.sitemaps.Walk(ctx, sitemaps, func(entry sitemap.Entry) error {
+        if !e.accepting(state) || state.budgetSpent() {
+            return sitemap.ErrStop
+        }
+
+        link, ok := e.admit(state, entry.Loc)
+        if !ok {
+            return nil
+        }
+        if rules.SkipUnchanged && e.unchangedSince(link, session.ID, entry.LastMod) {
+            skipped++
+            return nil
+        }
+
+        batch = append(batch, &models.CrawlTask{
+            ID:          uuid.New().String(),
+            SessionID:   session.ID,
+            URL:         link,
+            Method:      "GET",
+            Priority:    int(math.Round(entry.Priority * 10)),
+            MaxDepth:    session.Rules.MaxDepth,
+            Depth:       0,
+            CreatedAt:   time.Now(),
+            ScheduledAt: time.Now(),
+            Status:      "pending",
+        })
+        if len(batch) >= sitemapBatchSize {
+            if err := flush(); err != nil {
+                e.logger.Errorf("Failed to create sitemap tasks for session %s: %v", session.ID, err)
+                return sitemap.ErrStop
+            }
+        }
+        return nil
+    })
+    if err != nil {
+        e.logger.Warnf("Some sitemaps of session %s could not be read: %v", session.ID, err)
+    }
+    if len(batch) > 0 {
+        if err := flush(); err != nil {
+            e.logger.Errorf("Failed to create sitemap tasks for session %s: %v", session.ID, err)
+        }
+    }
+    e.logger.Infof("Seeded session %s with %d sitemap URLs, skipped %d unchanged", session.ID, queued, skipped)
+
+    if _, err := e.transitionSession(session.ID, "running", "pending"); err != nil {
+        e.logger.Errorf("Failed to start session %s after sitemap seeding: %v", session.ID, err)
+    }
+}
+
+// unchangedSince reports whether url was last fetched, by another session,
+// after lastMod. Without a lastmod there is nothing to go by.
+func (e *CrawlerEngine) unchangedSince(url, sessionID string, lastMod time.Time) bool {
+    if lastMod.IsZero() {
+        return false
+    }
+    previous, err := e.storage.GetLatestSnapshot(url, sessionID)
+    if err != nil {
+        e.logger.Errorf("Failed to load previous snapshot of %s: %v", url, err)
+        return false
+    }
+    return previous != nil && previous.StartTime.After(lastMod)
+}
@@ -0,0 +1,212 @@
+// stats.go
+package main
+
+import (
+    "context"
+    "time"
+
+    "crawler666/internal/models"
+)
+
+// taskOutcome carries a finished attempt from a worker to the result
+// processor. status is the task's terminal status, or "retry" when the
+// task went back to the frontier.
+type taskOutcome struct {
+    task   *models.CrawlTask
+    result *models.CrawlResult
+    status string
+}
+
+const rateWindowSize = 60
+
+// rateWindow counts events over the last minute in one-second buckets.
+type rateWindow struct {
+    buckets [rateWindowSize]int
+    seconds [rateWindowSize]int64
+}
+
+func (w *rateWindow) add(now time.Time) {
+    sec := now.Unix()
+    i := sec % rateWindowSize
+    if w.seconds[i] != sec {
+        w.seconds[i] = sec
+        w.buckets[i] = 0
+    }
+    w.buckets[i]++
+}
+
+func (w *rateWindow) count(now time.Time) int {
+    sec := now.Unix()
+    total := 0
+    for i := range w.buckets {
+        if sec-w.seconds[i] < rateWindowSize {
+            total += w.buckets[i]
+        }
+    }
+    return total
+}
+
+// recordOutcome updates the session's counters for a finished attempt.
+func (e *CrawlerEngine) recordOutcome(outcome *taskOutcome) {
+    state := e.getSession(outcome.task.SessionID)
+    if state == nil {
+        return
+    }
+
+    state.mu.Lock()
+    defer state.mu.Unlock()
+
+    if !outcome.result.Skipped {
+        state.fetches.add(time.Now())
+    }
+
+    switch outcome.status {
+    case "done":
+        state.stats.CompletedTasks++
+    case "unchanged":
+        state.stats.UnchangedTasks++
+        if saved, ok := outcome.result.Data.Metadata["bytes_saved"].(int64); ok {
+            state.stats.BytesSaved += saved
+        }
+    case "failed", "dead":
+        state.stats.FailedTasks++
+    case "skipped":
+        state.stats.SkippedTasks++
+    default:
+        // Retried tasks are still pending
+        state.dirty = true
+        return
+    }
+    switch outcome.result.Change {
+    case "new":
+        state.stats.NewPages++
+    case "changed":
+        state.stats.ChangedPages++
+    case "gone":
+        state.stats.GonePages++
+    }
+    state.stats.PendingTasks--
+    state.dirty = true
+}
+
+// SessionStats returns the live statistics the engine keeps for a
+// session, if it is active in this process.
+func (e *CrawlerEngine) SessionStats(sessionID string) (models.SessionStats, bool) {
+    e.mu.RLock()
+    state, exists := e.sessions[sessionID]
+    e.mu.RUnlock()
+    if !exists {
+        return models.SessionStats{}, false
+    }
+
+    state.mu.Lock()
+    defer state.mu.Unlock()
+    return state.currentStats(time.Now()), true
+}
+
+// currentStats adds what this process counted since its last flush to
+// the session's stored totals. The rate sums the other processes' rates as
+// last flushed and this one's as of now.
+func (s *sessionState) currentStats(now time.Time) models.SessionStats {
+    stats := s.base
+    addStats(&stats, &s.stats)
+    stats.PagesPerMinute += s.fetches.count(now) - s.flushedRate
+    // Tasks still in flight when the session ended finish after it
+    if stats.PendingTasks < 0 {
+        stats.PendingTasks = 0
+    }
+    return stats
+}
+
+func addStats(stats, delta *models.SessionStats) {
+    stats.TotalTasks += delta.TotalTasks
+    stats.CompletedTasks += delta.CompletedTasks
+    stats.FailedTasks += delta.FailedTasks
+    stats.SkippedTasks += delta.SkippedTasks
+    stats.PendingTasks += delta.PendingTasks
+    stats.PagesPerMinute += delta.PagesPerMinute
+    stats.DuplicateURLs += delta.DuplicateURLs
+    stats.UnchangedTasks += delta.UnchangedTasks
+    stats.BytesSaved += delta.BytesSaved
+    stats.NewPages += delta.NewPages
+    stats.ChangedPages += delta.ChangedPages
+    stats.GonePages += delta.GonePages
+    stats.SitemapURLs += delta.SitemapURLs
+}
+
+// flushSessionStats periodically writes changed session counters to
+// storage.
+func (e *CrawlerEngine) flushSessionStats(ctx context.Context) {
+    interval := time.Duration(e.config.StatsFlushInterval) * time.Second
+    if interval <= 0 {
+        interval = 5 * time.Second
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            e.flushAllSessions(true)
+            return
+        case <-ticker.C:
+            e.flushAllSessions(false)
+        }
+    }
+}
+
+func (e *CrawlerEngine) flushAllSessions(leaving bool) {
+    e.mu.RLock()
+    states := make([]*sessionState, 0, len(e.sessions))
+    for _, state := range e.sessions {
+        states = append(states, state)
+    }
+    e.mu.RUnlock()
+
+    for _, state := range states {
+        e.flushSession(state, leaving)
+    }
+}
+
+// flushSession adds the counts since the last flush to the stored stats,
+// reports this process's rate and picks up what other processes flushed
+// meanwhile. A process that is leaving withdraws its rate.
+func (e *CrawlerEngine) flushSession(state *sessionState, leaving bool) {
+    state.flushing.Lock()
+    defer state.flushing.Unlock()
+
+    state.mu.Lock()
+    rate := 0
+    if !leaving {
+        rate = state.fetches.count(time.Now())
+    }
+    // A reported rate lapses unless renewed, so keep flushing while there
+    // is one
+    if !state.dirty && rate == 0 && state.flushedRate == 0 {
+        state.mu.Unlock()
+        return
+    }
+    delta := state.stats
+    flushedRate := state.flushedRate
+    state.stats = models.SessionStats{}
+    state.flushedRate = rate
+    state.dirty = false
+    sessionID := state.session.ID
+    state.mu.Unlock()
+
+    totals, err := e.storage.AddSessionStats(sessionID, e.nodeID, &delta, rate)
+    if err != nil {
+        e.logger.Errorf("Failed to flush stats for session %s: %v", sessionID, err)
+        state.mu.Lock()
+        state.flushedRate = flushedRate
+        addStats(&state.stats, &delta)
+        state.dirty = true
+        state.mu.Unlock()
+        return
+    }
+
+    state.mu.Lock()
+    state.base = *totals
+    state.mu.Unlock()
+}
@@ -0,0 +1,263 @@
+// workers.go
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "time"
+)
+
+var (
+    ErrWorkerNotFound  = errors.New("worker not found")
+    ErrInvalidPoolSize = errors.New("invalid worker pool size")
+    ErrNoWorkerPool    = errors.New("no worker pool running")
+)
+
+// WorkerInfo describes a worker of the pool. State is idle, waiting (for
+// its task's host), busy or draining.
+type WorkerInfo struct {
+    ID    string `json:"id"`
+    State string `json:"state"`
+}
+
+// PoolStats counts the pool's workers by state.
+type PoolStats struct {
+    Active    int  `json:"active"`
+    Draining  int  `json:"draining"`
+    Busy      int  `json:"busy"`
+    Waiting   int  `json:"waiting"`
+    Autoscale bool `json:"autoscale"`
+    Min       int  `json:"min,omitempty"`
+    Max       int  `json:"max,omitempty"`
+}
+
+// startWorker adds a worker to the pool; e.mu must be held.
+func (e *CrawlerEngine) startWorker() *Worker {
+    // Named after the node so a dead node's tasks can be told apart
+    id := fmt.Sprintf("%s/worker-%d", e.nodeID, e.nextWorker)
+    e.nextWorker++
+
+    worker := e.createWorker(id, e.workersCtx)
+    e.workers[id] = worker
+    go worker.run()
+    return worker
+}
+
+func (e *CrawlerEngine) removeWorker(worker *Worker) {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+    if e.workers[worker.ID] == worker {
+        delete(e.workers, worker.ID)
+    }
+    worker.cancel()
+}
+
+// drainWorker lets a worker finish its current task and exit; e.mu must
+// be held.
+func (e *CrawlerEngine) drainWorker(worker *Worker) {
+    worker.active = false
+    worker.drain()
+}
+
+// ScaleWorkers grows or shrinks the pool to n active workers. Surplus
+// workers drain, idle ones first, so no task is cut short. Under
+// autoscaling n must lie within its bounds, and the autoscaler carries on
+// from there.
+func (e *CrawlerEngine) ScaleWorkers(n int) (PoolStats, error) {
+    if n < 0 {
+        return PoolStats{}, ErrInvalidPoolSize
+    }
+    if config := e.config.Autoscale; config.Enabled && (n < config.MinWorkers || n > config.MaxWorkers) {
+        return PoolStats{}, fmt.Errorf("%w: autoscaling keeps %d to %d workers", ErrInvalidPoolSize, config.MinWorkers, config.MaxWorkers)
+    }
+
+    e.mu.Lock()
+    if e.workersCtx == nil {
+        e.mu.Unlock()
+        return PoolStats{}, ErrNoWorkerPool
+    }
+    started, drained := e.resize(n)
+    e.mu.Unlock()
+
+    e.logger.Infof("Scaled worker pool to %d: started %d, draining %d", n, started, drained)
+    return e.WorkerPoolStats(), nil
+}
+
+// resize starts or drains workers until n are active; e.mu must be held.
+func (e *CrawlerEngine) resize(n int) (started, drained int) {
+    var active []*Worker
+    for _, worker := range e.workers {
+        if worker.active {
+            active = append(active, worker)
+        }
+    }
+
+    for len(active) < n {
+        active = append(active, e.startWorker())
+        started++
+    }
+    if len(active) > n {
+        sort.Slice(active, func(i, j int) bool {
+            return drainOrder(active[i]) < drainOrder(active[j])
+        })
+        for _, worker := range active[:len(active)-n] {
+            e.drainWorker(worker)
+            drained++
+        }
+    }
+    return started, drained
+}
+
+// drainOrder ranks workers by how much draining them costs.
+func drainOrder(worker *Worker) int {
+    switch {
+    case !worker.busy:
+        return 0
+    case worker.waiting:
+        return 1
+    }
+    return 2
+}
+
+// DrainWorker lets one worker finish its current task and exit.
+func (e *CrawlerEngine) DrainWorker(id string) error {
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    worker, exists := e.workers[id]
+    if !exists {
+        return ErrWorkerNotFound
+    }
+    if worker.active {
+        e.drainWorker(worker)
+        e.logger.Infof("Draining worker %s", id)
+    }
+    return nil
+}
+
+// Workers lists the pool's workers, including those still draining.
+func (e *CrawlerEngine) Workers() []WorkerInfo {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+
+    infos := make([]WorkerInfo, 0, len(e.workers))
+    for _, worker := range e.workers {
+        state := "idle"
+        switch {
+        case !worker.active:
+            state = "draining"
+        case worker.waiting:
+            state = "waiting"
+        case worker.busy:
+            state = "busy"
+        }
+        infos = append(infos, WorkerInfo{ID: worker.ID, State: state})
+    }
+    sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+    return infos
+}
+
+// WorkerPoolStats sums up the worker pool and its autoscaling bounds.
+func (e *CrawlerEngine) WorkerPoolStats() PoolStats {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+
+    stats := PoolStats{Autoscale: e.config.Autoscale.Enabled}
+    if stats.Autoscale {
+        stats.Min, stats.Max = e.config.Autoscale.MinWorkers, e.config.Autoscale.MaxWorkers
+    }
+    for _, worker := range e.workers {
+        if worker.active {
+            stats.Active++
+        } else {
+            stats.Draining++
+        }
+        if worker.busy {
+            stats.Busy++
+        }
+        if worker.waiting {
+            stats.Waiting++
+        }
+    }
+    return stats
+}
+
+// autoscale resizes the pool within the configured bounds at every
+// interval.
+func (e *CrawlerEngine) autoscale(ctx context.Context) {
+    ticker := time.NewTicker(time.Duration(e.config.Autoscale.Interval) * time.Second)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            e.autoscaleOnce()
+        }
+    }
+}
+
+func (e *CrawlerEngine) autoscaleOnce() {
+    config := e.config.Autoscale
+    depth := e.queue.Len()
+
+    e.mu.Lock()
+    defer e.mu.Unlock()
+
+    active, idle, waiting := 0, 0, 0
+    for _, worker := range e.workers {
+        if !worker.active {
+            continue
+        }
+        active++
+        if !worker.busy {
+            idle++
+        } else if worker.waiting {
+            waiting++
+        }
+    }
+
+    target := scaleTarget(active, idle, waiting, depth)
+    if target < config.MinWorkers {
+        target = config.MinWorkers
+    }
+    if target > config.MaxWorkers {
+        target = config.MaxWorkers
+    }
+    if target == active {
+        return
+    }
+
+    e.resize(target)
+    e.logger.Infof("Autoscaled workers from %d to %d (queued %d, idle %d, waiting on hosts %d)",
+        active, target, depth, idle, waiting)
+}
+
+// scaleTarget picks a pool size from the current one. The pool grows by
+// a quarter while tasks are queued and no worker is idle, as long as at
+// most half of the busy workers wait on host politeness: past that, more
+// workers would only wait as well. It shrinks by half the idle workers
+// when the queue is empty, and by half the politeness stalls beyond that
+// mark.
+func scaleTarget(active, idle, waiting, depth int) int {
+    busy := active - idle
+    switch {
+    case depth > 0 && idle == 0 && waiting*2 <= busy:
+        step := active / 4
+        if step < 1 {
+            step = 1
+        }
+        if step > depth {
+            step = depth
+        }
+        return active + step
+    case depth == 0 && idle > 0:
+        return active - (idle+1)/2
+    case waiting*2 > busy:
+        return active - (waiting-busy/2+1)/2
+    }
+    return active
+}
@@ -0,0 +1,34 @@
+// workers_test.go
+package main
+
+import "testing"
+
+func TestScaleTarget(t *testing.T) {
+    tests := []struct {
+        name                          string
+        active, idle, waiting, depth int
+        want                          int
+    }{
+        {"grows by a quarter", 8, 0, 0, 100, 10},
+        {"grows by at least one", 2, 0, 0, 5, 3},
+        {"grows an empty pool", 0, 0, 0, 3, 1},
+        {"grows no further than the queue", 20, 0, 0, 2, 22},
+        {"grows with half the busy waiting", 8, 0, 4, 10, 10},
+        {"holds with idle workers and a queue", 10, 2, 0, 5, 10},
+        {"holds when busy with an empty queue", 10, 0, 0, 0, 10},
+        {"holds an empty pool", 0, 0, 0, 0, 0},
+        {"shrinks by half the idle", 10, 5, 0, 0, 7},
+        {"shrinks a single idle worker", 4, 1, 0, 0, 3},
+        {"shrinks on politeness stalls despite a queue", 8, 0, 5, 100, 7},
+        {"shrinks on politeness stalls", 6, 0, 6, 0, 4},
+    }
+
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := scaleTarget(tt.active, tt.idle, tt.waiting, tt.depth); got != tt.want {
+                t.Errorf("scaleTarget(%d, %d, %d, %d) = %d, want %d",
+                    tt.active, tt.idle, tt.waiting, tt.depth, got, tt.want)
+            }
+        })
+    }
+}